@@ -5,6 +5,7 @@ import (
 	"api_gateway/internal/clients"
 	"api_gateway/internal/handlers"
 	"api_gateway/internal/middleware"
+	"api_gateway/internal/tracing"
 	"context"
 	"errors"
 	"net/http"
@@ -30,6 +31,18 @@ func main() {
 	}
 	logger.SetLevel(logLevel)
 	logger.Infof("Starting API Gateway...")
+
+	shutdownTracing, err := tracing.Init(context.Background(), "api_gateway", cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio, logger)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Error shutting down tracing: %v", err)
+		}
+	}()
 	logger.Infof("Log level set to: %s", logLevel.String())
 
 	clientTimeout := 5 * time.Second
@@ -55,23 +68,39 @@ func main() {
 	logger.Info("gRPC Clients initialized successfully.")
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatalf("FATAL: Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(gin.Recovery())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing("api_gateway"))
+	router.Use(middleware.Metrics())
 	router.Use(middleware.RequestLogger(logger))
 
-	authHandler := handlers.NewAuthHandler(userClient, logger)
+	sessionValidator := middleware.NewSessionValidator(userClient, cfg.SessionCacheTTL, logger)
+
+	authHandler := handlers.NewAuthHandler(userClient, sessionValidator, logger)
 	userHandler := handlers.NewUserHandler(userClient, logger)
 	productHandler := handlers.NewProductHandler(inventoryClient, logger)
 	categoryHandler := handlers.NewCategoryHandler(inventoryClient, logger)
 	orderHandler := handlers.NewOrderHandler(orderClient, logger)
 	logger.Info("HTTP Handlers initialized.")
 
+	loginLimiter := middleware.NewRateLimiter("auth", cfg.RateLimitAuthRPM, cfg.RateLimitAuthBurst, logger)
+	refreshLimiter := middleware.NewRateLimiter("refresh", cfg.RateLimitRefreshRPM, cfg.RateLimitRefreshBurst, logger)
+	registerLimiter := middleware.NewRateLimiter("register", cfg.RateLimitAuthRPM, cfg.RateLimitAuthBurst, logger)
+	catalogLimiter := middleware.NewRateLimiter("catalog", cfg.RateLimitCatalogRPM, cfg.RateLimitCatalogBurst, logger)
+	defaultLimiter := middleware.NewRateLimiter("default", cfg.RateLimitDefaultRPM, cfg.RateLimitDefaultBurst, logger)
+	ipLimiter := middleware.NewRateLimiter("ip", cfg.RateLimitIPRPM, cfg.RateLimitIPBurst, logger)
+
 	v1 := router.Group("/api/v1")
 
 	authGroup := v1.Group("/auth")
 	{
-		authGroup.POST("/login", authHandler.Login)
+		authGroup.POST("/login", loginLimiter.Middleware(), authHandler.Login)
+		authGroup.POST("/refresh", refreshLimiter.Middleware(), authHandler.Refresh)
 	}
-	userGroupPublic := v1.Group("/users")
+	userGroupPublic := v1.Group("/users", registerLimiter.Middleware())
 	{
 		userGroupPublic.POST("/register", userHandler.Register)
 	}
@@ -79,48 +108,83 @@ func main() {
 	// --- Protected Routes ---
 	protected := v1.Group("/")
 
-	protected.Use(middleware.AuthMiddleware(logger))
+	// The per-IP limit runs before token validation, so clients cycling through invalid or
+	// stolen tokens are limited too; the per-user limits of the route groups below run after it
+	protected.Use(ipLimiter.Middleware())
+	protected.Use(middleware.AuthMiddleware(logger, cfg.JwtSecret, cfg.JwtIssuer, sessionValidator))
+	staffOnly := middleware.RequireRoles(logger, middleware.RoleStaff, middleware.RoleAdmin)
+	adminOnly := middleware.RequireRoles(logger, middleware.RoleAdmin)
 	{
 
+		// --- Auth / Sessions ---
+		authProtected := protected.Group("/auth", defaultLimiter.Middleware())
+		{
+			authProtected.POST("/logout", authHandler.Logout)
+			authProtected.GET("/sessions", authHandler.ListSessions)
+			authProtected.DELETE("/sessions/:id", authHandler.RevokeSession)
+		}
+
 		// --- Products ---
-		products := protected.Group("/products")
+		products := protected.Group("/products", catalogLimiter.Middleware())
 		{
-			products.POST("", productHandler.CreateProduct)
+			products.POST("", staffOnly, productHandler.CreateProduct)
 			products.GET("", productHandler.ListProducts)
 			products.GET("/:id", productHandler.GetProduct)
-			products.PATCH("/:id", productHandler.UpdateProduct)
-			products.DELETE("/:id", productHandler.DeleteProduct)
+			products.PATCH("/:id", staffOnly, productHandler.UpdateProduct)
+			products.DELETE("/:id", staffOnly, productHandler.DeleteProduct)
+			products.POST("/:id/variants", staffOnly, productHandler.CreateProductVariant)
+			products.PATCH("/:id/variants/:variant_id", staffOnly, productHandler.UpdateProductVariant)
+			products.DELETE("/:id/variants/:variant_id", staffOnly, productHandler.DeleteProductVariant)
 		}
 
+		// Stock ledger for warehouse audits
+		protected.GET("/stock-movements", catalogLimiter.Middleware(), staffOnly, productHandler.ListStockMovements)
+
 		// Categories
-		categories := protected.Group("/categories")
+		categories := protected.Group("/categories", catalogLimiter.Middleware())
 		{
-			categories.POST("", categoryHandler.CreateCategory)
+			categories.POST("", staffOnly, categoryHandler.CreateCategory)
 			categories.GET("", categoryHandler.ListCategories)
+			categories.GET("/tree", categoryHandler.GetCategoryTree)
 			categories.GET("/:id", categoryHandler.GetCategory)
-			categories.PATCH("/:id", categoryHandler.UpdateCategory)
-			categories.DELETE("/:id", categoryHandler.DeleteCategory)
+			categories.GET("/:id/path", categoryHandler.GetCategoryPath)
+			categories.PATCH("/:id", staffOnly, categoryHandler.UpdateCategory)
+			categories.DELETE("/:id", staffOnly, categoryHandler.DeleteCategory)
 		}
 
 		//  Orders
-		orders := protected.Group("/orders")
+		orders := protected.Group("/orders", defaultLimiter.Middleware())
 		{
 			orders.POST("", orderHandler.CreateOrder)
 			orders.GET("", orderHandler.ListOrders)
 			orders.GET("/:id", orderHandler.GetOrder)
+			orders.GET("/:id/history", orderHandler.GetOrderHistory)
 			orders.PATCH("/:id", orderHandler.UpdateOrderStatus)
 		}
-		userGroupProtected := protected.Group("/users")
+		userGroupProtected := protected.Group("/users", defaultLimiter.Middleware())
 		{
 			userGroupProtected.GET("/profile/:id", userHandler.GetProfile)
 		}
 
+		// --- Admin ---
+		admin := protected.Group("/admin", adminOnly, defaultLimiter.Middleware())
+		{
+			admin.POST("/users/:id/roles", userHandler.GrantRole)
+			admin.DELETE("/users/:id/roles/:role", userHandler.RevokeRole)
+			admin.GET("/orders", orderHandler.SearchOrders)
+		}
+
 	}
 
 	// --- Health Check ---
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "UP"})
-	})
+	healthHandler := handlers.NewHealthHandler(map[string]handlers.HealthChecker{
+		"user_service":      userClient,
+		"order_service":     orderClient,
+		"inventory_service": inventoryClient,
+	}, cfg.HealthCheckTimeout, logger)
+	router.GET("/health", healthHandler.Live)
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
 
 	//Start HTTP Server with Graceful Shutdown
 	httpServer := &http.Server{
@@ -128,6 +192,8 @@ func main() {
 		Handler: router,
 	}
 
+	metricsServer := middleware.ServeMetrics(cfg.MetricsPort, logger)
+
 	serverErrChan := make(chan error, 1)
 
 	go func() {
@@ -164,5 +230,9 @@ func main() {
 		logger.Info("HTTP server gracefully stopped.")
 	}
 
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf("Error shutting down metrics server: %v", err)
+	}
+
 	logger.Info("API Gateway shut down gracefully.")
 }
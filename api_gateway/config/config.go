@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -12,11 +13,37 @@ import (
 
 type Config struct {
 	JwtSecret                string `envconfig:"JWT_SECRET"                  required:"true"`
+	JwtIssuer                string `envconfig:"JWT_ISSUER"                  default:"user_service"`
 	GatewayPort              string `envconfig:"API_GATEWAY_PORT"            default:":8080"`
+	MetricsPort              string `envconfig:"METRICS_PORT"                default:":9090"` // Prometheus /metrics listener
 	LogLevel                 string `envconfig:"LOG_LEVEL"                   default:"info"`
 	InventoryServiceGrpcAddr string `envconfig:"INVENTORY_SERVICE_GRPC_ADDR" required:"true"`
 	OrderServiceGrpcAddr     string `envconfig:"ORDER_SERVICE_GRPC_ADDR"     required:"true"`
 	UserServiceGrpcAddr      string `envconfig:"USER_SERVICE_GRPC_ADDR"      required:"true"`
+
+	SessionCacheTTL    time.Duration `envconfig:"SESSION_CACHE_TTL"    default:"15s"`
+	HealthCheckTimeout time.Duration `envconfig:"HEALTH_CHECK_TIMEOUT" default:"2s"` // Upper bound for /health/ready
+
+	// Proxies whose X-Forwarded-For is trusted when resolving the client IP for rate limiting
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+
+	// Token-bucket limits per client, in requests per minute plus burst size. Token refreshes have
+	// their own bucket since every open tab renews its access token
+	RateLimitAuthRPM      int `envconfig:"RATE_LIMIT_AUTH_RPM"      default:"10"`
+	RateLimitAuthBurst    int `envconfig:"RATE_LIMIT_AUTH_BURST"    default:"5"`
+	RateLimitRefreshRPM   int `envconfig:"RATE_LIMIT_REFRESH_RPM"   default:"60"`
+	RateLimitRefreshBurst int `envconfig:"RATE_LIMIT_REFRESH_BURST" default:"20"`
+	RateLimitCatalogRPM   int `envconfig:"RATE_LIMIT_CATALOG_RPM"   default:"600"`
+	RateLimitCatalogBurst int `envconfig:"RATE_LIMIT_CATALOG_BURST" default:"100"`
+	RateLimitDefaultRPM   int `envconfig:"RATE_LIMIT_DEFAULT_RPM"   default:"120"`
+	RateLimitDefaultBurst int `envconfig:"RATE_LIMIT_DEFAULT_BURST" default:"30"`
+	// Per-IP limit in front of token validation on protected routes; a whole office behind one NAT shares it
+	RateLimitIPRPM   int `envconfig:"RATE_LIMIT_IP_RPM"   default:"1200"`
+	RateLimitIPBurst int `envconfig:"RATE_LIMIT_IP_BURST" default:"200"`
+
+	TracingExporter     string  `envconfig:"TRACING_EXPORTER"      default:"none"` // none, stdout or otlp
+	TracingOTLPEndpoint string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+	TracingSampleRatio  float64 `envconfig:"TRACING_SAMPLE_RATIO"  default:"1"`
 }
 
 var (
@@ -39,9 +66,15 @@ func LoadConfig(logger *logrus.Logger) *Config {
 		}
 
 		// Log loaded config
-		logger.Infof("Configuration loaded: GatewayPort=%s, LogLevel=%s", config.GatewayPort, config.LogLevel)
+		logger.Infof("Configuration loaded: GatewayPort=%s, MetricsPort=%s, LogLevel=%s", config.GatewayPort, config.MetricsPort, config.LogLevel)
 		logger.Infof("UserServiceAddr=%s, InventoryServiceAddr=%s, OrderServiceAddr=%s",
 			config.UserServiceGrpcAddr, config.InventoryServiceGrpcAddr, config.OrderServiceGrpcAddr)
+		logger.Infof("Rate limits (rpm/burst): auth=%d/%d, refresh=%d/%d, catalog=%d/%d, default=%d/%d, ip=%d/%d",
+			config.RateLimitAuthRPM, config.RateLimitAuthBurst,
+			config.RateLimitRefreshRPM, config.RateLimitRefreshBurst,
+			config.RateLimitCatalogRPM, config.RateLimitCatalogBurst,
+			config.RateLimitDefaultRPM, config.RateLimitDefaultBurst,
+			config.RateLimitIPRPM, config.RateLimitIPBurst)
 		if config.JwtSecret == "" {
 			logger.Fatal("Configuration error: JWT_SECRET is not set")
 		}
@@ -1,14 +1,17 @@
 package clients
 
 import (
+	"api_gateway/internal/requestid"
 	inventorypb "api_gateway/proto/inventorypb"
 	"context"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -18,13 +21,23 @@ type InventoryServiceClient interface {
 	UpdateCategory(ctx context.Context, req *inventorypb.UpdateCategoryRequest) (*inventorypb.Category, error)
 	DeleteCategory(ctx context.Context, req *inventorypb.DeleteCategoryRequest) (*emptypb.Empty, error)
 	ListCategories(ctx context.Context, req *inventorypb.ListCategoriesRequest) (*inventorypb.ListCategoriesResponse, error)
+	GetCategoryTree(ctx context.Context, req *inventorypb.GetCategoryTreeRequest) (*inventorypb.GetCategoryTreeResponse, error)
+	GetCategoryPath(ctx context.Context, req *inventorypb.GetCategoryPathRequest) (*inventorypb.GetCategoryPathResponse, error)
 
 	CreateProduct(ctx context.Context, req *inventorypb.CreateProductRequest) (*inventorypb.Product, error)
 	GetProduct(ctx context.Context, req *inventorypb.GetProductRequest) (*inventorypb.Product, error)
 	UpdateProduct(ctx context.Context, req *inventorypb.UpdateProductRequest) (*inventorypb.Product, error)
 	DeleteProduct(ctx context.Context, req *inventorypb.DeleteProductRequest) (*emptypb.Empty, error)
 	ListProducts(ctx context.Context, req *inventorypb.ListProductsRequest) (*inventorypb.ListProductsResponse, error)
+	SearchProducts(ctx context.Context, req *inventorypb.SearchProductsRequest) (*inventorypb.SearchProductsResponse, error)
 
+	CreateProductVariant(ctx context.Context, req *inventorypb.CreateProductVariantRequest) (*inventorypb.ProductVariant, error)
+	UpdateProductVariant(ctx context.Context, req *inventorypb.UpdateProductVariantRequest) (*inventorypb.ProductVariant, error)
+	DeleteProductVariant(ctx context.Context, req *inventorypb.DeleteProductVariantRequest) (*emptypb.Empty, error)
+
+	ListStockMovements(ctx context.Context, req *inventorypb.ListStockMovementsRequest) (*inventorypb.ListStockMovementsResponse, error)
+
+	HealthCheck(ctx context.Context) error
 	Close() error
 }
 
@@ -42,6 +55,8 @@ func NewInventoryServiceClient(target string, logger *logrus.Logger, timeout tim
 	conn, err := grpc.DialContext(ctx, target,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
 		logger.Errorf("InventoryClient: Failed to dial %s: %v", target, err)
@@ -95,6 +110,16 @@ func (c *inventoryGRPCClient) ListCategories(ctx context.Context, req *inventory
 	return c.client.ListCategories(ctx, req)
 }
 
+func (c *inventoryGRPCClient) GetCategoryTree(ctx context.Context, req *inventorypb.GetCategoryTreeRequest) (*inventorypb.GetCategoryTreeResponse, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling GetCategoryTree: RootID=%d", req.GetRootId())
+	return c.client.GetCategoryTree(ctx, req)
+}
+
+func (c *inventoryGRPCClient) GetCategoryPath(ctx context.Context, req *inventorypb.GetCategoryPathRequest) (*inventorypb.GetCategoryPathResponse, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling GetCategoryPath: ID=%d", req.GetId())
+	return c.client.GetCategoryPath(ctx, req)
+}
+
 func (c *inventoryGRPCClient) CreateProduct(ctx context.Context, req *inventorypb.CreateProductRequest) (*inventorypb.Product, error) {
 	c.log.Debugf("InventoryClient(gRPC): Calling CreateProduct: Name=%s", req.GetName())
 	return c.client.CreateProduct(ctx, req)
@@ -123,3 +148,42 @@ func (c *inventoryGRPCClient) ListProducts(ctx context.Context, req *inventorypb
 	c.log.Debugf("InventoryClient(gRPC): Calling ListProducts: Limit=%d, Offset=%d", req.GetLimit(), req.GetOffset())
 	return c.client.ListProducts(ctx, req)
 }
+
+func (c *inventoryGRPCClient) SearchProducts(ctx context.Context, req *inventorypb.SearchProductsRequest) (*inventorypb.SearchProductsResponse, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling SearchProducts: Query=%q, Limit=%d, Offset=%d", req.GetQuery(), req.GetLimit(), req.GetOffset())
+	return c.client.SearchProducts(ctx, req)
+}
+
+func (c *inventoryGRPCClient) CreateProductVariant(ctx context.Context, req *inventorypb.CreateProductVariantRequest) (*inventorypb.ProductVariant, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling CreateProductVariant: ProductID=%d, SKU=%s", req.GetProductId(), req.GetSku())
+	return c.client.CreateProductVariant(ctx, req)
+}
+
+func (c *inventoryGRPCClient) UpdateProductVariant(ctx context.Context, req *inventorypb.UpdateProductVariantRequest) (*inventorypb.ProductVariant, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling UpdateProductVariant: ID=%d", req.GetVariant().GetId())
+	return c.client.UpdateProductVariant(ctx, req)
+}
+
+func (c *inventoryGRPCClient) DeleteProductVariant(ctx context.Context, req *inventorypb.DeleteProductVariantRequest) (*emptypb.Empty, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling DeleteProductVariant: ID=%d", req.GetId())
+	return c.client.DeleteProductVariant(ctx, req)
+}
+
+func (c *inventoryGRPCClient) ListStockMovements(ctx context.Context, req *inventorypb.ListStockMovementsRequest) (*inventorypb.ListStockMovementsResponse, error) {
+	c.log.Debugf("InventoryClient(gRPC): Calling ListStockMovements: ProductID=%d, Limit=%d, Offset=%d", req.GetProductId(), req.GetLimit(), req.GetOffset())
+	return c.client.ListStockMovements(ctx, req)
+}
+
+// HealthCheck asks the inventory service's grpc.health.v1 endpoint whether it is serving.
+func (c *inventoryGRPCClient) HealthCheck(ctx context.Context) error {
+	res, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: inventorypb.InventoryService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("inventory service health check failed: %w", err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("inventory service is %s", res.GetStatus())
+	}
+	return nil
+}
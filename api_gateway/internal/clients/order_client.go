@@ -1,14 +1,17 @@
 package clients
 
 import (
+	"api_gateway/internal/requestid"
 	orderpb "api_gateway/proto/orderpb"
 	"context"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type OrderServiceClient interface {
@@ -16,6 +19,9 @@ type OrderServiceClient interface {
 	GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error)
 	UpdateOrderStatus(ctx context.Context, req *orderpb.UpdateOrderStatusRequest) (*orderpb.Order, error)
 	ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error)
+	GetOrderHistory(ctx context.Context, req *orderpb.GetOrderHistoryRequest) (*orderpb.GetOrderHistoryResponse, error)
+	SearchOrders(ctx context.Context, req *orderpb.SearchOrdersRequest) (*orderpb.SearchOrdersResponse, error)
+	HealthCheck(ctx context.Context) error
 	Close() error
 }
 
@@ -33,6 +39,8 @@ func NewOrderServiceClient(target string, logger *logrus.Logger, timeout time.Du
 	conn, err := grpc.DialContext(ctx, target,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
 		logger.Errorf("OrderClient: Failed to dial %s: %v", target, err)
@@ -76,3 +84,27 @@ func (c *orderGRPCClient) ListOrders(ctx context.Context, req *orderpb.ListOrder
 	c.log.Debugf("OrderClient(gRPC): Calling ListOrders for UserID: %d", req.GetUserId())
 	return c.client.ListOrders(ctx, req)
 }
+
+func (c *orderGRPCClient) GetOrderHistory(ctx context.Context, req *orderpb.GetOrderHistoryRequest) (*orderpb.GetOrderHistoryResponse, error) {
+	c.log.Debugf("OrderClient(gRPC): Calling GetOrderHistory for OrderID: %d", req.GetId())
+	return c.client.GetOrderHistory(ctx, req)
+}
+
+func (c *orderGRPCClient) SearchOrders(ctx context.Context, req *orderpb.SearchOrdersRequest) (*orderpb.SearchOrdersResponse, error) {
+	c.log.Debugf("OrderClient(gRPC): Calling SearchOrders (limit: %d)", req.GetLimit())
+	return c.client.SearchOrders(ctx, req)
+}
+
+// HealthCheck asks the order service's grpc.health.v1 endpoint whether it is serving.
+func (c *orderGRPCClient) HealthCheck(ctx context.Context) error {
+	res, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: orderpb.OrderService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("order service health check failed: %w", err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("order service is %s", res.GetStatus())
+	}
+	return nil
+}
@@ -1,20 +1,32 @@
 package clients
 
 import (
+	"api_gateway/internal/requestid"
 	userpb "api_gateway/proto/userpb"
 	"context"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/types/known/emptypb"
 )
 
 type UserServiceClient interface {
 	AuthenticateUser(ctx context.Context, req *userpb.AuthenticateUserRequest) (*userpb.AuthenticateUserResponse, error)
 	RegisterUser(ctx context.Context, req *userpb.RegisterUserRequest) (*userpb.User, error)
 	GetUserProfile(ctx context.Context, req *userpb.GetUserProfileRequest) (*userpb.UserProfile, error)
+	ValidateToken(ctx context.Context, req *userpb.ValidateTokenRequest) (*userpb.ValidateTokenResponse, error)
+	Logout(ctx context.Context, req *userpb.LogoutRequest) (*emptypb.Empty, error)
+	ListSessions(ctx context.Context, req *userpb.ListSessionsRequest) (*userpb.ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, req *userpb.RevokeSessionRequest) (*emptypb.Empty, error)
+	RefreshToken(ctx context.Context, req *userpb.RefreshTokenRequest) (*userpb.AuthenticateUserResponse, error)
+	GrantRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error)
+	RevokeRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error)
+	HealthCheck(ctx context.Context) error
 	Close() error
 }
 
@@ -32,6 +44,8 @@ func NewUserServiceClient(target string, logger *logrus.Logger, timeout time.Dur
 	conn, err := grpc.DialContext(ctx, target,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
+		grpc.WithUnaryInterceptor(requestid.UnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
 		logger.Errorf("UserClient: Failed to dial %s: %v", target, err)
@@ -70,3 +84,52 @@ func (c *userServiceGRPCClient) GetUserProfile(ctx context.Context, req *userpb.
 	c.log.Debugf("UserClient(gRPC): Calling GetUserProfile for UserID: %d", req.GetUserId())
 	return c.client.GetUserProfile(ctx, req)
 }
+
+func (c *userServiceGRPCClient) ValidateToken(ctx context.Context, req *userpb.ValidateTokenRequest) (*userpb.ValidateTokenResponse, error) {
+	c.log.Debug("UserClient(gRPC): Calling ValidateToken")
+	return c.client.ValidateToken(ctx, req)
+}
+
+func (c *userServiceGRPCClient) Logout(ctx context.Context, req *userpb.LogoutRequest) (*emptypb.Empty, error) {
+	c.log.Debug("UserClient(gRPC): Calling Logout")
+	return c.client.Logout(ctx, req)
+}
+
+func (c *userServiceGRPCClient) ListSessions(ctx context.Context, req *userpb.ListSessionsRequest) (*userpb.ListSessionsResponse, error) {
+	c.log.Debugf("UserClient(gRPC): Calling ListSessions for UserID: %d", req.GetUserId())
+	return c.client.ListSessions(ctx, req)
+}
+
+func (c *userServiceGRPCClient) RevokeSession(ctx context.Context, req *userpb.RevokeSessionRequest) (*emptypb.Empty, error) {
+	c.log.Debugf("UserClient(gRPC): Calling RevokeSession for UserID: %d, SessionID: %s", req.GetUserId(), req.GetSessionId())
+	return c.client.RevokeSession(ctx, req)
+}
+
+func (c *userServiceGRPCClient) RefreshToken(ctx context.Context, req *userpb.RefreshTokenRequest) (*userpb.AuthenticateUserResponse, error) {
+	c.log.Debug("UserClient(gRPC): Calling RefreshToken")
+	return c.client.RefreshToken(ctx, req)
+}
+
+func (c *userServiceGRPCClient) GrantRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error) {
+	c.log.Debugf("UserClient(gRPC): Calling GrantRole for UserID: %d, Role: %s", req.GetUserId(), req.GetRole())
+	return c.client.GrantRole(ctx, req)
+}
+
+func (c *userServiceGRPCClient) RevokeRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error) {
+	c.log.Debugf("UserClient(gRPC): Calling RevokeRole for UserID: %d, Role: %s", req.GetUserId(), req.GetRole())
+	return c.client.RevokeRole(ctx, req)
+}
+
+// HealthCheck asks the user service's grpc.health.v1 endpoint whether it is serving.
+func (c *userServiceGRPCClient) HealthCheck(ctx context.Context) error {
+	res, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: userpb.UserService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("user service health check failed: %w", err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("user service is %s", res.GetStatus())
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"api_gateway/internal/clients"
+	"api_gateway/internal/middleware"
 	userpb "api_gateway/proto/userpb"
 	"context"
 	"net/http"
@@ -13,13 +14,15 @@ import (
 
 type AuthHandler struct {
 	userClient clients.UserServiceClient
+	sessions   *middleware.SessionValidator
 	log        *logrus.Logger
 }
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(uc clients.UserServiceClient, logger *logrus.Logger) *AuthHandler {
+func NewAuthHandler(uc clients.UserServiceClient, sessions *middleware.SessionValidator, logger *logrus.Logger) *AuthHandler {
 	return &AuthHandler{
 		userClient: uc,
+		sessions:   sessions,
 		log:        logger,
 	}
 }
@@ -32,7 +35,15 @@ type LoginRequest struct {
 
 // LoginResponse defines the JSON response for successful login
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshRequest defines the expected JSON body for token refresh requests
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 // Login handles the POST /login request
@@ -69,5 +80,132 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	handlerLogger.Infof("Authentication successful for UserID: %d", grpcRes.GetUserId())
-	c.JSON(http.StatusOK, LoginResponse{Token: grpcRes.GetToken()})
+	c.JSON(http.StatusOK, toLoginResponse(grpcRes))
+}
+
+// Refresh handles the POST /auth/refresh request
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "Refresh")
+	var req RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handlerLogger.Warnf("Failed to bind refresh request: %v", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.userClient.RefreshToken(ctx, &userpb.RefreshTokenRequest{RefreshToken: req.RefreshToken})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	handlerLogger.Infof("Token pair refreshed for UserID: %d", grpcRes.GetUserId())
+	c.JSON(http.StatusOK, toLoginResponse(grpcRes))
+}
+
+func toLoginResponse(res *userpb.AuthenticateUserResponse) LoginResponse {
+	return LoginResponse{
+		Token:            res.GetToken(),
+		ExpiresAt:        res.GetExpiresAt().AsTime(),
+		RefreshToken:     res.GetRefreshToken(),
+		RefreshExpiresAt: res.GetRefreshExpiresAt().AsTime(),
+	}
+}
+
+// SessionResponse describes one active session of the current user
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
+
+// Logout handles the POST /auth/logout request
+func (h *AuthHandler) Logout(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "Logout")
+
+	rawToken, _ := c.Get("rawToken")
+	tokenStr, _ := rawToken.(string)
+	if tokenStr == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authorization token missing or invalid"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.userClient.Logout(ctx, &userpb.LogoutRequest{Token: tokenStr}); err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	if sessionID, ok := c.Get("sessionID"); ok {
+		h.sessions.Invalidate(sessionID.(string))
+	}
+
+	handlerLogger.Info("Logout successful")
+	c.Status(http.StatusNoContent)
+}
+
+// ListSessions handles the GET /auth/sessions request
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "ListSessions")
+
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authorization token missing or invalid"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.userClient.ListSessions(ctx, &userpb.ListSessionsRequest{UserId: userID})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	currentSessionID := c.GetString("sessionID")
+	resp := make([]SessionResponse, 0, len(grpcRes.GetSessions()))
+	for _, session := range grpcRes.GetSessions() {
+		resp = append(resp, SessionResponse{
+			ID:        session.GetId(),
+			CreatedAt: session.GetCreatedAt().AsTime(),
+			ExpiresAt: session.GetExpiresAt().AsTime(),
+			Current:   session.GetId() == currentSessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": resp})
+}
+
+// RevokeSession handles the DELETE /auth/sessions/:id request
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "RevokeSession")
+
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authorization token missing or invalid"})
+		return
+	}
+	sessionID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.userClient.RevokeSession(ctx, &userpb.RevokeSessionRequest{UserId: userID, SessionId: sessionID})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	h.sessions.Invalidate(sessionID)
+
+	handlerLogger.Infof("Session %s revoked for UserID: %d", sessionID, userID)
+	c.Status(http.StatusNoContent)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type CategoryHandler struct {
@@ -25,7 +26,8 @@ func NewCategoryHandler(ic clients.InventoryServiceClient, logger *logrus.Logger
 }
 
 type CreateCategoryRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	ParentID int64  `json:"parent_id" binding:"min=0"` // 0 = top level
 }
 
 func (h *CategoryHandler) CreateCategory(c *gin.Context) {
@@ -38,7 +40,7 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 		return
 	}
 
-	grpcReq := &inventorypb.CreateCategoryRequest{Name: req.Name}
+	grpcReq := &inventorypb.CreateCategoryRequest{Name: req.Name, ParentId: req.ParentID}
 
 	ctxWithMD := getContextWithAuthToken(c)
 	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
@@ -81,7 +83,14 @@ func (h *CategoryHandler) GetCategory(c *gin.Context) {
 func (h *CategoryHandler) ListCategories(c *gin.Context) {
 	handlerLogger := h.log.WithField("handler", "ListCategories")
 
-	grpcReq := &inventorypb.ListCategoriesRequest{}
+	pageSize, err := strconv.ParseInt(c.DefaultQuery("page_size", "0"), 10, 32)
+	if err != nil || pageSize < 0 {
+		pageSize = 0 // Service default
+	}
+	grpcReq := &inventorypb.ListCategoriesRequest{
+		PageSize:  int32(pageSize),
+		PageToken: c.Query("page_token"),
+	}
 
 	ctxWithMD := getContextWithAuthToken(c)
 	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
@@ -96,9 +105,61 @@ func (h *CategoryHandler) ListCategories(c *gin.Context) {
 	c.JSON(http.StatusOK, grpcRes)
 }
 
+// GetCategoryTree serves GET /categories/tree?root_id=, the whole catalog unless root_id is given.
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "GetCategoryTree")
+
+	grpcReq := &inventorypb.GetCategoryTreeRequest{}
+	if rootIDStr := c.Query("root_id"); rootIDStr != "" {
+		rootID, err := strconv.ParseInt(rootIDStr, 10, 64)
+		if err != nil || rootID <= 0 {
+			handlerLogger.Warnf("Invalid root_id query parameter: %s", rootIDStr)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid root_id format"})
+			return
+		}
+		grpcReq.RootId = rootID
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.GetCategoryTree(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcRes)
+}
+
+func (h *CategoryHandler) GetCategoryPath(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "GetCategoryPath")
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		handlerLogger.Warnf("Invalid category ID parameter: %s", idStr)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid category ID format"})
+		return
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.GetCategoryPath(callCtx, &inventorypb.GetCategoryPathRequest{Id: id})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcRes)
+}
+
 // UpdateCategoryRequest for binding JSON
 type UpdateCategoryRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	ParentID *int64 `json:"parent_id" binding:"omitempty,min=0"` // Omit to keep the parent, 0 moves the category to the top level
 }
 
 func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
@@ -124,6 +185,9 @@ func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
 			Name: req.Name,
 		},
 	}
+	if req.ParentID != nil {
+		grpcReq.ParentId = &wrapperspb.Int64Value{Value: *req.ParentID}
+	}
 
 	ctxWithMD := getContextWithAuthToken(c)
 	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	healthStatusUp   = "UP"
+	healthStatusDown = "DOWN"
+)
+
+// HealthChecker is implemented by every downstream gRPC client.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	dependencies map[string]HealthChecker
+	timeout      time.Duration
+	log          *logrus.Logger
+}
+
+// NewHealthHandler creates a new HealthHandler; dependencies are keyed by the name reported in /health/ready
+func NewHealthHandler(dependencies map[string]HealthChecker, timeout time.Duration, logger *logrus.Logger) *HealthHandler {
+	return &HealthHandler{
+		dependencies: dependencies,
+		timeout:      timeout,
+		log:          logger,
+	}
+}
+
+// DependencyStatus describes the state of a single downstream service
+type DependencyStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadinessResponse defines the JSON response for GET /health/ready
+type ReadinessResponse struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Live handles GET /health/live: the process is up and able to serve HTTP
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": healthStatusUp})
+}
+
+// Ready handles GET /health/ready: every downstream service reports SERVING
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.timeout)
+	defer cancel()
+
+	resp := ReadinessResponse{
+		Status:       healthStatusUp,
+		Dependencies: make(map[string]DependencyStatus, len(h.dependencies)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, dep := range h.dependencies {
+		wg.Add(1)
+		go func(name string, dep HealthChecker) {
+			defer wg.Done()
+			status := DependencyStatus{Status: healthStatusUp}
+			if err := dep.HealthCheck(ctx); err != nil {
+				h.log.Warnf("Readiness check for %s failed: %v", name, err)
+				status = DependencyStatus{Status: healthStatusDown, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Dependencies[name] = status
+			if status.Status != healthStatusUp {
+				resp.Status = healthStatusDown
+			}
+		}(name, dep)
+	}
+	wg.Wait()
+
+	if resp.Status != healthStatusUp {
+		c.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
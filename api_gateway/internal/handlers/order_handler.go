@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"api_gateway/internal/clients"
+	"api_gateway/internal/middleware"
 	orderpb "api_gateway/proto/orderpb"
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type OrderHandler struct {
@@ -27,6 +31,7 @@ func NewOrderHandler(oc clients.OrderServiceClient, logger *logrus.Logger) *Orde
 
 type CreateOrderItemRequest struct {
 	ProductID int64 `json:"product_id" binding:"required,gt=0"`
+	VariantID int64 `json:"variant_id" binding:"gte=0"` // Required for products sold in variants
 	Quantity  int32 `json:"quantity" binding:"required,gt=0"`
 }
 
@@ -38,22 +43,13 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	handlerLogger := h.log.WithField("handler", "CreateOrder")
 	var req CreateOrderRequest
 
-	rawToken, _ := c.Get("rawToken")
-	if rawToken == nil || rawToken.(string) == "" {
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		handlerLogger.Warn("Could not get valid UserID from context (expected from middleware)")
 		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authorization token missing or invalid"})
 		return
 	}
-
-	userIDVal, _ := c.Get("userID")
-	var userID int64 = 1
-	if id, ok := userIDVal.(int); ok && id > 0 {
-		userID = int64(id)
-	} else if id64, ok := userIDVal.(int64); ok && id64 > 0 {
-		userID = id64
-	} else {
-		handlerLogger.Warn("Could not get valid UserID from context (expected from middleware), using placeholder 1")
-	}
-	handlerLogger.Infof("Handling CreateOrder for (placeholder/context) UserID: %d", userID)
+	handlerLogger.Infof("Handling CreateOrder for UserID: %d", userID)
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		handlerLogger.Warnf("Failed to bind request: %v", err)
@@ -66,13 +62,17 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	for _, item := range req.Items {
 		grpcItems = append(grpcItems, &orderpb.OrderItem{
 			ProductId: item.ProductID,
+			VariantId: item.VariantID,
 			Quantity:  item.Quantity,
 		})
 	}
 
+	// Retries that repeat the Idempotency-Key get the original order back instead of a duplicate,
+	// or the original error if inventory rejected the order's stock reservation
 	grpcReq := &orderpb.CreateOrderRequest{
-		UserId: userID,
-		Items:  grpcItems,
+		UserId:         userID,
+		Items:          grpcItems,
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
 	}
 
 	ctxWithMD := getContextWithAuthToken(c)
@@ -115,22 +115,39 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	c.JSON(http.StatusOK, grpcRes)
 }
 
+func (h *OrderHandler) GetOrderHistory(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "GetOrderHistory")
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		handlerLogger.Warnf("Invalid order ID parameter: %s", idStr)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid order ID format"})
+		return
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.orderClient.GetOrderHistory(callCtx, &orderpb.GetOrderHistoryRequest{Id: id})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcRes)
+}
+
 func (h *OrderHandler) ListOrders(c *gin.Context) {
 	handlerLogger := h.log.WithField("handler", "ListOrders")
 
-	rawToken, _ := c.Get("rawToken")
-	rawToken = rawToken.(string)
-	userIDVal, _ := c.Get("userID")
-	var userID int64 = 1
-	if id, ok := userIDVal.(int); ok && id > 0 {
-		userID = int64(id)
-	} else if id64, ok := userIDVal.(int64); ok && id64 > 0 {
-		userID = id64
-	} else {
-		handlerLogger.Warn("Could not get valid UserID from context (expected from middleware), using placeholder 1 for ListOrders")
-
+	userID, ok := getUserIDFromContext(c)
+	if !ok {
+		handlerLogger.Warn("Could not get valid UserID from context (expected from middleware) for ListOrders")
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authorization token missing or invalid"})
+		return
 	}
-	handlerLogger.Infof("Handling ListOrders for (placeholder/context) UserID: %d", userID)
+	handlerLogger.Infof("Handling ListOrders for UserID: %d", userID)
 
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
@@ -143,10 +160,13 @@ func (h *OrderHandler) ListOrders(c *gin.Context) {
 		offset = 0
 	}
 
+	// page_token continues where the previous page's next_page_token left off; offset is only
+	// kept for older clients
 	grpcReq := &orderpb.ListOrdersRequest{
-		UserId: userID,
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		UserId:    userID,
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+		PageToken: c.Query("page_token"),
 	}
 
 	ctxWithMD := getContextWithAuthToken(c)
@@ -163,7 +183,8 @@ func (h *OrderHandler) ListOrders(c *gin.Context) {
 }
 
 type UpdateOrderStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=pending completed cancelled"`
+	Status string `json:"status" binding:"required,oneof=pending paid processing shipped delivered completed cancelled refunded"`
+	Reason string `json:"reason" binding:"max=500"`
 }
 
 func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
@@ -183,14 +204,31 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
+	// Customers may only cancel; any other status change is reserved for staff
+	if req.Status != "cancelled" && !middleware.HasAnyRole(c, middleware.RoleStaff, middleware.RoleAdmin) {
+		handlerLogger.Warnf("Status change to %s for order %d denied: staff role required", req.Status, id)
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Insufficient permissions to set order status"})
+		return
+	}
+
 	var protoStatus orderpb.OrderStatus
 	switch req.Status {
 	case "pending":
 		protoStatus = orderpb.OrderStatus_PENDING
+	case "paid":
+		protoStatus = orderpb.OrderStatus_PAID
+	case "processing":
+		protoStatus = orderpb.OrderStatus_PROCESSING
+	case "shipped":
+		protoStatus = orderpb.OrderStatus_SHIPPED
+	case "delivered":
+		protoStatus = orderpb.OrderStatus_DELIVERED
 	case "completed":
 		protoStatus = orderpb.OrderStatus_COMPLETED
 	case "cancelled":
 		protoStatus = orderpb.OrderStatus_CANCELLED
+	case "refunded":
+		protoStatus = orderpb.OrderStatus_REFUNDED
 	default:
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid status value"})
 		return
@@ -199,6 +237,7 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	grpcReq := &orderpb.UpdateOrderStatusRequest{
 		Id:     id,
 		Status: protoStatus,
+		Reason: req.Reason,
 	}
 
 	ctxWithMD := getContextWithAuthToken(c)
@@ -213,3 +252,90 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, grpcRes)
 }
+
+// SearchOrders handles GET /admin/orders. Filters: status (comma-separated), user_id, product_id,
+// min_total, created_from and created_to (RFC 3339); sort_by=created_at|total, order=asc|desc,
+// limit and cursor (next_cursor of the previous page).
+func (h *OrderHandler) SearchOrders(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "SearchOrders")
+
+	grpcReq := &orderpb.SearchOrdersRequest{
+		SortBy: c.Query("sort_by"),
+		Cursor: c.Query("cursor"),
+	}
+	if statuses := c.Query("status"); statuses != "" {
+		for _, name := range strings.Split(statuses, ",") {
+			value, ok := orderpb.OrderStatus_value[strings.ToUpper(strings.TrimSpace(name))]
+			if !ok || value == int32(orderpb.OrderStatus_ORDER_STATUS_UNSPECIFIED) {
+				handlerLogger.Warnf("Invalid status query parameter: %s", name)
+				c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid status value: %s", name)})
+				return
+			}
+			grpcReq.Statuses = append(grpcReq.Statuses, orderpb.OrderStatus(value))
+		}
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		grpcReq.Ascending = true
+	case "desc":
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid order, expected asc or desc"})
+		return
+	}
+
+	for param, target := range map[string]*int64{"user_id": &grpcReq.UserId, "product_id": &grpcReq.ProductId} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			handlerLogger.Warnf("Invalid %s query parameter: %s", param, value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid %s format", param)})
+			return
+		}
+		*target = id
+	}
+	if value := c.Query("min_total"); value != "" {
+		minTotal, err := strconv.ParseFloat(value, 64)
+		if err != nil || minTotal < 0 {
+			handlerLogger.Warnf("Invalid min_total query parameter: %s", value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid min_total format"})
+			return
+		}
+		grpcReq.MinTotal = minTotal
+	}
+	for param, target := range map[string]**timestamppb.Timestamp{"created_from": &grpcReq.CreatedFrom, "created_to": &grpcReq.CreatedTo} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid %s query parameter: %s", param, value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid %s format, expected RFC 3339", param)})
+			return
+		}
+		*target = timestamppb.New(t)
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid limit format"})
+			return
+		}
+		grpcReq.Limit = int32(limit)
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 10*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.orderClient.SearchOrders(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcRes)
+}
@@ -4,6 +4,7 @@ import (
 	"api_gateway/internal/clients"
 	inventorypb "api_gateway/proto/inventorypb"
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
@@ -27,17 +29,36 @@ func NewProductHandler(ic clients.InventoryServiceClient, logger *logrus.Logger)
 	}
 }
 
+// actorMetadataKey tells inventory_service who made a stock change; it is recorded in the stock ledger.
+const actorMetadataKey = "x-actor"
+
 func getContextWithAuthToken(c *gin.Context) context.Context {
 	ctx := c.Request.Context()
 	if rawToken, exists := c.Get("rawToken"); exists {
 		if tokenStr, ok := rawToken.(string); ok && tokenStr != "" {
 			md := metadata.Pairs("x-auth-token", tokenStr)
+			if userID, ok := getUserIDFromContext(c); ok {
+				md.Set(actorMetadataKey, fmt.Sprintf("user:%d", userID))
+			}
 			return metadata.NewOutgoingContext(ctx, md)
 		}
 	}
 	return ctx
 }
 
+// getUserIDFromContext returns the user ID that AuthMiddleware stored after verifying the token.
+func getUserIDFromContext(c *gin.Context) (int64, bool) {
+	userIDVal, exists := c.Get("userID")
+	if !exists {
+		return 0, false
+	}
+	userID, ok := userIDVal.(int64)
+	if !ok || userID <= 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
 type CreateProductRequest struct {
 	Name       string  `json:"name" binding:"required"`
 	Price      float64 `json:"price" binding:"required,gt=0"`
@@ -100,9 +121,19 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, grpcRes)
 }
 
+// productSearchParams are the query parameters that turn GET /products into a search.
+var productSearchParams = []string{"q", "min_price", "max_price", "in_stock", "sort", "facets"}
+
 func (h *ProductHandler) ListProducts(c *gin.Context) {
 	handlerLogger := h.log.WithField("handler", "ListProducts")
 
+	for _, param := range productSearchParams {
+		if _, ok := c.GetQuery(param); ok {
+			h.searchProducts(c)
+			return
+		}
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	offsetStr := c.DefaultQuery("offset", "0")
 	categoryIDStr := c.Query("category_id")
@@ -117,8 +148,9 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 	}
 
 	grpcReq := &inventorypb.ListProductsRequest{
-		Limit:  int32(limit),
-		Offset: int32(offset),
+		Limit:     int32(limit),
+		Offset:    int32(offset),
+		PageToken: c.Query("page_token"),
 	}
 
 	if categoryIDStr != "" {
@@ -130,6 +162,15 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 		}
 		grpcReq.CategoryIdFilter = &wrapperspb.Int64Value{Value: catID}
 	}
+	if value := c.Query("include_subcategories"); value != "" {
+		includeSubcategories, err := strconv.ParseBool(value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid include_subcategories query parameter: %s", value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid include_subcategories format, expected true or false"})
+			return
+		}
+		grpcReq.IncludeSubcategories = includeSubcategories
+	}
 
 	ctxWithMD := getContextWithAuthToken(c)
 	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
@@ -143,6 +184,93 @@ func (h *ProductHandler) ListProducts(c *gin.Context) {
 	c.JSON(http.StatusOK, grpcRes)
 }
 
+// searchProducts serves GET /products?q=...&min_price=&max_price=&in_stock=true&sort=&category_id=&include_subcategories=true&limit=&offset=&facets=true
+func (h *ProductHandler) searchProducts(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "SearchProducts")
+
+	// Search results are ranked, so they page by offset only
+	if _, ok := c.GetQuery("page_token"); ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "page_token cannot be combined with search parameters, use offset instead"})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 32)
+	if err != nil || limit < 0 {
+		limit = 20
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 32)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	grpcReq := &inventorypb.SearchProductsRequest{
+		Query:  c.Query("q"),
+		Sort:   c.Query("sort"),
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	}
+	if categoryIDStr := c.Query("category_id"); categoryIDStr != "" {
+		catID, err := strconv.ParseInt(categoryIDStr, 10, 64)
+		if err != nil || catID <= 0 {
+			handlerLogger.Warnf("Invalid category_id query parameter: %s", categoryIDStr)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid category_id format"})
+			return
+		}
+		grpcReq.CategoryId = catID
+	}
+	if value := c.Query("include_subcategories"); value != "" {
+		includeSubcategories, err := strconv.ParseBool(value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid include_subcategories query parameter: %s", value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid include_subcategories format, expected true or false"})
+			return
+		}
+		grpcReq.IncludeSubcategories = includeSubcategories
+	}
+	for param, target := range map[string]*float64{"min_price": &grpcReq.MinPrice, "max_price": &grpcReq.MaxPrice} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 {
+			handlerLogger.Warnf("Invalid %s query parameter: %s", param, value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid %s format", param)})
+			return
+		}
+		*target = price
+	}
+	if value := c.Query("in_stock"); value != "" {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid in_stock query parameter: %s", value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid in_stock format, expected true or false"})
+			return
+		}
+		grpcReq.InStockOnly = inStock
+	}
+	if value := c.Query("facets"); value != "" {
+		facets, err := strconv.ParseBool(value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid facets query parameter: %s", value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid facets format, expected true or false"})
+			return
+		}
+		grpcReq.IncludeFacets = facets
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.SearchProducts(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+	c.JSON(http.StatusOK, grpcRes)
+}
+
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	handlerLogger := h.log.WithField("handler", "UpdateProduct")
 	idStr := c.Param("id")
@@ -214,6 +342,13 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		Product:    grpcProduct,
 		UpdateMask: &fieldmaskpb.FieldMask{Paths: maskPaths},
 	}
+	// Optional ledger details for stock changes, e.g. {"stock": 120, "stock_reason": "restock", "stock_reference_id": "PO-1881"}
+	if reason, ok := updates["stock_reason"].(string); ok {
+		grpcReq.StockReason = reason
+	}
+	if reference, ok := updates["stock_reference_id"].(string); ok {
+		grpcReq.StockReferenceId = reference
+	}
 
 	ctxWithMD := getContextWithAuthToken(c)
 	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
@@ -252,3 +387,211 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+type CreateProductVariantRequest struct {
+	SKU        string            `json:"sku" binding:"required"`
+	Attributes map[string]string `json:"attributes"`
+	Price      float64           `json:"price" binding:"gte=0"` // 0 = the product's price
+	Stock      int32             `json:"stock" binding:"gte=0"`
+}
+
+func (h *ProductHandler) CreateProductVariant(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "CreateProductVariant")
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		handlerLogger.Warnf("Invalid product ID parameter: %s", idStr)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid product ID format"})
+		return
+	}
+
+	var req CreateProductVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handlerLogger.Warnf("Failed to bind request: %v", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	grpcReq := &inventorypb.CreateProductVariantRequest{
+		ProductId:  id,
+		Sku:        req.SKU,
+		Attributes: req.Attributes,
+		Price:      req.Price,
+		Stock:      req.Stock,
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.CreateProductVariant(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, grpcRes)
+}
+
+// UpdateProductVariantRequest changes only the fields that are present; a price of 0 clears the override.
+type UpdateProductVariantRequest struct {
+	SKU              *string            `json:"sku"`
+	Attributes       *map[string]string `json:"attributes"`
+	Price            *float64           `json:"price"`
+	Stock            *int32             `json:"stock"`
+	StockReason      string             `json:"stock_reason"`
+	StockReferenceID string             `json:"stock_reference_id"`
+}
+
+func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "UpdateProductVariant")
+	productID, variantID, ok := parseVariantParams(c)
+	if !ok {
+		handlerLogger.Warnf("Invalid variant path parameters: %s/%s", c.Param("id"), c.Param("variant_id"))
+		return
+	}
+
+	var req UpdateProductVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handlerLogger.Warnf("Failed to bind update request body: %v", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	grpcVariant := &inventorypb.ProductVariant{Id: variantID, ProductId: productID}
+	maskPaths := []string{}
+	if req.SKU != nil {
+		grpcVariant.Sku = *req.SKU
+		maskPaths = append(maskPaths, "sku")
+	}
+	if req.Attributes != nil {
+		grpcVariant.Attributes = *req.Attributes
+		maskPaths = append(maskPaths, "attributes")
+	}
+	if req.Price != nil {
+		grpcVariant.Price = *req.Price
+		maskPaths = append(maskPaths, "price")
+	}
+	if req.Stock != nil {
+		grpcVariant.Stock = *req.Stock
+		maskPaths = append(maskPaths, "stock")
+	}
+	if len(maskPaths) == 0 {
+		handlerLogger.Warn("Update request received, but no valid fields to update")
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "No valid fields provided for update"})
+		return
+	}
+
+	grpcReq := &inventorypb.UpdateProductVariantRequest{
+		Variant:          grpcVariant,
+		UpdateMask:       &fieldmaskpb.FieldMask{Paths: maskPaths},
+		StockReason:      req.StockReason,
+		StockReferenceId: req.StockReferenceID,
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.UpdateProductVariant(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, grpcRes)
+}
+
+func (h *ProductHandler) DeleteProductVariant(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "DeleteProductVariant")
+	productID, variantID, ok := parseVariantParams(c)
+	if !ok {
+		handlerLogger.Warnf("Invalid variant path parameters: %s/%s", c.Param("id"), c.Param("variant_id"))
+		return
+	}
+
+	grpcReq := &inventorypb.DeleteProductVariantRequest{Id: variantID, ProductId: productID}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	if _, err := h.inventoryClient.DeleteProductVariant(callCtx, grpcReq); err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseVariantParams reads the product and variant IDs of /products/:id/variants/:variant_id and
+// answers 400 itself when either is invalid.
+func parseVariantParams(c *gin.Context) (int64, int64, bool) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || productID <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid product ID format"})
+		return 0, 0, false
+	}
+	variantID, err := strconv.ParseInt(c.Param("variant_id"), 10, 64)
+	if err != nil || variantID <= 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid variant ID format"})
+		return 0, 0, false
+	}
+	return productID, variantID, true
+}
+
+// ListStockMovements returns the stock ledger, newest first. Every query parameter is an optional filter;
+// from and to are RFC 3339 timestamps.
+func (h *ProductHandler) ListStockMovements(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "ListStockMovements")
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 32)
+	if err != nil || limit < 0 {
+		limit = 50
+	}
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 32)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	grpcReq := &inventorypb.ListStockMovementsRequest{
+		Reason:      c.Query("reason"),
+		ReferenceId: c.Query("reference_id"),
+		Actor:       c.Query("actor"),
+		Limit:       int32(limit),
+		Offset:      int32(offset),
+	}
+	if productIDStr := c.Query("product_id"); productIDStr != "" {
+		productID, err := strconv.ParseInt(productIDStr, 10, 64)
+		if err != nil || productID <= 0 {
+			handlerLogger.Warnf("Invalid product_id query parameter: %s", productIDStr)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid product_id format"})
+			return
+		}
+		grpcReq.ProductId = productID
+	}
+	for param, target := range map[string]**timestamppb.Timestamp{"from": &grpcReq.From, "to": &grpcReq.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			handlerLogger.Warnf("Invalid %s query parameter: %s", param, value)
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("Invalid %s format, expected RFC 3339", param)})
+			return
+		}
+		*target = timestamppb.New(t)
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.inventoryClient.ListStockMovements(callCtx, grpcReq)
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+	c.JSON(http.StatusOK, grpcRes)
+}
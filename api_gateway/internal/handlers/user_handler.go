@@ -31,9 +31,15 @@ type RegisterRequest struct {
 }
 
 type RegisterResponse struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Roles []string `json:"roles"`
+}
+
+// UpdateRoleRequest defines the JSON body for granting a role
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=customer staff admin"`
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
@@ -67,6 +73,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 		ID:    grpcRes.GetId(),
 		Name:  grpcRes.GetName(),
 		Email: grpcRes.GetEmail(),
+		Roles: grpcRes.GetRoles(),
 	}
 	c.JSON(http.StatusCreated, resp)
 }
@@ -101,3 +108,63 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	handlerLogger.Infof("Profile retrieved successfully for UserID: %d", grpcRes.GetId())
 	c.JSON(http.StatusOK, grpcRes)
 }
+
+// GrantRole handles the POST /admin/users/:id/roles request
+func (h *UserHandler) GrantRole(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "GrantRole")
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil || userID <= 0 {
+		handlerLogger.Warnf("Invalid User ID in path parameter: %s", userIDStr)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid user ID format in URL"})
+		return
+	}
+
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		handlerLogger.Warnf("Failed to bind grant role request: %v", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.userClient.GrantRole(callCtx, &userpb.UpdateUserRoleRequest{UserId: userID, Role: req.Role})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	handlerLogger.Infof("Role %s granted to UserID: %d", req.Role, userID)
+	c.JSON(http.StatusOK, grpcRes)
+}
+
+// RevokeRole handles the DELETE /admin/users/:id/roles/:role request
+func (h *UserHandler) RevokeRole(c *gin.Context) {
+	handlerLogger := h.log.WithField("handler", "RevokeRole")
+
+	userIDStr := c.Param("id")
+	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	if err != nil || userID <= 0 {
+		handlerLogger.Warnf("Invalid User ID in path parameter: %s", userIDStr)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid user ID format in URL"})
+		return
+	}
+	role := c.Param("role")
+
+	ctxWithMD := getContextWithAuthToken(c)
+	callCtx, cancel := context.WithTimeout(ctxWithMD, 5*time.Second)
+	defer cancel()
+
+	grpcRes, err := h.userClient.RevokeRole(callCtx, &userpb.UpdateUserRoleRequest{UserId: userID, Role: role})
+	if err != nil {
+		mapGrpcErrorToHttpStatus(c, handlerLogger, err)
+		return
+	}
+
+	handlerLogger.Infof("Role %s revoked from UserID: %d", role, userID)
+	c.JSON(http.StatusOK, grpcRes)
+}
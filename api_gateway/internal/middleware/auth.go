@@ -1,15 +1,37 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 )
 
-func AuthMiddleware(log *logrus.Logger) gin.HandlerFunc {
+// tokenClaims mirrors the access token claims issued by user_service.
+type tokenClaims struct {
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AuthMiddleware verifies the HS256 bearer token issued by user_service, checks that
+// its session is still active and stores the authenticated user ID in the gin context under "userID"
+// and the token roles under "roles".
+func AuthMiddleware(log *logrus.Logger, jwtSecret, jwtIssuer string, sessions *SessionValidator) gin.HandlerFunc {
+	secret := []byte(jwtSecret)
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithExpirationRequired(),
+	)
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,9 +54,44 @@ func AuthMiddleware(log *logrus.Logger) gin.HandlerFunc {
 			return
 		}
 
-		log.Debugf("Middleware: Extracted raw token (UUID): %s...", rawToken[:min(10, len(rawToken))])
+		log.Debugf("Middleware: Extracted raw token: %s...", rawToken[:min(10, len(rawToken))])
+
+		claims := &tokenClaims{}
+		if _, err := parser.ParseWithClaims(rawToken, claims, keyFunc); err != nil {
+			log.Warnf("Middleware: Token verification failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil || userID <= 0 || claims.ID == "" {
+			log.Warnf("Middleware: Token has invalid subject or session claim: sub=%q, jti=%q", claims.Subject, claims.ID)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		sessionUserID, err := sessions.Validate(c.Request.Context(), claims.ID, rawToken)
+		if err != nil {
+			if errors.Is(err, ErrSessionInvalid) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session is no longer valid"})
+				return
+			}
+			log.Errorf("Middleware: Session validation unavailable: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Authentication service temporarily unavailable"})
+			return
+		}
+		if sessionUserID != userID {
+			log.Warnf("Middleware: Session %s belongs to UserID %d, token subject is %d", claims.ID, sessionUserID, userID)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		log.Debugf("Middleware: Token verified for UserID: %d (session %s)", userID, claims.ID)
 
 		c.Set("rawToken", rawToken)
+		c.Set("userID", userID)
+		c.Set("sessionID", claims.ID)
+		c.Set("roles", claims.Roles)
 		c.Next()
 	}
 }
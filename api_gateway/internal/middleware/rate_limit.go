@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// visitorTTL is how long an idle client bucket is kept before it is dropped.
+	visitorTTL = 10 * time.Minute
+	// cleanupInterval is how often idle buckets are swept.
+	cleanupInterval = time.Minute
+)
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter is a token-bucket limiter for one route group. Authenticated requests are keyed
+// by user ID, anonymous ones by client IP, so each client gets its own bucket.
+type RateLimiter struct {
+	name        string
+	perMinute   int
+	limit       rate.Limit
+	burst       int
+	mu          sync.Mutex
+	visitors    map[string]*visitor
+	lastCleanup time.Time
+	log         *logrus.Logger
+}
+
+// NewRateLimiter allows requestsPerMinute sustained requests per client with bursts of up to burst requests.
+func NewRateLimiter(name string, requestsPerMinute, burst int, logger *logrus.Logger) *RateLimiter {
+	return &RateLimiter{
+		name:        name,
+		perMinute:   requestsPerMinute,
+		limit:       rate.Limit(float64(requestsPerMinute) / 60),
+		burst:       burst,
+		visitors:    make(map[string]*visitor),
+		lastCleanup: time.Now(),
+		log:         logger,
+	}
+}
+
+// Middleware returns the gin handler enforcing the limit. Placed after AuthMiddleware it keys
+// requests by user; placed before it, it limits by IP whatever token the request carries.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rateLimitKey(c)
+		limiter := l.getLimiter(key)
+
+		now := time.Now()
+		reservation := limiter.ReserveN(now, 1)
+		delay := reservation.DelayFrom(now)
+
+		// The limit is the sustained quota per minute; Remaining is what the bucket allows right now
+		c.Header("X-RateLimit-Limit", strconv.Itoa(l.perMinute))
+		if delay > 0 {
+			reservation.CancelAt(now)
+			retryAfter := int(math.Ceil(delay.Seconds()))
+
+			l.log.Warnf("Middleware: Rate limit '%s' exceeded for %s on %s %s", l.name, key, c.Request.Method, c.FullPath())
+			c.Header("X-RateLimit-Remaining", "0")
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(now.Add(delay).Unix(), 10))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please retry later"})
+			return
+		}
+
+		tokens := limiter.TokensAt(now)
+		remaining := int(math.Max(0, math.Floor(tokens)))
+		untilFull := time.Duration((float64(l.burst) - tokens) / float64(l.limit) * float64(time.Second))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(now.Add(untilFull).Unix(), 10))
+		c.Next()
+	}
+}
+
+func (l *RateLimiter) getLimiter(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastCleanup) > cleanupInterval {
+		for k, v := range l.visitors {
+			if now.Sub(v.lastSeen) > visitorTTL {
+				delete(l.visitors, k)
+			}
+		}
+		l.lastCleanup = now
+	}
+
+	v, ok := l.visitors[key]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.visitors[key] = v
+	}
+	v.lastSeen = now
+	return v.limiter
+}
+
+func rateLimitKey(c *gin.Context) string {
+	if userID := c.GetInt64("userID"); userID > 0 {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"api_gateway/internal/requestid"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validRequestID limits client-supplied IDs to a safe charset and length before they reach the logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID accepts the client's X-Request-ID (or generates a new one), returns it in the response
+// and stores it in the request context so the gRPC clients forward it to the services.
+// It must be registered before RequestLogger.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestid.Header)
+		if !validRequestID.MatchString(id) {
+			id = requestid.New()
+		}
+
+		c.Header(requestid.Header, id)
+		c.Set("requestID", id)
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
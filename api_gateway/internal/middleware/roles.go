@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Roles carried in the access token issued by user_service.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// RequireRoles allows the request through only if the authenticated user holds at least one
+// of the given roles. It must run after AuthMiddleware, which stores the token roles under "roles".
+func RequireRoles(log *logrus.Logger, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasAnyRole(c, roles...) {
+			log.Warnf("Middleware: UserID %d with roles %v denied access to %s %s (requires one of %v)",
+				c.GetInt64("userID"), c.GetStringSlice("roles"), c.Request.Method, c.FullPath(), roles)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasAnyRole reports whether the authenticated user holds at least one of the given roles.
+func HasAnyRole(c *gin.Context, roles ...string) bool {
+	userRoles := c.GetStringSlice("roles")
+	for _, required := range roles {
+		for _, role := range userRoles {
+			if role == required {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"api_gateway/internal/clients"
+	userpb "api_gateway/proto/userpb"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSessionInvalid is returned when user_service reports the token's session as revoked, expired or unknown.
+var ErrSessionInvalid = errors.New("session is not valid")
+
+const maxCachedSessions = 10000
+
+type sessionCacheEntry struct {
+	userID      int64
+	cachedUntil time.Time
+}
+
+// SessionValidator checks tokens against user_service and keeps positive answers
+// in a short-lived local cache keyed by session ID (the token's jti claim).
+type SessionValidator struct {
+	userClient clients.UserServiceClient
+	ttl        time.Duration
+	log        *logrus.Logger
+
+	mu      sync.RWMutex
+	entries map[string]sessionCacheEntry
+}
+
+func NewSessionValidator(uc clients.UserServiceClient, ttl time.Duration, logger *logrus.Logger) *SessionValidator {
+	return &SessionValidator{
+		userClient: uc,
+		ttl:        ttl,
+		log:        logger,
+		entries:    make(map[string]sessionCacheEntry),
+	}
+}
+
+// Validate returns the user ID owning the session, asking user_service when the cache has no fresh entry.
+func (v *SessionValidator) Validate(ctx context.Context, sessionID, rawToken string) (int64, error) {
+	now := time.Now()
+
+	v.mu.RLock()
+	entry, found := v.entries[sessionID]
+	v.mu.RUnlock()
+	if found && now.Before(entry.cachedUntil) {
+		return entry.userID, nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := v.userClient.ValidateToken(callCtx, &userpb.ValidateTokenRequest{Token: rawToken})
+	if err != nil {
+		return 0, fmt.Errorf("could not validate session with user service: %w", err)
+	}
+	if !res.GetValid() {
+		v.Invalidate(sessionID)
+		v.log.Warnf("Middleware: Session %s rejected by user service: %s", sessionID, res.GetErrorMessage())
+		return 0, ErrSessionInvalid
+	}
+
+	cachedUntil := now.Add(v.ttl)
+	if expiresAt := res.GetExpiresAt(); expiresAt != nil && expiresAt.AsTime().Before(cachedUntil) {
+		cachedUntil = expiresAt.AsTime()
+	}
+
+	v.mu.Lock()
+	if len(v.entries) >= maxCachedSessions {
+		v.pruneLocked(now)
+	}
+	v.entries[sessionID] = sessionCacheEntry{userID: res.GetUserId(), cachedUntil: cachedUntil}
+	v.mu.Unlock()
+
+	return res.GetUserId(), nil
+}
+
+// Invalidate drops the cached entry so the next request for this session goes back to user_service.
+func (v *SessionValidator) Invalidate(sessionID string) {
+	v.mu.Lock()
+	delete(v.entries, sessionID)
+	v.mu.Unlock()
+}
+
+func (v *SessionValidator) pruneLocked(now time.Time) {
+	for id, entry := range v.entries {
+		if !now.Before(entry.cachedUntil) {
+			delete(v.entries, id)
+		}
+	}
+}
@@ -5,8 +5,14 @@ import (
 	"database/sql"
 	"fmt"
 	"inventory_service/config"
+	"inventory_service/internal/actor"
+	"inventory_service/internal/clients"
 	grpcHandler "inventory_service/internal/delivery/grpc"
+	"inventory_service/internal/healthcheck"
+	"inventory_service/internal/metrics"
 	"inventory_service/internal/repository"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
 	"inventory_service/internal/usecase"
 	inventorypb "inventory_service/proto"
 	"net"
@@ -17,7 +23,10 @@ import (
 
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -33,6 +42,18 @@ func main() {
 	}
 	logger.Infof("Starting Inventory Service (gRPC)...")
 
+	shutdownTracing, err := tracing.Init(context.Background(), "inventory_service", cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio, logger)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Error shutting down tracing: %v", err)
+		}
+	}()
+
 	database, err := connectDB(cfg.DatabaseURL, logger)
 	if err != nil {
 		logger.Fatalf("FATAL: Failed to connect to database: %v", err)
@@ -48,13 +69,21 @@ func main() {
 
 	categoryRepo := repository.NewPostgresCategoryRepository(database, logger)
 	productRepo := repository.NewPostgresProductRepository(database, logger)
+	reservationRepo := repository.NewPostgresReservationRepository(database, logger)
+	movementRepo := repository.NewPostgresStockMovementRepository(database, logger)
 	logger.Info("Repositories initialized.")
 
+	orderClient, err := clients.NewOrderGRPCClient(cfg.OrderServiceGrpcAddr, logger)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to create Order gRPC client: %v", err)
+	}
+
 	categoryUseCase := usecase.NewCategoryUseCase(categoryRepo, logger)
-	productUseCase := usecase.NewProductUseCase(productRepo, categoryRepo, logger)
+	productUseCase := usecase.NewProductUseCase(productRepo, categoryRepo, movementRepo, orderClient, logger)
+	reservationUseCase := usecase.NewReservationUseCase(reservationRepo, cfg.ReservationTTL, logger)
 	logger.Info("Use cases initialized.")
 
-	inventoryGrpcHandler := grpcHandler.NewInventoryHandler(productUseCase, categoryUseCase, logger)
+	inventoryGrpcHandler := grpcHandler.NewInventoryHandler(productUseCase, categoryUseCase, reservationUseCase, logger)
 	logger.Info("gRPC Handler initialized.")
 
 	lis, err := net.Listen("tcp", cfg.GrpcPort)
@@ -63,13 +92,32 @@ func main() {
 	}
 	logger.Infof("gRPC server listening on %s", cfg.GrpcPort)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor(logger),
+			actor.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+		),
+	)
 
 	inventorypb.RegisterInventoryServiceServer(grpcServer, inventoryGrpcHandler)
 
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	healthMonitor := healthcheck.NewMonitor(healthServer, []string{inventorypb.InventoryService_ServiceDesc.ServiceName}, []healthcheck.Check{
+		{Name: "postgres", Probe: database.PingContext},
+	}, cfg.HealthCheckInterval, logger)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go healthMonitor.Run(healthCtx)
+	logger.Info("gRPC health service registered")
+
 	reflection.Register(grpcServer)
 	logger.Info("gRPC reflection service registered")
 
+	metricsServer := metrics.Serve(cfg.MetricsPort, logger)
+
 	go func() {
 		logger.Info("Starting gRPC server...")
 		if err := grpcServer.Serve(lis); err != nil && err != grpc.ErrServerStopped {
@@ -78,6 +126,10 @@ func main() {
 		logger.Info("gRPC server stopped serving.")
 	}()
 
+	sweeperCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	go runReservationSweeper(actor.NewContext(sweeperCtx, "system:reservation_sweeper"), reservationUseCase, cfg.ReservationSweepInterval, logger)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	logger.Info("Signal listener started.")
@@ -85,14 +137,42 @@ func main() {
 	<-quit // Block until signal
 	logger.Warn("Shutdown signal received...")
 
+	stopSweeper()
+	stopHealth()
+	healthServer.Shutdown()
+
 	logger.Info("Attempting graceful shutdown of gRPC server...")
 	grpcServer.GracefulStop()
 	logger.Info("gRPC server gracefully stopped.")
 
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelMetrics()
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.Errorf("Error shutting down metrics server: %v", err)
+	}
+
 	logger.Info("Inventory Service shut down gracefully.")
 
 }
 
+func runReservationSweeper(ctx context.Context, uc usecase.ReservationUseCase, interval time.Duration, logger *logrus.Logger) {
+	logger.Infof("Reservation sweeper started (interval: %s)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Reservation sweeper stopped.")
+			return
+		case <-ticker.C:
+			if _, err := uc.ExpireReservations(ctx); err != nil {
+				logger.Errorf("Reservation sweep failed: %v", err)
+			}
+		}
+	}
+}
+
 func setupLogger(level string) *logrus.Logger {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -14,6 +15,18 @@ type Config struct {
 	DatabaseURL string `envconfig:"DATABASE_URL" required:"true"`
 	GrpcPort    string `envconfig:"GRPC_PORT"    default:":50051"` //gPRC port for inventory
 	LogLevel    string `envconfig:"LOG_LEVEL"    default:"info"`
+	MetricsPort string `envconfig:"METRICS_PORT" default:":9091"` // Prometheus /metrics listener
+
+	HealthCheckInterval time.Duration `envconfig:"HEALTH_CHECK_INTERVAL" default:"5s"`
+
+	OrderServiceGrpcAddr string `envconfig:"ORDER_SERVICE_GRPC_ADDR" default:"localhost:50052"` // Asked before a product is split into variants
+
+	ReservationTTL           time.Duration `envconfig:"RESERVATION_TTL"            default:"15m"` // Default hold for ReserveStock
+	ReservationSweepInterval time.Duration `envconfig:"RESERVATION_SWEEP_INTERVAL" default:"1m"`
+
+	TracingExporter     string  `envconfig:"TRACING_EXPORTER"      default:"none"` // none, stdout or otlp
+	TracingOTLPEndpoint string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+	TracingSampleRatio  float64 `envconfig:"TRACING_SAMPLE_RATIO"  default:"1"`
 }
 
 var (
@@ -0,0 +1,42 @@
+package actor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataKey is the gRPC metadata key callers use to say on whose behalf they change stock,
+// e.g. "user:42" from the API gateway or "service:order_service".
+const MetadataKey = "x-actor"
+
+// Unknown is recorded when a caller did not identify itself.
+const Unknown = "unknown"
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying the actor.
+func NewContext(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, actor)
+}
+
+// FromContext returns the actor stored in ctx, or Unknown if there is none.
+func FromContext(ctx context.Context) string {
+	if actor, _ := ctx.Value(ctxKey{}).(string); actor != "" {
+		return actor
+	}
+	return Unknown
+}
+
+// UnaryServerInterceptor stores the actor from the incoming metadata in the request context.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) > 0 && values[0] != "" {
+				ctx = NewContext(ctx, values[0])
+			}
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,89 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"inventory_service/internal/requestid"
+	orderpb "inventory_service/proto/orderpb"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authMetadataKey carries the caller's access token; order_service only answers staff.
+const authMetadataKey = "x-auth-token"
+
+type OrderClient interface {
+	// CountLegacyOrdersHoldingStock counts orders placed without a stock reservation that still
+	// expect to return stock to the product. The caller's token is forwarded from ctx.
+	CountLegacyOrdersHoldingStock(ctx context.Context, productID int) (int, error)
+}
+
+type orderGRPCClient struct {
+	client orderpb.OrderServiceClient
+	log    *logrus.Logger
+	conn   *grpc.ClientConn
+}
+
+// NewOrderGRPCClient does not wait for order_service: it dials inventory_service on startup
+// itself, so a blocking dial here would leave both services waiting for each other.
+func NewOrderGRPCClient(target string, logger *logrus.Logger) (OrderClient, error) {
+	logger.Infof("OrderClient: Dialing gRPC target: %s", target)
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), authUnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		logger.Errorf("OrderClient: Failed to dial %s: %v", target, err)
+		return nil, fmt.Errorf("failed to set up connection to order service at %s: %w", target, err)
+	}
+
+	return &orderGRPCClient{
+		client: orderpb.NewOrderServiceClient(conn),
+		log:    logger,
+		conn:   conn,
+	}, nil
+}
+
+// authUnaryClientInterceptor passes the access token the API gateway sent with the incoming request on to order_service.
+func authUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if tokens := md.Get(authMetadataKey); len(tokens) > 0 && tokens[0] != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, authMetadataKey, tokens[0])
+			}
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func (c *orderGRPCClient) Close() error {
+	if c.conn != nil {
+		c.log.Info("OrderClient: Closing gRPC connection")
+		return c.conn.Close()
+	}
+	return nil
+}
+
+func (c *orderGRPCClient) CountLegacyOrdersHoldingStock(ctx context.Context, productID int) (int, error) {
+	log := requestid.Logger(ctx, c.log)
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := c.client.CountLegacyOrdersHoldingStock(callCtx, &orderpb.CountLegacyOrdersHoldingStockRequest{ProductId: int64(productID)})
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			log.Errorf("OrderClient(gRPC): CountLegacyOrdersHoldingStock failed for product %d with code %s: %s", productID, st.Code(), st.Message())
+			return 0, fmt.Errorf("order service gRPC error (%s): %s", st.Code(), st.Message())
+		}
+		log.Errorf("OrderClient(gRPC): Failed to execute CountLegacyOrdersHoldingStock for product %d: %v", productID, err)
+		return 0, fmt.Errorf("failed to communicate with order service: %w", err)
+	}
+	return int(res.GetCount()), nil
+}
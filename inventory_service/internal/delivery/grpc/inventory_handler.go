@@ -6,26 +6,30 @@ import (
 	"inventory_service/internal/usecase"
 	inventorypb "inventory_service/proto"
 	"strings"
+	"time"
 
 	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type InventoryHandler struct {
 	inventorypb.UnimplementedInventoryServiceServer
-	productUseCase  usecase.ProductUseCase
-	categoryUseCase usecase.CategoryUseCase
-	log             *logrus.Logger
+	productUseCase     usecase.ProductUseCase
+	categoryUseCase    usecase.CategoryUseCase
+	reservationUseCase usecase.ReservationUseCase
+	log                *logrus.Logger
 }
 
-func NewInventoryHandler(puc usecase.ProductUseCase, cuc usecase.CategoryUseCase, logger *logrus.Logger) *InventoryHandler {
+func NewInventoryHandler(puc usecase.ProductUseCase, cuc usecase.CategoryUseCase, ruc usecase.ReservationUseCase, logger *logrus.Logger) *InventoryHandler {
 	return &InventoryHandler{
-		productUseCase:  puc,
-		categoryUseCase: cuc,
-		log:             logger,
+		productUseCase:     puc,
+		categoryUseCase:    cuc,
+		reservationUseCase: ruc,
+		log:                logger,
 	}
 }
 
@@ -34,8 +38,9 @@ func mapDomainCategoryToProto(cat *domain.Category) *inventorypb.Category {
 		return nil
 	}
 	return &inventorypb.Category{
-		Id:   int64(cat.ID),
-		Name: cat.Name,
+		Id:       int64(cat.ID),
+		Name:     cat.Name,
+		ParentId: int64(cat.ParentID),
 	}
 }
 
@@ -49,17 +54,40 @@ func mapDomainProductToProto(prod *domain.Product) *inventorypb.Product {
 		Price:      prod.Price,
 		Stock:      int32(prod.Stock),
 		CategoryId: int64(prod.CategoryID),
+		Variants:   mapDomainVariantsToProto(prod.Variants),
 	}
 }
 
+func mapDomainVariantToProto(variant *domain.ProductVariant) *inventorypb.ProductVariant {
+	return &inventorypb.ProductVariant{
+		Id:         int64(variant.ID),
+		ProductId:  int64(variant.ProductID),
+		Sku:        variant.SKU,
+		Attributes: variant.Attributes,
+		Price:      variant.Price,
+		Stock:      int32(variant.Stock),
+	}
+}
+
+func mapDomainVariantsToProto(variants []domain.ProductVariant) []*inventorypb.ProductVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+	result := make([]*inventorypb.ProductVariant, 0, len(variants))
+	for i := range variants {
+		result = append(result, mapDomainVariantToProto(&variants[i]))
+	}
+	return result
+}
+
 func (h *InventoryHandler) CreateCategory(ctx context.Context, req *inventorypb.CreateCategoryRequest) (*inventorypb.Category, error) {
 	h.log.Infof("gRPC Handler: Received CreateCategory request: Name=%s", req.GetName())
 	if req.GetName() == "" {
 		return nil, status.Error(codes.InvalidArgument, "Category name cannot be empty")
 	}
 
-	domainCat := &domain.Category{Name: req.GetName()}
-	createdCat, err := h.categoryUseCase.CreateCategory(domainCat)
+	domainCat := &domain.Category{Name: req.GetName(), ParentID: int(req.GetParentId())}
+	createdCat, err := h.categoryUseCase.CreateCategory(ctx, domainCat)
 	if err != nil {
 		h.log.Errorf("gRPC Handler: CreateCategory use case error: %v", err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -76,7 +104,7 @@ func (h *InventoryHandler) GetCategory(ctx context.Context, req *inventorypb.Get
 		return nil, status.Error(codes.InvalidArgument, "Invalid category ID")
 	}
 
-	cat, err := h.categoryUseCase.GetCategoryByID(id)
+	cat, err := h.categoryUseCase.GetCategoryByID(ctx, id)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: GetCategory use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -98,8 +126,12 @@ func (h *InventoryHandler) UpdateCategory(ctx context.Context, req *inventorypb.
 		ID:   id,
 		Name: protoCat.GetName(),
 	}
+	reparent := req.GetParentId() != nil
+	if reparent {
+		domainCat.ParentID = int(req.GetParentId().GetValue())
+	}
 
-	updatedCat, err := h.categoryUseCase.UpdateCategory(domainCat)
+	updatedCat, err := h.categoryUseCase.UpdateCategory(ctx, domainCat, reparent)
 	if err != nil {
 		h.log.Errorf("gRPC Handler: UpdateCategory use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -116,7 +148,7 @@ func (h *InventoryHandler) DeleteCategory(ctx context.Context, req *inventorypb.
 		return nil, status.Error(codes.InvalidArgument, "Invalid category ID")
 	}
 
-	err := h.categoryUseCase.DeleteCategory(id)
+	err := h.categoryUseCase.DeleteCategory(ctx, id)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: DeleteCategory use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -127,25 +159,60 @@ func (h *InventoryHandler) DeleteCategory(ctx context.Context, req *inventorypb.
 }
 
 func (h *InventoryHandler) ListCategories(ctx context.Context, req *inventorypb.ListCategoriesRequest) (*inventorypb.ListCategoriesResponse, error) {
-	h.log.Info("gRPC Handler: Received ListCategories request")
+	h.log.Infof("gRPC Handler: Received ListCategories request: PageSize=%d", req.GetPageSize())
 
-	cats, err := h.categoryUseCase.ListCategories()
+	page, err := h.categoryUseCase.ListCategories(ctx, int(req.GetPageSize()), req.GetPageToken())
 	if err != nil {
 		h.log.Errorf("gRPC Handler: ListCategories use case error: %v", err)
-		return nil, status.Errorf(codes.Internal, "Failed to list categories: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
 	}
 
 	resp := &inventorypb.ListCategoriesResponse{
-		Categories: make([]*inventorypb.Category, 0, len(cats)),
+		Categories:    make([]*inventorypb.Category, 0, len(page.Categories)),
+		NextPageToken: page.NextPageToken,
+		TotalSize:     int32(page.TotalSize),
 	}
-	for i := range cats {
-		resp.Categories = append(resp.Categories, mapDomainCategoryToProto(&cats[i]))
+	for i := range page.Categories {
+		resp.Categories = append(resp.Categories, mapDomainCategoryToProto(&page.Categories[i]))
 	}
 
 	h.log.Infof("gRPC Handler: Listed %d categories successfully", len(resp.Categories))
 	return resp, nil
 }
 
+func (h *InventoryHandler) GetCategoryTree(ctx context.Context, req *inventorypb.GetCategoryTreeRequest) (*inventorypb.GetCategoryTreeResponse, error) {
+	h.log.Infof("gRPC Handler: Received GetCategoryTree request: RootID=%d", req.GetRootId())
+
+	roots, err := h.categoryUseCase.GetCategoryTree(ctx, int(req.GetRootId()))
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetCategoryTree use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+	return &inventorypb.GetCategoryTreeResponse{Roots: mapDomainCategoryNodesToProto(roots)}, nil
+}
+
+func (h *InventoryHandler) GetCategoryPath(ctx context.Context, req *inventorypb.GetCategoryPathRequest) (*inventorypb.GetCategoryPathResponse, error) {
+	id := int(req.GetId())
+	h.log.Infof("gRPC Handler: Received GetCategoryPath request: ID=%d", id)
+	if id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid category ID")
+	}
+
+	path, err := h.categoryUseCase.GetCategoryPath(ctx, id)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetCategoryPath use case error for ID %d: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &inventorypb.GetCategoryPathResponse{
+		Categories: make([]*inventorypb.Category, 0, len(path)),
+	}
+	for i := range path {
+		resp.Categories = append(resp.Categories, mapDomainCategoryToProto(&path[i]))
+	}
+	return resp, nil
+}
+
 func (h *InventoryHandler) CreateProduct(ctx context.Context, req *inventorypb.CreateProductRequest) (*inventorypb.Product, error) {
 	h.log.Infof("gRPC Handler: Received CreateProduct request: Name=%s", req.GetName())
 	if req.GetName() == "" || req.GetPrice() <= 0 || req.GetStock() < 0 {
@@ -159,7 +226,7 @@ func (h *InventoryHandler) CreateProduct(ctx context.Context, req *inventorypb.C
 		CategoryID: int(req.GetCategoryId()),
 	}
 
-	createdProd, err := h.productUseCase.CreateProduct(domainProd)
+	createdProd, err := h.productUseCase.CreateProduct(ctx, domainProd)
 	if err != nil {
 		h.log.Errorf("gRPC Handler: CreateProduct use case error: %v", err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -176,7 +243,7 @@ func (h *InventoryHandler) GetProduct(ctx context.Context, req *inventorypb.GetP
 		return nil, status.Error(codes.InvalidArgument, "Invalid product ID")
 	}
 
-	prod, err := h.productUseCase.GetProductByID(id)
+	prod, err := h.productUseCase.GetProductByID(ctx, id)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: GetProduct use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -186,6 +253,64 @@ func (h *InventoryHandler) GetProduct(ctx context.Context, req *inventorypb.GetP
 	return mapDomainProductToProto(prod), nil
 }
 
+func (h *InventoryHandler) SearchProducts(ctx context.Context, req *inventorypb.SearchProductsRequest) (*inventorypb.SearchProductsResponse, error) {
+	h.log.Infof("gRPC Handler: Received SearchProducts request: Query=%q, CategoryID=%d, Sort=%s, Limit=%d, Offset=%d",
+		req.GetQuery(), req.GetCategoryId(), req.GetSort(), req.GetLimit(), req.GetOffset())
+
+	result, err := h.productUseCase.SearchProducts(ctx, domain.ProductSearchFilter{
+		Query:                req.GetQuery(),
+		CategoryID:           int(req.GetCategoryId()),
+		IncludeSubcategories: req.GetIncludeSubcategories(),
+		MinPrice:             req.GetMinPrice(),
+		MaxPrice:             req.GetMaxPrice(),
+		InStockOnly:          req.GetInStockOnly(),
+		Sort:                 domain.ProductSort(req.GetSort()),
+		Limit:                int(req.GetLimit()),
+		Offset:               int(req.GetOffset()),
+	}, req.GetIncludeFacets())
+	if err != nil {
+		h.log.Warnf("gRPC Handler: SearchProducts use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &inventorypb.SearchProductsResponse{
+		Products: make([]*inventorypb.Product, 0, len(result.Products)),
+		Facets:   mapDomainFacetsToProto(result.Facets),
+	}
+	for i := range result.Products {
+		resp.Products = append(resp.Products, mapDomainProductToProto(&result.Products[i]))
+	}
+	h.log.Infof("gRPC Handler: Product search returned %d products", len(resp.Products))
+	return resp, nil
+}
+
+func (h *InventoryHandler) GetProducts(ctx context.Context, req *inventorypb.GetProductsRequest) (*inventorypb.GetProductsResponse, error) {
+	h.log.Infof("gRPC Handler: Received GetProducts request: IDs=%d", len(req.GetIds()))
+
+	ids := make([]int, 0, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		ids = append(ids, int(id))
+	}
+	products, missing, err := h.productUseCase.GetProducts(ctx, ids)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetProducts use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &inventorypb.GetProductsResponse{
+		Products:   make([]*inventorypb.Product, 0, len(products)),
+		MissingIds: make([]int64, 0, len(missing)),
+	}
+	for i := range products {
+		resp.Products = append(resp.Products, mapDomainProductToProto(&products[i]))
+	}
+	for _, id := range missing {
+		resp.MissingIds = append(resp.MissingIds, int64(id))
+	}
+	h.log.Infof("gRPC Handler: Returned %d products, %d missing", len(resp.Products), len(resp.MissingIds))
+	return resp, nil
+}
+
 func (h *InventoryHandler) UpdateProduct(ctx context.Context, req *inventorypb.UpdateProductRequest) (*inventorypb.Product, error) {
 	protoProd := req.GetProduct()
 	mask := req.GetUpdateMask()
@@ -232,14 +357,18 @@ func (h *InventoryHandler) UpdateProduct(ctx context.Context, req *inventorypb.U
 
 	if len(updates) == 0 {
 		h.log.Warnf("gRPC Handler: UpdateProduct request for ID %d resulted in empty valid updates map after processing mask.", id)
-		currentProd, err := h.productUseCase.GetProductByID(id)
+		currentProd, err := h.productUseCase.GetProductByID(ctx, id)
 		if err != nil {
 			return nil, mapDomainErrorToGrpcStatus(err)
 		}
 		return mapDomainProductToProto(currentProd), nil
 	}
 
-	updatedProd, err := h.productUseCase.UpdateProduct(id, updates)
+	change := domain.StockChange{
+		Reason:      domain.StockMovementReason(req.GetStockReason()),
+		ReferenceID: req.GetStockReferenceId(),
+	}
+	updatedProd, err := h.productUseCase.UpdateProduct(ctx, id, updates, change)
 	if err != nil {
 		h.log.Errorf("gRPC Handler: UpdateProduct use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -256,7 +385,7 @@ func (h *InventoryHandler) DeleteProduct(ctx context.Context, req *inventorypb.D
 		return nil, status.Error(codes.InvalidArgument, "Invalid product ID")
 	}
 
-	err := h.productUseCase.DeleteProduct(id)
+	err := h.productUseCase.DeleteProduct(ctx, id)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: DeleteProduct use case error for ID %d: %v", id, err)
 		return nil, mapDomainErrorToGrpcStatus(err)
@@ -266,6 +395,92 @@ func (h *InventoryHandler) DeleteProduct(ctx context.Context, req *inventorypb.D
 	return &emptypb.Empty{}, nil
 }
 
+func (h *InventoryHandler) CreateProductVariant(ctx context.Context, req *inventorypb.CreateProductVariantRequest) (*inventorypb.ProductVariant, error) {
+	h.log.Infof("gRPC Handler: Received CreateProductVariant request: ProductID=%d, SKU=%s", req.GetProductId(), req.GetSku())
+	if req.GetProductId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid product ID")
+	}
+
+	variant := &domain.ProductVariant{
+		ProductID:  int(req.GetProductId()),
+		SKU:        req.GetSku(),
+		Attributes: req.GetAttributes(),
+		Price:      req.GetPrice(),
+		Stock:      int(req.GetStock()),
+	}
+	created, err := h.productUseCase.CreateVariant(ctx, variant)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: CreateProductVariant use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Variant created successfully: ID=%d", created.ID)
+	return mapDomainVariantToProto(created), nil
+}
+
+func (h *InventoryHandler) UpdateProductVariant(ctx context.Context, req *inventorypb.UpdateProductVariantRequest) (*inventorypb.ProductVariant, error) {
+	protoVariant := req.GetVariant()
+	mask := req.GetUpdateMask()
+	if protoVariant == nil || protoVariant.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Valid variant with ID is required for update")
+	}
+	if mask == nil || len(mask.GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Field mask is required for update")
+	}
+
+	id := int(protoVariant.GetId())
+	h.log.Infof("gRPC Handler: Received UpdateProductVariant request: ID=%d, MaskPaths=%v", id, mask.GetPaths())
+
+	updates := make(map[string]interface{})
+	for _, path := range mask.GetPaths() {
+		switch path {
+		case "sku":
+			updates["sku"] = protoVariant.GetSku()
+		case "attributes":
+			attributes := protoVariant.GetAttributes()
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			updates["attributes"] = attributes
+		case "price":
+			updates["price"] = protoVariant.GetPrice()
+		case "stock":
+			updates["stock"] = int(protoVariant.GetStock())
+		default:
+			h.log.Warnf("gRPC Handler: UpdateProductVariant ignoring unknown path in mask: %s", path)
+		}
+	}
+
+	change := domain.StockChange{
+		Reason:      domain.StockMovementReason(req.GetStockReason()),
+		ReferenceID: req.GetStockReferenceId(),
+	}
+	updated, err := h.productUseCase.UpdateVariant(ctx, int(protoVariant.GetProductId()), id, updates, change)
+	if err != nil {
+		h.log.Errorf("gRPC Handler: UpdateProductVariant use case error for ID %d: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Variant updated successfully: ID=%d", updated.ID)
+	return mapDomainVariantToProto(updated), nil
+}
+
+func (h *InventoryHandler) DeleteProductVariant(ctx context.Context, req *inventorypb.DeleteProductVariantRequest) (*empty.Empty, error) {
+	id := int(req.GetId())
+	h.log.Infof("gRPC Handler: Received DeleteProductVariant request: ID=%d", id)
+	if id <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid variant ID")
+	}
+
+	if err := h.productUseCase.DeleteVariant(ctx, int(req.GetProductId()), id); err != nil {
+		h.log.Warnf("gRPC Handler: DeleteProductVariant use case error for ID %d: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Variant deleted successfully: ID=%d", id)
+	return &emptypb.Empty{}, nil
+}
+
 func (h *InventoryHandler) ListProducts(ctx context.Context, req *inventorypb.ListProductsRequest) (*inventorypb.ListProductsResponse, error) {
 	limit := int(req.GetLimit())
 	offset := int(req.GetOffset())
@@ -273,7 +488,7 @@ func (h *InventoryHandler) ListProducts(ctx context.Context, req *inventorypb.Li
 
 	h.log.Infof("gRPC Handler: Received ListProducts request: Limit=%d, Offset=%d, CategoryFilterPresent=%t", limit, offset, categoryIDFilter != nil)
 
-	var products []domain.Product
+	var page *domain.ProductPage
 	var err error
 
 	if categoryIDFilter != nil {
@@ -282,10 +497,10 @@ func (h *InventoryHandler) ListProducts(ctx context.Context, req *inventorypb.Li
 			return nil, status.Error(codes.InvalidArgument, "Invalid category ID filter value")
 		}
 		h.log.Infof("gRPC Handler: Listing products by category: %d", catID)
-		products, err = h.productUseCase.ListProductsByCategory(catID, limit, offset)
+		page, err = h.productUseCase.ListProductsByCategory(ctx, catID, req.GetIncludeSubcategories(), limit, offset, req.GetPageToken())
 	} else {
 		h.log.Info("gRPC Handler: Listing all products")
-		products, err = h.productUseCase.ListProducts(limit, offset)
+		page, err = h.productUseCase.ListProducts(ctx, limit, offset, req.GetPageToken())
 	}
 
 	if err != nil {
@@ -297,16 +512,240 @@ func (h *InventoryHandler) ListProducts(ctx context.Context, req *inventorypb.Li
 	}
 
 	resp := &inventorypb.ListProductsResponse{
-		Products: make([]*inventorypb.Product, 0, len(products)),
-	}
-	for i := range products {
-		resp.Products = append(resp.Products, mapDomainProductToProto(&products[i]))
+		Products:      mapDomainProductsToProto(page.Products),
+		NextPageToken: page.NextPageToken,
+		TotalSize:     int32(page.TotalSize),
 	}
 
 	h.log.Infof("gRPC Handler: Listed %d products successfully", len(resp.Products))
 	return resp, nil
 }
 
+func (h *InventoryHandler) ReserveStock(ctx context.Context, req *inventorypb.ReserveStockRequest) (*inventorypb.ReserveStockResponse, error) {
+	h.log.Infof("gRPC Handler: Received ReserveStock request: Items=%d, TTLSeconds=%d", len(req.GetItems()), req.GetTtlSeconds())
+
+	ttl := time.Duration(req.GetTtlSeconds()) * time.Second
+	reservation, products, err := h.reservationUseCase.ReserveStock(ctx, req.GetReservationId(), mapProtoStockItemsToDomain(req.GetItems()), ttl)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: ReserveStock use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Stock reserved successfully under reservation %s", reservation.ID)
+	return &inventorypb.ReserveStockResponse{
+		Reservation: mapDomainReservationToProto(reservation),
+		Products:    mapDomainProductsToProto(products),
+	}, nil
+}
+
+func (h *InventoryHandler) ReleaseStock(ctx context.Context, req *inventorypb.ReleaseStockRequest) (*inventorypb.StockAdjustmentResponse, error) {
+	h.log.Infof("gRPC Handler: Received ReleaseStock request: Items=%d", len(req.GetItems()))
+
+	products, err := h.productUseCase.ReleaseStock(ctx, mapProtoStockItemsToDomain(req.GetItems()), req.GetIdempotencyKey())
+	if err != nil {
+		h.log.Errorf("gRPC Handler: ReleaseStock use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Stock released successfully for %d products", len(products))
+	return &inventorypb.StockAdjustmentResponse{Products: mapDomainProductsToProto(products)}, nil
+}
+
+func (h *InventoryHandler) ConfirmReservation(ctx context.Context, req *inventorypb.ConfirmReservationRequest) (*inventorypb.Reservation, error) {
+	id := req.GetReservationId()
+	h.log.Infof("gRPC Handler: Received ConfirmReservation request: ID=%s", id)
+
+	reservation, err := h.reservationUseCase.ConfirmReservation(ctx, id)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: ConfirmReservation use case error for ID %s: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Reservation confirmed successfully: ID=%s", id)
+	return mapDomainReservationToProto(reservation), nil
+}
+
+func (h *InventoryHandler) FulfillReservation(ctx context.Context, req *inventorypb.FulfillReservationRequest) (*inventorypb.Reservation, error) {
+	id := req.GetReservationId()
+	h.log.Infof("gRPC Handler: Received FulfillReservation request: ID=%s", id)
+
+	reservation, err := h.reservationUseCase.FulfillReservation(ctx, id)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: FulfillReservation use case error for ID %s: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Reservation fulfilled successfully: ID=%s", id)
+	return mapDomainReservationToProto(reservation), nil
+}
+
+func (h *InventoryHandler) CancelReservation(ctx context.Context, req *inventorypb.CancelReservationRequest) (*inventorypb.Reservation, error) {
+	id := req.GetReservationId()
+	h.log.Infof("gRPC Handler: Received CancelReservation request: ID=%s", id)
+
+	reservation, err := h.reservationUseCase.CancelReservation(ctx, id)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: CancelReservation use case error for ID %s: %v", id, err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Reservation %s is %s", id, reservation.Status)
+	return mapDomainReservationToProto(reservation), nil
+}
+
+func (h *InventoryHandler) GetReservations(ctx context.Context, req *inventorypb.GetReservationsRequest) (*inventorypb.GetReservationsResponse, error) {
+	h.log.Infof("gRPC Handler: Received GetReservations request: IDs=%d", len(req.GetReservationIds()))
+
+	reservations, err := h.reservationUseCase.GetReservations(ctx, req.GetReservationIds())
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetReservations use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &inventorypb.GetReservationsResponse{
+		Reservations: make([]*inventorypb.Reservation, 0, len(reservations)),
+	}
+	for i := range reservations {
+		resp.Reservations = append(resp.Reservations, mapDomainReservationToProto(&reservations[i]))
+	}
+	h.log.Infof("gRPC Handler: Returned %d reservations", len(resp.Reservations))
+	return resp, nil
+}
+
+func (h *InventoryHandler) GetAppliedReleases(ctx context.Context, req *inventorypb.GetAppliedReleasesRequest) (*inventorypb.GetAppliedReleasesResponse, error) {
+	h.log.Infof("gRPC Handler: Received GetAppliedReleases request: Keys=%d", len(req.GetIdempotencyKeys()))
+
+	applied, err := h.productUseCase.GetAppliedReleases(ctx, req.GetIdempotencyKeys())
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetAppliedReleases use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+	h.log.Infof("gRPC Handler: %d of %d stock releases were applied", len(applied), len(req.GetIdempotencyKeys()))
+	return &inventorypb.GetAppliedReleasesResponse{IdempotencyKeys: applied}, nil
+}
+
+func (h *InventoryHandler) ListStockMovements(ctx context.Context, req *inventorypb.ListStockMovementsRequest) (*inventorypb.ListStockMovementsResponse, error) {
+	h.log.Infof("gRPC Handler: Received ListStockMovements request: ProductID=%d, Reason=%s, ReferenceID=%s, Limit=%d, Offset=%d",
+		req.GetProductId(), req.GetReason(), req.GetReferenceId(), req.GetLimit(), req.GetOffset())
+
+	filter := domain.StockMovementFilter{
+		ProductID:   int(req.GetProductId()),
+		Reason:      domain.StockMovementReason(req.GetReason()),
+		ReferenceID: req.GetReferenceId(),
+		Actor:       req.GetActor(),
+		Limit:       int(req.GetLimit()),
+		Offset:      int(req.GetOffset()),
+	}
+	if req.GetFrom() != nil {
+		filter.From = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		filter.To = req.GetTo().AsTime()
+	}
+
+	movements, err := h.productUseCase.ListStockMovements(ctx, filter)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: ListStockMovements use case error: %v", err)
+		return nil, mapDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &inventorypb.ListStockMovementsResponse{
+		Movements: make([]*inventorypb.StockMovement, 0, len(movements)),
+	}
+	for _, m := range movements {
+		resp.Movements = append(resp.Movements, &inventorypb.StockMovement{
+			Id:            m.ID,
+			ProductId:     int64(m.ProductID),
+			VariantId:     int64(m.VariantID),
+			Delta:         int32(m.Delta),
+			QuantityAfter: int32(m.QuantityAfter),
+			Reason:        string(m.Reason),
+			ReferenceId:   m.ReferenceID,
+			Actor:         m.Actor,
+			CreatedAt:     timestamppb.New(m.CreatedAt),
+		})
+	}
+	h.log.Infof("gRPC Handler: Listed %d stock movements", len(resp.Movements))
+	return resp, nil
+}
+
+func mapDomainReservationToProto(reservation *domain.Reservation) *inventorypb.Reservation {
+	items := make([]*inventorypb.StockItem, 0, len(reservation.Items))
+	for _, item := range reservation.Items {
+		items = append(items, &inventorypb.StockItem{
+			ProductId: int64(item.ProductID),
+			VariantId: int64(item.VariantID),
+			Quantity:  int32(item.Quantity),
+		})
+	}
+	return &inventorypb.Reservation{
+		Id:        reservation.ID,
+		Status:    string(reservation.Status),
+		Items:     items,
+		ExpiresAt: timestamppb.New(reservation.ExpiresAt),
+		CreatedAt: timestamppb.New(reservation.CreatedAt),
+		UpdatedAt: timestamppb.New(reservation.UpdatedAt),
+	}
+}
+
+func mapProtoStockItemsToDomain(items []*inventorypb.StockItem) []domain.StockItem {
+	result := make([]domain.StockItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, domain.StockItem{
+			ProductID: int(item.GetProductId()),
+			VariantID: int(item.GetVariantId()),
+			Quantity:  int(item.GetQuantity()),
+		})
+	}
+	return result
+}
+
+func mapDomainCategoryNodesToProto(nodes []*domain.CategoryNode) []*inventorypb.CategoryTreeNode {
+	result := make([]*inventorypb.CategoryTreeNode, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, &inventorypb.CategoryTreeNode{
+			Category: mapDomainCategoryToProto(&node.Category),
+			Children: mapDomainCategoryNodesToProto(node.Children),
+		})
+	}
+	return result
+}
+
+func mapDomainProductsToProto(products []domain.Product) []*inventorypb.Product {
+	result := make([]*inventorypb.Product, 0, len(products))
+	for i := range products {
+		result = append(result, mapDomainProductToProto(&products[i]))
+	}
+	return result
+}
+
+func mapDomainFacetsToProto(facets *domain.ProductFacets) *inventorypb.ProductFacets {
+	if facets == nil {
+		return nil
+	}
+	result := &inventorypb.ProductFacets{
+		Categories:   make([]*inventorypb.CategoryFacet, 0, len(facets.Categories)),
+		PriceBuckets: make([]*inventorypb.PriceBucketFacet, 0, len(facets.PriceBuckets)),
+		InStock:      int32(facets.InStock),
+		OutOfStock:   int32(facets.OutOfStock),
+	}
+	for _, category := range facets.Categories {
+		result.Categories = append(result.Categories, &inventorypb.CategoryFacet{
+			CategoryId: int64(category.CategoryID),
+			Name:       category.Name,
+			Count:      int32(category.Count),
+		})
+	}
+	for _, bucket := range facets.PriceBuckets {
+		result.PriceBuckets = append(result.PriceBuckets, &inventorypb.PriceBucketFacet{
+			MinPrice: bucket.Min,
+			MaxPrice: bucket.Max,
+			Count:    int32(bucket.Count),
+		})
+	}
+	return result
+}
+
 func mapDomainErrorToGrpcStatus(err error) error {
 	if err == nil {
 		return nil
@@ -320,6 +759,16 @@ func mapDomainErrorToGrpcStatus(err error) error {
 		strings.Contains(errMsg, "duplicate key"),
 		strings.Contains(errMsg, "unique constraint"):
 		return status.Error(codes.AlreadyExists, err.Error())
+	case strings.Contains(errMsg, "insufficient stock"),
+		strings.Contains(errMsg, "cannot confirm reservation"),
+		strings.Contains(errMsg, "cannot fulfill reservation"),
+		strings.Contains(errMsg, "cannot cancel reservation"),
+		strings.Contains(errMsg, "cannot release stock"),
+		strings.Contains(errMsg, "cannot add variants"),
+		strings.Contains(errMsg, "cannot delete variant"),
+		strings.Contains(errMsg, "cannot delete product"),
+		strings.Contains(errMsg, "still has subcategories"):
+		return status.Error(codes.FailedPrecondition, err.Error())
 	case strings.Contains(errMsg, "invalid"),
 		strings.Contains(errMsg, "cannot be empty"),
 		strings.Contains(errMsg, "must be positive"),
@@ -1,9 +1,33 @@
 package domain
 
+import "context"
+
 type CategoryRepository interface {
-	CreateCategory(category *Category) (*Category, error)
-	GetCategoryByID(id int) (*Category, error)
-	UpdateCategory(category *Category) (*Category, error)
-	DeleteCategory(id int) error
-	ListCategories() ([]Category, error)
+	CreateCategory(ctx context.Context, category *Category) (*Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*Category, error)
+	// UpdateCategory renames the category and, with reparent set, moves it under category.ParentID
+	// (0 = top level). A move that would make the category its own ancestor fails.
+	UpdateCategory(ctx context.Context, category *Category, reparent bool) (*Category, error)
+	// DeleteCategory fails while the category still has subcategories.
+	DeleteCategory(ctx context.Context, id int) error
+	// ListCategories pages through the categories in ID order; pageToken is the NextPageToken of the previous page.
+	ListCategories(ctx context.Context, limit int, pageToken string) (*CategoryPage, error)
+	// GetCategorySubtree returns the category rootID and all its descendants sorted by name, or
+	// every category if rootID is 0.
+	GetCategorySubtree(ctx context.Context, rootID int) ([]Category, error)
+	// GetCategoryPath returns the ancestors of a category from the top level down, ending with the category itself.
+	GetCategoryPath(ctx context.Context, id int) ([]Category, error)
+}
+
+// CategoryNode is a category with its subcategories, sorted by name.
+type CategoryNode struct {
+	Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryPage is one page of the category list. NextPageToken is empty on the last page.
+type CategoryPage struct {
+	Categories    []Category
+	NextPageToken string
+	TotalSize     int
 }
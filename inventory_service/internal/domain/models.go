@@ -6,9 +6,39 @@ type Product struct {
 	Price      float64 `json:"price"`
 	Stock      int     `json:"stock"`
 	CategoryID int     `json:"category_id"`
+	// Variants is only loaded for single products and by-ID lookups. A product with variants
+	// sells only through them and its Stock is the sum of theirs.
+	Variants []ProductVariant `json:"variants,omitempty"`
+}
+
+// ProductVariant is one sellable version of a product, such as a size and colour of a shirt.
+type ProductVariant struct {
+	ID         int               `json:"id"`
+	ProductID  int               `json:"product_id"`
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      float64           `json:"price"` // 0 sells at the product's price
+	Stock      int               `json:"stock"`
+}
+
+// EffectivePrice is what the variant sells for given its product's price.
+func (v ProductVariant) EffectivePrice(productPrice float64) float64 {
+	if v.Price > 0 {
+		return v.Price
+	}
+	return productPrice
+}
+
+// StockItem is a quantity of a product to reserve or release. VariantID is required for
+// products sold in variants and 0 otherwise.
+type StockItem struct {
+	ProductID int `json:"product_id"`
+	VariantID int `json:"variant_id,omitempty"`
+	Quantity  int `json:"quantity"`
 }
 
 type Category struct {
-	ID   int    `json:"id"`   // Category id
-	Name string `json:"name"` // Category nma
+	ID       int    `json:"id"`        // Category id
+	Name     string `json:"name"`      // Category nma
+	ParentID int    `json:"parent_id"` // 0 for a top-level category (department)
 }
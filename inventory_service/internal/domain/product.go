@@ -1,12 +1,52 @@
 package domain
 
+import "context"
+
 type ProductRepository interface {
-	CreateProduct(product *Product) (*Product, error)
-	GetProductByID(id int) (*Product, error)
+	CreateProduct(ctx context.Context, product *Product) (*Product, error)
+	GetProductByID(ctx context.Context, id int) (*Product, error)
+	// GetProductsByIDs returns the products with the given IDs ordered by ID; unknown IDs are left out.
+	GetProductsByIDs(ctx context.Context, ids []int) ([]Product, error)
+
+	// UpdateProduct records a stock movement described by change when updates contains "stock".
+	UpdateProduct(ctx context.Context, id int, updates map[string]interface{}, change StockChange) (*Product, error)
+
+	// DeleteProduct removes a product with its variants. Products held by pending or confirmed (not
+	// yet fulfilled) reservations cannot be deleted.
+	DeleteProduct(ctx context.Context, id int) error
+	// ListProducts pages through all products in ID order. pageToken is the NextPageToken of the
+	// previous page; offset is only applied to the first page.
+	ListProducts(ctx context.Context, limit, offset int, pageToken string) (*ProductPage, error)
+	// ListProductsByCategory lists the products of one category or, with includeSubcategories, of
+	// the category and all its descendants.
+	ListProductsByCategory(ctx context.Context, categoryID int, includeSubcategories bool, limit, offset int, pageToken string) (*ProductPage, error)
+	SearchProducts(ctx context.Context, filter ProductSearchFilter) ([]Product, error)
+	// SearchProductFacets counts the products matching filter by category, price bucket and
+	// availability; the filter's sort and paging are ignored.
+	SearchProductFacets(ctx context.Context, filter ProductSearchFilter) (*ProductFacets, error)
 
-	UpdateProduct(id int, updates map[string]interface{}) (*Product, error)
+	// CreateVariant adds a variant to a product. The first variant can only be added while the
+	// product's own stock is 0; the variant's initial stock is recorded in the ledger.
+	CreateVariant(ctx context.Context, variant *ProductVariant) (*ProductVariant, error)
+	GetVariantByID(ctx context.Context, id int) (*ProductVariant, error)
+	// UpdateVariant applies updates ("sku", "attributes", "price", "stock"), recording a stock
+	// movement described by change when updates contains "stock". A price of 0 clears the override.
+	UpdateVariant(ctx context.Context, id int, updates map[string]interface{}, change StockChange) (*ProductVariant, error)
+	// DeleteVariant removes a variant together with its stock, which is recorded in the ledger. Variants
+	// held by pending or confirmed (not yet fulfilled) reservations cannot be deleted.
+	DeleteVariant(ctx context.Context, id int) error
+
+	// ReleaseStock returns stock for all items in one transaction, all-or-nothing.
+	// A non-empty idempotencyKey that was already used makes the call a no-op.
+	ReleaseStock(ctx context.Context, items []StockItem, idempotencyKey string) ([]Product, error)
+	// AppliedReleases returns the given idempotency keys under which a release was applied.
+	AppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error)
+}
 
-	DeleteProduct(id int) error
-	ListProducts(limit, offset int) ([]Product, error)
-	ListProductsByCategory(categoryID, limit, offset int) ([]Product, error)
+// ProductPage is one page of a product listing. NextPageToken is empty on the last page and
+// TotalSize counts the products of the whole listing.
+type ProductPage struct {
+	Products      []Product
+	NextPageToken string
+	TotalSize     int
 }
@@ -0,0 +1,68 @@
+package domain
+
+// ProductSort is an ordering of product search results; ties are broken by product ID.
+type ProductSort string
+
+const (
+	SortRelevance ProductSort = "relevance" // Best text match first; needs a query
+	SortNewest    ProductSort = "newest"
+	SortPriceAsc  ProductSort = "price_asc"
+	SortPriceDesc ProductSort = "price_desc"
+	SortName      ProductSort = "name"
+)
+
+func IsValidProductSort(sort ProductSort) bool {
+	switch sort {
+	case SortRelevance, SortNewest, SortPriceAsc, SortPriceDesc, SortName:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProductSearchFilter narrows a product search. Zero values leave a filter out.
+type ProductSearchFilter struct {
+	Query                string // Full-text search on the name; every word must match the start of a word in it
+	CategoryID           int
+	IncludeSubcategories bool    // Widens CategoryID to all its descendant categories
+	MinPrice             float64 // A product sold in variants matches if any variant is priced within MinPrice and MaxPrice
+	MaxPrice             float64
+	InStockOnly          bool
+	Sort                 ProductSort
+	Limit                int
+	Offset               int
+}
+
+// PriceBucketEdges split the price facet into buckets: below the first edge, between each pair
+// of edges, and from the last edge up.
+var PriceBucketEdges = []float64{10, 25, 50, 100, 250, 500, 1000}
+
+// CategoryFacet counts the matching products in one category; CategoryID 0 means no category.
+type CategoryFacet struct {
+	CategoryID int    `json:"category_id"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+// PriceBucket counts the matching products priced from Min (inclusive) up to Max (exclusive);
+// a Max of 0 means no upper bound. A product sold in variants counts in every bucket one of its
+// variants is priced in.
+type PriceBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// ProductFacets summarises all products that match a search, not just the returned page.
+type ProductFacets struct {
+	Categories   []CategoryFacet `json:"categories"` // Largest first
+	PriceBuckets []PriceBucket   `json:"price_buckets"`
+	InStock      int             `json:"in_stock"`
+	OutOfStock   int             `json:"out_of_stock"`
+}
+
+// ProductSearchResult is a page of search hits, with facets if they were asked for.
+type ProductSearchResult struct {
+	Products []Product
+	Facets   *ProductFacets
+}
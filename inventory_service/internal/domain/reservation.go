@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationFulfilled ReservationStatus = "fulfilled"
+	ReservationCancelled ReservationStatus = "cancelled"
+	ReservationExpired   ReservationStatus = "expired"
+)
+
+// Reservation holds stock for a pending order until it is confirmed, cancelled or expires. A confirmed
+// reservation keeps holding the stock until its order ships, which marks it fulfilled.
+type Reservation struct {
+	ID        string            `json:"id"`
+	Status    ReservationStatus `json:"status"`
+	Items     []StockItem       `json:"items"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type ReservationRepository interface {
+	// CreateReservation decrements stock for every item and stores the reservation in one transaction.
+	CreateReservation(ctx context.Context, reservation *Reservation) (*Reservation, []Product, error)
+	ConfirmReservation(ctx context.Context, id string) (*Reservation, error)
+	// FulfillReservation marks a confirmed reservation as shipped; its stock is gone for good and can
+	// no longer be returned. Fulfilling an already fulfilled reservation is a no-op.
+	FulfillReservation(ctx context.Context, id string) (*Reservation, error)
+	// CancelReservation returns the held or confirmed stock; cancelling an already cancelled or expired reservation is a no-op.
+	CancelReservation(ctx context.Context, id string) (*Reservation, error)
+	// GetReservations returns the reservations with the given IDs; unknown IDs are left out.
+	GetReservations(ctx context.Context, ids []string) ([]Reservation, error)
+	// ExpireReservations returns the stock of up to limit pending reservations whose TTL has passed.
+	ExpireReservations(ctx context.Context, limit int) (int, error)
+}
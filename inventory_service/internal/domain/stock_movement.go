@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type StockMovementReason string
+
+const (
+	ReasonInitialStock       StockMovementReason = "initial_stock"
+	ReasonRestock            StockMovementReason = "restock"
+	ReasonManualAdjust       StockMovementReason = "manual_adjust"
+	ReasonOrderReserve       StockMovementReason = "order_reserve"
+	ReasonOrderCancel        StockMovementReason = "order_cancel"
+	ReasonReservationExpired StockMovementReason = "reservation_expired"
+)
+
+func IsValidStockMovementReason(reason StockMovementReason) bool {
+	switch reason {
+	case ReasonInitialStock, ReasonRestock, ReasonManualAdjust, ReasonOrderReserve, ReasonOrderCancel, ReasonReservationExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// StockMovement is one entry of the append-only stock ledger.
+type StockMovement struct {
+	ID            int64               `json:"id"`
+	ProductID     int                 `json:"product_id"`
+	VariantID     int                 `json:"variant_id,omitempty"` // Set for stock of a variant; QuantityAfter is then the variant's stock
+	Delta         int                 `json:"delta"`
+	QuantityAfter int                 `json:"quantity_after"`
+	Reason        StockMovementReason `json:"reason"`
+	ReferenceID   string              `json:"reference_id,omitempty"`
+	Actor         string              `json:"actor"`
+	CreatedAt     time.Time           `json:"created_at"`
+}
+
+// StockChange says why a stock write happens; it is recorded with the resulting movements.
+type StockChange struct {
+	Reason      StockMovementReason
+	ReferenceID string
+}
+
+// StockMovementFilter narrows ListStockMovements; zero values do not filter.
+type StockMovementFilter struct {
+	ProductID   int
+	Reason      StockMovementReason
+	ReferenceID string
+	Actor       string
+	From        time.Time // Inclusive
+	To          time.Time // Exclusive
+	Limit       int
+	Offset      int
+}
+
+type StockMovementRepository interface {
+	// ListStockMovements returns matching movements, newest first.
+	ListStockMovements(ctx context.Context, filter StockMovementFilter) ([]StockMovement, error)
+}
@@ -0,0 +1,79 @@
+package healthcheck
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// checkTimeout bounds a single dependency check so a hung dependency is reported as down.
+const checkTimeout = 2 * time.Second
+
+// Check probes one dependency; a non-nil error marks the service NOT_SERVING.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Monitor periodically runs the dependency checks and publishes the aggregate result
+// on the standard grpc.health.v1 server, both for the overall ("") and the named services.
+type Monitor struct {
+	server   *health.Server
+	services []string
+	checks   []Check
+	interval time.Duration
+	log      *logrus.Logger
+}
+
+func NewMonitor(server *health.Server, services []string, checks []Check, interval time.Duration, logger *logrus.Logger) *Monitor {
+	return &Monitor{
+		server:   server,
+		services: append([]string{""}, services...),
+		checks:   checks,
+		interval: interval,
+		log:      logger,
+	}
+}
+
+// Run checks the dependencies immediately and then on every tick until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	m.log.Infof("Health monitor started (interval: %s)", m.interval)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	var last healthpb.HealthCheckResponse_ServingStatus
+	for {
+		current := m.probe(ctx)
+		if current != last {
+			m.log.Infof("Health status changed: %s -> %s", last, current)
+			last = current
+		}
+		for _, service := range m.services {
+			m.server.SetServingStatus(service, current)
+		}
+
+		select {
+		case <-ctx.Done():
+			m.log.Info("Health monitor stopped.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) probe(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	status := healthpb.HealthCheckResponse_SERVING
+	for _, check := range m.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := check.Probe(checkCtx)
+		cancel()
+		if err != nil {
+			m.log.Warnf("Health check '%s' failed: %v", check.Name, err)
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+	}
+	return status
+}
@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 
 	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
 
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
@@ -23,103 +26,263 @@ func NewPostgresCategoryRepository(db *sql.DB, logger *logrus.Logger) domain.Cat
 	}
 }
 
-func (r *postgresCategoryRepository) CreateCategory(category *domain.Category) (*domain.Category, error) {
-	query := `INSERT INTO categories (name) VALUES ($1) returning id`
-	err := r.db.QueryRow(query, category.Name).Scan(&category.ID)
+func (r *postgresCategoryRepository) CreateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.CreateCategory")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `INSERT INTO categories (name, parent_id) VALUES ($1, NULLIF($2, 0)) returning id`
+	err := r.db.QueryRowContext(ctx, query, category.Name, category.ParentID).Scan(&category.ID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			r.log.Warnf("Attempted to create category with duplicate name: %s", category.Name)
+			log.Warnf("Attempted to create category with duplicate name: %s", category.Name)
 			return nil, fmt.Errorf("category with name '%s' already exists", category.Name)
 		}
-		r.log.Errorf("Failed to create category '%s': %v", category.Name, err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			log.Warnf("Attempted to create category '%s' under missing parent %d", category.Name, category.ParentID)
+			return nil, fmt.Errorf("invalid parent category: category %d does not exist", category.ParentID)
+		}
+		log.Errorf("Failed to create category '%s': %v", category.Name, err)
 		return nil, fmt.Errorf("could not create category: %w", err)
 	}
-	r.log.Infof("Category created successfully with ID: %d, Name: %s", category.ID, category.Name)
+	log.Infof("Category created successfully with ID: %d, Name: %s", category.ID, category.Name)
 	return category, nil
 }
 
-func (r *postgresCategoryRepository) GetCategoryByID(id int) (*domain.Category, error) {
-	query := `SELECT id, name FROM categories WHERE id = $1`
+func (r *postgresCategoryRepository) GetCategoryByID(ctx context.Context, id int) (*domain.Category, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.GetCategoryByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `SELECT id, name, COALESCE(parent_id, 0) FROM categories WHERE id = $1`
 	category := &domain.Category{}
-	err := r.db.QueryRow(query, id).Scan(&category.ID, &category.Name)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&category.ID, &category.Name, &category.ParentID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Category with ID %d not found", id)
+			log.Warnf("Category with ID %d not found", id)
 			return nil, fmt.Errorf("category with id %d not found", id)
 		}
-		r.log.Errorf("Failed to get category by ID %d: %v", id, err)
+		log.Errorf("Failed to get category by ID %d: %v", id, err)
 		return nil, fmt.Errorf("could not get category by id: %w", err)
 	}
-	r.log.Infof("Category retrieved successfully with ID: %d", id)
+	log.Infof("Category retrieved successfully with ID: %d", id)
 	return category, nil
 }
 
-func (r *postgresCategoryRepository) UpdateCategory(category *domain.Category) (*domain.Category, error) {
-	query := `UPDATE categories SET name = $1 WHERE id = $2 RETURNING id, name`
-	err := r.db.QueryRow(query, category.Name, category.ID).Scan(&category.ID, &category.Name)
+func (r *postgresCategoryRepository) UpdateCategory(ctx context.Context, category *domain.Category, reparent bool) (*domain.Category, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.UpdateCategory")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		query := `UPDATE categories SET name = $1 WHERE id = $2 RETURNING id, name, COALESCE(parent_id, 0)`
+		args := []interface{}{category.Name, category.ID}
+		if reparent {
+			if err := checkCategoryMoveTx(ctx, tx, category.ID, category.ParentID); err != nil {
+				return err
+			}
+			query = `UPDATE categories SET name = $1, parent_id = NULLIF($3, 0) WHERE id = $2 RETURNING id, name, COALESCE(parent_id, 0)`
+			args = append(args, category.ParentID)
+		}
+		return tx.QueryRowContext(ctx, query, args...).Scan(&category.ID, &category.Name, &category.ParentID)
+	})
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			r.log.Warnf("Attempted to update category ID %d with duplicate name: %s", category.ID, category.Name)
+			log.Warnf("Attempted to update category ID %d with duplicate name: %s", category.ID, category.Name)
 			return nil, fmt.Errorf("category with name '%s' already exists", category.Name)
 		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			log.Warnf("Attempted to move category ID %d under missing parent %d", category.ID, category.ParentID)
+			return nil, fmt.Errorf("invalid parent category: category %d does not exist", category.ParentID)
+		}
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Category with ID %d not found for update", category.ID)
+			log.Warnf("Category with ID %d not found for update", category.ID)
 			return nil, fmt.Errorf("category with id %d not found for update", category.ID)
 		}
-		r.log.Errorf("Failed to update category ID %d: %v", category.ID, err)
+		log.Errorf("Failed to update category ID %d: %v", category.ID, err)
 		return nil, fmt.Errorf("could not update category: %w", err)
 	}
-	r.log.Infof("Category updated successfully with ID: %d", category.ID)
+	log.Infof("Category updated successfully with ID: %d (parent: %d)", category.ID, category.ParentID)
 	return category, nil
 }
 
-func (r *postgresCategoryRepository) DeleteCategory(id int) error {
+// checkCategoryMoveTx rejects moving a category under one of its own descendants. Moves are
+// serialised by a transaction-level advisory lock, because two concurrent moves (A under B and
+// B under A) would each pass the check on their own and together close a cycle.
+func checkCategoryMoveTx(ctx context.Context, tx *sql.Tx, id, parentID int) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('categories.parent_id'))`); err != nil {
+		return fmt.Errorf("could not lock category tree: %w", err)
+	}
+	if parentID == 0 {
+		return nil
+	}
+
+	var inside bool
+	query := categorySubtreeCTE("$1") + ` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`
+	if err := tx.QueryRowContext(ctx, query, id, parentID).Scan(&inside); err != nil {
+		return fmt.Errorf("could not check category tree: %w", err)
+	}
+	if inside {
+		return fmt.Errorf("invalid parent category: category %d is inside category %d", parentID, id)
+	}
+	return nil
+}
+
+func (r *postgresCategoryRepository) DeleteCategory(ctx context.Context, id int) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.DeleteCategory")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `DELETE FROM categories WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
-		r.log.Errorf("Failed to delete category ID %d: %v", id, err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			log.Warnf("Attempted to delete category ID %d that has subcategories", id)
+			return fmt.Errorf("cannot delete category %d: it still has subcategories", id)
+		}
+		log.Errorf("Failed to delete category ID %d: %v", id, err)
 		return fmt.Errorf("could not delete category: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		r.log.Errorf("Failed to get rows affected after deleting category ID %d: %v", id, err)
+		log.Errorf("Failed to get rows affected after deleting category ID %d: %v", id, err)
 		return fmt.Errorf("could not confirm category deletion: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		r.log.Warnf("Attempted to delete non-existent category ID %d", id)
+		log.Warnf("Attempted to delete non-existent category ID %d", id)
 		return fmt.Errorf("category with id %d not found for deletion", id)
 	}
 
-	r.log.Infof("Category deleted successfully with ID: %d", id)
+	log.Infof("Category deleted successfully with ID: %d", id)
 	return nil
 }
 
-func (r *postgresCategoryRepository) ListCategories() ([]domain.Category, error) {
-	query := `SELECT id, name FROM categories ORDER BY id ASC`
-	rows, err := r.db.Query(query)
+func (r *postgresCategoryRepository) ListCategories(ctx context.Context, limit int, pageToken string) (*domain.CategoryPage, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.ListCategories")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	afterID, err := decodePageToken(pageToken, "categories")
 	if err != nil {
-		r.log.Errorf("Failed to list categories: %v", err)
+		return nil, err
+	}
+
+	page := &domain.CategoryPage{Categories: []domain.Category{}}
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&page.TotalSize); err != nil {
+		log.Errorf("Failed to count categories: %v", err)
+		return nil, fmt.Errorf("could not count categories: %w", err)
+	}
+
+	// One extra row tells whether there is a next page
+	query := `SELECT id, name, COALESCE(parent_id, 0) FROM categories WHERE id > $1 ORDER BY id ASC LIMIT $2`
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit+1)
+	if err != nil {
+		log.Errorf("Failed to list categories: %v", err)
 		return nil, fmt.Errorf("could not list categories: %w", err)
 	}
 	defer rows.Close()
 
-	categories := []domain.Category{}
 	for rows.Next() {
 		var category domain.Category
-		if err := rows.Scan(&category.ID, &category.Name); err != nil {
-			r.log.Errorf("Failed to scan category row: %v", err)
+		if err := rows.Scan(&category.ID, &category.Name, &category.ParentID); err != nil {
+			log.Errorf("Failed to scan category row: %v", err)
 			continue
 		}
-		categories = append(categories, category)
+		page.Categories = append(page.Categories, category)
 	}
 
 	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during categories list iteration: %v", err)
+		log.Errorf("Error during categories list iteration: %v", err)
 		return nil, fmt.Errorf("error iterating categories: %w", err)
 	}
 
-	r.log.Infof("Retrieved %d categories", len(categories))
+	if len(page.Categories) > limit {
+		page.Categories = page.Categories[:limit]
+		page.NextPageToken = keysetToken{Scope: "categories", AfterID: page.Categories[limit-1].ID}.encode()
+	}
+	log.Infof("Retrieved %d of %d categories", len(page.Categories), page.TotalSize)
+	return page, nil
+}
+
+// categorySubtreeCTE names the IDs of the category rootParam and all its descendants "subtree".
+// UNION rather than UNION ALL ends the recursion even on a cycle.
+func categorySubtreeCTE(rootParam string) string {
+	return `
+        WITH RECURSIVE subtree(id) AS (
+            SELECT id FROM categories WHERE id = ` + rootParam + `
+            UNION
+            SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+        )`
+}
+
+func (r *postgresCategoryRepository) GetCategorySubtree(ctx context.Context, rootID int) ([]domain.Category, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.GetCategorySubtree")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	query := `SELECT id, name, COALESCE(parent_id, 0) FROM categories ORDER BY name, id`
+	var args []interface{}
+	if rootID > 0 {
+		query = categorySubtreeCTE("$1") + `
+        SELECT c.id, c.name, COALESCE(c.parent_id, 0)
+        FROM categories c JOIN subtree USING (id)
+        ORDER BY c.name, c.id`
+		args = append(args, rootID)
+	}
+	categories, err := r.queryCategories(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Failed to load category subtree of %d: %v", rootID, err)
+		return nil, err
+	}
+	if rootID > 0 && len(categories) == 0 {
+		return nil, fmt.Errorf("category with id %d not found", rootID)
+	}
+	log.Infof("Retrieved %d categories in subtree of %d", len(categories), rootID)
+	return categories, nil
+}
+
+func (r *postgresCategoryRepository) GetCategoryPath(ctx context.Context, id int) ([]domain.Category, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "CategoryRepository.GetCategoryPath")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	// Walks up through the parents; the depth bound ends the walk even on a cycle
+	query := `
+        WITH RECURSIVE path(id, name, parent_id, depth) AS (
+            SELECT id, name, parent_id, 0 FROM categories WHERE id = $1
+            UNION ALL
+            SELECT c.id, c.name, c.parent_id, p.depth + 1
+            FROM categories c JOIN path p ON c.id = p.parent_id
+            WHERE p.depth < 100
+        )
+        SELECT id, name, COALESCE(parent_id, 0) FROM path ORDER BY depth DESC`
+	path, err := r.queryCategories(ctx, query, id)
+	if err != nil {
+		log.Errorf("Failed to load path of category %d: %v", id, err)
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("category with id %d not found", id)
+	}
+	return path, nil
+}
+
+func (r *postgresCategoryRepository) queryCategories(ctx context.Context, query string, args ...interface{}) ([]domain.Category, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []domain.Category{}
+	for rows.Next() {
+		var category domain.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.ParentID); err != nil {
+			return nil, fmt.Errorf("error scanning category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating categories: %w", err)
+	}
 	return categories, nil
 }
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// keysetToken is the keyset position after the last row of a list page. Scope names the list it
+// was issued for, so that a token of one category's products cannot page through another's.
+type keysetToken struct {
+	Scope   string `json:"s,omitempty"`
+	AfterID int    `json:"a"`
+}
+
+func (t keysetToken) encode() string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodePageToken returns the ID to continue after; an empty token starts at the beginning.
+func decodePageToken(encoded, scope string) (int, error) {
+	if encoded == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, errors.New("invalid page token: malformed")
+	}
+	var token keysetToken
+	if err := json.Unmarshal(raw, &token); err != nil || token.AfterID <= 0 {
+		return 0, errors.New("invalid page token: malformed")
+	}
+	if token.Scope != scope {
+		return 0, errors.New("invalid page token: it belongs to a different listing")
+	}
+	return token.AfterID, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodePageToken(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		scope   string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty starts at the beginning", encoded: "", scope: "categories", want: 0},
+		{name: "round trip", encoded: keysetToken{Scope: "categories", AfterID: 120}.encode(), scope: "categories", want: 120},
+		{name: "round trip without scope", encoded: keysetToken{AfterID: 5}.encode(), scope: "", want: 5},
+		{name: "subtree scope", encoded: keysetToken{Scope: "subtree:3", AfterID: 9}.encode(), scope: "subtree:3", want: 9},
+		{name: "other listing", encoded: keysetToken{Scope: "category:3", AfterID: 9}.encode(), scope: "category:4", wantErr: true},
+		{name: "category token on subtree listing", encoded: keysetToken{Scope: "category:3", AfterID: 9}.encode(), scope: "subtree:3", wantErr: true},
+		{name: "not base64", encoded: "?!", scope: "categories", wantErr: true},
+		{name: "not JSON", encoded: encode("120"), scope: "categories", wantErr: true},
+		{name: "missing position", encoded: encode(`{"s":"categories"}`), scope: "categories", wantErr: true},
+		{name: "zero position", encoded: encode(`{"s":"categories","a":0}`), scope: "categories", wantErr: true},
+		{name: "negative position", encoded: encode(`{"s":"categories","a":-1}`), scope: "categories", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePageToken(tt.encoded, tt.scope)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodePageToken(%q, %q) = %d, want an error", tt.encoded, tt.scope, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodePageToken(%q, %q) returned error: %v", tt.encoded, tt.scope, err)
+			}
+			if got != tt.want {
+				t.Errorf("decodePageToken(%q, %q) = %d, want %d", tt.encoded, tt.scope, got, tt.want)
+			}
+		})
+	}
+}
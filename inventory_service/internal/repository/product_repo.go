@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
 	"strings"
 
 	"github.com/lib/pq"
@@ -23,7 +26,10 @@ func NewPostgresProductRepository(db *sql.DB, logger *logrus.Logger) domain.Prod
 	}
 }
 
-func (r *postgresProductRepository) CreateProduct(product *domain.Product) (*domain.Product, error) {
+func (r *postgresProductRepository) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.CreateProduct")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `
         INSERT INTO products (name, price, stock, category_id)
         VALUES ($1, $2, $3, $4)
@@ -35,24 +41,32 @@ func (r *postgresProductRepository) CreateProduct(product *domain.Product) (*dom
 		categoryID = sql.NullInt64{Valid: false}
 	}
 
-	err := r.db.QueryRow(query, product.Name, product.Price, product.Stock, categoryID).Scan(&product.ID)
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, query, product.Name, product.Price, product.Stock, categoryID).Scan(&product.ID); err != nil {
+			return err
+		}
+		return recordStockMovementTx(ctx, tx, product.ID, 0, product.Stock, product.Stock, domain.StockChange{Reason: domain.ReasonInitialStock})
+	})
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
-			r.log.Warnf("Attempted to create product with non-existent category ID: %d", product.CategoryID)
+			log.Warnf("Attempted to create product with non-existent category ID: %d", product.CategoryID)
 			return nil, fmt.Errorf("category with id %d does not exist", product.CategoryID)
 		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" {
-			r.log.Warnf("Check constraint violation for product '%s': %s", product.Name, pqErr.Message)
+			log.Warnf("Check constraint violation for product '%s': %s", product.Name, pqErr.Message)
 			return nil, fmt.Errorf("product data constraint violation: %s", pqErr.Message)
 		}
-		r.log.Errorf("Failed to create product '%s': %v", product.Name, err)
+		log.Errorf("Failed to create product '%s': %v", product.Name, err)
 		return nil, fmt.Errorf("could not create product: %w", err)
 	}
-	r.log.Infof("Product created successfully with ID: %d, Name: %s", product.ID, product.Name)
+	log.Infof("Product created successfully with ID: %d, Name: %s", product.ID, product.Name)
 	return product, nil
 }
 
-func (r *postgresProductRepository) GetProductByID(id int) (*domain.Product, error) {
+func (r *postgresProductRepository) GetProductByID(ctx context.Context, id int) (*domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.GetProductByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `
         SELECT id, name, price, stock, category_id
         FROM products
@@ -60,7 +74,7 @@ func (r *postgresProductRepository) GetProductByID(id int) (*domain.Product, err
 	product := &domain.Product{}
 	var categoryID sql.NullInt64
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
 		&product.Name,
 		&product.Price,
@@ -69,10 +83,10 @@ func (r *postgresProductRepository) GetProductByID(id int) (*domain.Product, err
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Product with ID %d not found", id)
+			log.Warnf("Product with ID %d not found", id)
 			return nil, fmt.Errorf("product with id %d not found", id)
 		}
-		r.log.Errorf("Failed to get product by ID %d: %v", id, err)
+		log.Errorf("Failed to get product by ID %d: %v", id, err)
 		return nil, fmt.Errorf("could not get product by id: %w", err)
 	}
 
@@ -82,14 +96,66 @@ func (r *postgresProductRepository) GetProductByID(id int) (*domain.Product, err
 		product.CategoryID = 0
 	}
 
-	r.log.Infof("Product retrieved successfully with ID: %d", id)
+	withVariants := []domain.Product{*product}
+	if err := r.attachVariants(ctx, withVariants); err != nil {
+		log.Errorf("Failed to get variants of product ID %d: %v", id, err)
+		return nil, err
+	}
+	product = &withVariants[0]
+
+	log.Infof("Product retrieved successfully with ID: %d", id)
 	return product, nil
 }
 
-func (r *postgresProductRepository) UpdateProduct(id int, updates map[string]interface{}) (*domain.Product, error) {
+func (r *postgresProductRepository) GetProductsByIDs(ctx context.Context, ids []int) ([]domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.GetProductsByIDs")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `
+        SELECT id, name, price, stock, category_id
+        FROM products
+        WHERE id = ANY($1::int[])
+        ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		log.Errorf("Failed to get %d products by ID: %v", len(ids), err)
+		return nil, fmt.Errorf("could not get products by id: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]domain.Product, 0, len(ids))
+	for rows.Next() {
+		var product domain.Product
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &categoryID); err != nil {
+			log.Errorf("Failed to scan product row: %v", err)
+			return nil, fmt.Errorf("error scanning product data: %w", err)
+		}
+		if categoryID.Valid {
+			product.CategoryID = int(categoryID.Int64)
+		}
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("Error during products iteration: %v", err)
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+	if err := r.attachVariants(ctx, products); err != nil {
+		log.Errorf("Failed to get variants of %d products: %v", len(products), err)
+		return nil, err
+	}
+	log.Infof("Retrieved %d of %d requested products", len(products), len(ids))
+	return products, nil
+}
+
+func (r *postgresProductRepository) UpdateProduct(ctx context.Context, id int, updates map[string]interface{}, change domain.StockChange) (*domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.UpdateProduct")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	if len(updates) == 0 {
-		r.log.Info("Repository: No fields provided for product update ID %d. Returning current product.", id)
-		return r.GetProductByID(id)
+		log.Infof("Repository: No fields provided for product update ID %d. Returning current product.", id)
+		return r.GetProductByID(ctx, id)
 	}
 
 	queryBase := "UPDATE products SET "
@@ -113,7 +179,7 @@ func (r *postgresProductRepository) UpdateProduct(id int, updates map[string]int
 
 			catID, ok := value.(int)
 			if !ok {
-				r.log.Errorf("Repository: Invalid type received for category_id for product ID %d: %T", id, value)
+				log.Errorf("Repository: Invalid type received for category_id for product ID %d: %T", id, value)
 				return nil, fmt.Errorf("internal error: invalid type for category_id in repository")
 			}
 			if catID == 0 {
@@ -123,7 +189,7 @@ func (r *postgresProductRepository) UpdateProduct(id int, updates map[string]int
 			}
 		default:
 
-			r.log.Warnf("Repository: Skipping unknown field '%s' provided for product update ID %d", key, id)
+			log.Warnf("Repository: Skipping unknown field '%s' provided for product update ID %d", key, id)
 			continue
 		}
 
@@ -133,156 +199,407 @@ func (r *postgresProductRepository) UpdateProduct(id int, updates map[string]int
 	}
 
 	if len(setClauses) == 0 {
-		r.log.Warn("Repository: No valid known fields provided for product update ID %d. Returning current product.", id)
-		return r.GetProductByID(id)
+		log.Warnf("Repository: No valid known fields provided for product update ID %d. Returning current product.", id)
+		return r.GetProductByID(ctx, id)
 	}
 
 	query := queryBase + strings.Join(setClauses, ", ") + fmt.Sprintf(" WHERE id = $%d", argCounter)
 	args = append(args, id) // Добавляем ID в конец аргументов
 
-	r.log.Debugf("Repository: Executing partial update query for ID %d: %s with args: %v", id, query, args)
+	log.Debugf("Repository: Executing partial update query for ID %d: %s with args: %v", id, query, args)
+
+	var rowsAffected int64
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		newStock, stockChanged := updates["stock"].(int)
+		var oldStock int
+		if stockChanged {
+			// Lock the row so the recorded delta matches the stock actually replaced
+			var hasVariants bool
+			err := tx.QueryRowContext(ctx, `
+                SELECT stock, EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1)
+                FROM products
+                WHERE id = $1
+                FOR UPDATE`, id).Scan(&oldStock, &hasVariants)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not read current stock: %w", err)
+			}
+			if hasVariants {
+				return fmt.Errorf("invalid stock change: product %d is sold in variants, change the stock of its variants instead", id)
+			}
+			if change.Reason == domain.ReasonRestock && newStock < oldStock {
+				return fmt.Errorf("invalid stock change: restock cannot lower stock of product %d from %d to %d", id, oldStock, newStock)
+			}
+		}
 
-	result, err := r.db.Exec(query, args...)
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err = result.RowsAffected(); err != nil {
+			log.Errorf("Repository: Failed to get rows affected after partial update for ID %d: %v", id, err)
+		}
+		if stockChanged && rowsAffected > 0 {
+			return recordStockMovementTx(ctx, tx, id, 0, newStock-oldStock, newStock, change)
+		}
+		return nil
+	})
 	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid stock change") {
+			log.Warnf("Repository: %v", err)
+			return nil, err
+		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
 			catID := 0
 			if catIDVal, exists := updates["category_id"]; exists {
 				catID, _ = catIDVal.(int)
 			}
-			r.log.Warnf("Repository: Attempted to update product ID %d with non-existent category ID: %d", id, catID)
+			log.Warnf("Repository: Attempted to update product ID %d with non-existent category ID: %d", id, catID)
 			return nil, fmt.Errorf("category with id %d does not exist", catID)
 		}
 
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" {
-			r.log.Warnf("Repository: Check constraint violation for product update ID %d: %s", id, pqErr.Message)
+			log.Warnf("Repository: Check constraint violation for product update ID %d: %s", id, pqErr.Message)
 			return nil, fmt.Errorf("product data constraint violation: %s", pqErr.Message)
 		}
-		r.log.Errorf("Repository: Failed to execute partial update for product ID %d: %v", id, err)
+		log.Errorf("Repository: Failed to execute partial update for product ID %d: %v", id, err)
 		return nil, fmt.Errorf("could not partially update product: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		r.log.Errorf("Repository: Failed to get rows affected after partial update for ID %d: %v", id, err)
-
-	}
-
 	if rowsAffected == 0 {
-		r.log.Warnf("Repository: Product with ID %d not found for update (0 rows affected)", id)
+		log.Warnf("Repository: Product with ID %d not found for update (0 rows affected)", id)
 		return nil, fmt.Errorf("product with id %d not found for update", id)
 	}
 
-	r.log.Infof("Repository: Partial update successful for product ID %d (%d rows affected). Fetching updated product.", id, rowsAffected)
-	return r.GetProductByID(id)
+	log.Infof("Repository: Partial update successful for product ID %d (%d rows affected). Fetching updated product.", id, rowsAffected)
+	return r.GetProductByID(ctx, id)
 }
 
-func (r *postgresProductRepository) DeleteProduct(id int) error {
-	query := `DELETE FROM products WHERE id = $1`
-	result, err := r.db.Exec(query, id)
-	if err != nil {
-		r.log.Errorf("Failed to delete product ID %d: %v", id, err)
-		return fmt.Errorf("could not delete product: %w", err)
-	}
-	rowsAffected, err := result.RowsAffected()
+func (r *postgresProductRepository) DeleteProduct(ctx context.Context, id int) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.DeleteProduct")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		// Locking the product keeps new reservations of it out until the delete commits
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT true FROM products WHERE id = $1 FOR UPDATE`, id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("product with id %d not found for deletion", id)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read product %d: %w", id, err)
+		}
+
+		// Cancelling these reservations returns stock to the product, so it has to outlive them
+		var held bool
+		err = tx.QueryRowContext(ctx, `
+            SELECT EXISTS (
+                SELECT 1
+                FROM stock_reservation_items i
+                JOIN stock_reservations s ON s.id = i.reservation_id
+                WHERE i.product_id = $1 AND s.status IN ('pending', 'confirmed')
+            )`, id).Scan(&held)
+		if err != nil {
+			return fmt.Errorf("could not check reservations of product %d: %w", id, err)
+		}
+		if held {
+			return fmt.Errorf("cannot delete product %d: open reservations still hold its stock", id)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("could not delete product: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		r.log.Errorf("Failed to get rows affected after deleting product ID %d: %v", id, err)
-		return fmt.Errorf("could not confirm product deletion: %w", err)
-	}
-	if rowsAffected == 0 {
-		r.log.Warnf("Attempted to delete non-existent product ID %d", id)
-		return fmt.Errorf("product with id %d not found for deletion", id)
+		log.Warnf("Failed to delete product ID %d: %v", id, err)
+		return err
 	}
-	r.log.Infof("Product deleted successfully with ID: %d", id)
+	log.Infof("Product deleted successfully with ID: %d", id)
 	return nil
 }
 
-func (r *postgresProductRepository) ListProducts(limit, offset int) ([]domain.Product, error) {
+func (r *postgresProductRepository) ListProducts(ctx context.Context, limit, offset int, pageToken string) (*domain.ProductPage, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.ListProducts")
+	defer span.End()
+	return r.listProducts(ctx, 0, false, limit, offset, pageToken)
+}
+
+func (r *postgresProductRepository) ListProductsByCategory(ctx context.Context, categoryID int, includeSubcategories bool, limit, offset int, pageToken string) (*domain.ProductPage, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.ListProductsByCategory")
+	defer span.End()
+	return r.listProducts(ctx, categoryID, includeSubcategories, limit, offset, pageToken)
+}
+
+// listProducts pages by ID with a keyset ("id > last ID of the previous page") so that deep pages
+// cost the same as the first one. A categoryID of 0 lists all products.
+func (r *postgresProductRepository) listProducts(ctx context.Context, categoryID int, subtree bool, limit, offset int, pageToken string) (*domain.ProductPage, error) {
+	log := requestid.Logger(ctx, r.log)
 	if limit <= 0 {
 		limit = 10
 	}
 	if limit > 100 {
 		limit = 100
 	}
-	if offset < 0 {
+	if offset < 0 || pageToken != "" {
 		offset = 0
 	}
 
-	query := `
+	scope := ""
+	if categoryID > 0 {
+		scope = fmt.Sprintf("category:%d", categoryID)
+		if subtree {
+			scope = fmt.Sprintf("subtree:%d", categoryID)
+		}
+	}
+	afterID, err := decodePageToken(pageToken, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	with, where := "", "id > $1"
+	args := []interface{}{afterID}
+	countQuery := `SELECT COUNT(*) FROM products`
+	var countArgs []interface{}
+	switch {
+	case categoryID > 0 && subtree:
+		with = categorySubtreeCTE("$2")
+		where += " AND category_id IN (SELECT id FROM subtree)"
+		args = append(args, categoryID)
+		countQuery = categorySubtreeCTE("$1") + ` SELECT COUNT(*) FROM products WHERE category_id IN (SELECT id FROM subtree)`
+		countArgs = append(countArgs, categoryID)
+	case categoryID > 0:
+		where += " AND category_id = $2"
+		args = append(args, categoryID)
+		countQuery += ` WHERE category_id = $1`
+		countArgs = append(countArgs, categoryID)
+	}
+
+	page := &domain.ProductPage{Products: []domain.Product{}}
+	if err := r.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&page.TotalSize); err != nil {
+		log.Errorf("Failed to count products (category: %d, subcategories: %t): %v", categoryID, subtree, err)
+		return nil, fmt.Errorf("could not count products: %w", err)
+	}
+
+	// One extra row tells whether there is a next page
+	args = append(args, limit+1, offset)
+	query := fmt.Sprintf(`%s
         SELECT id, name, price, stock, category_id
         FROM products
+        WHERE %s
         ORDER BY id ASC
-        LIMIT $1 OFFSET $2`
-	rows, err := r.db.Query(query, limit, offset)
+        LIMIT $%d OFFSET $%d`, with, where, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		r.log.Errorf("Failed to list products with limit %d, offset %d: %v", limit, offset, err)
+		log.Errorf("Failed to list products (category: %d, limit: %d, offset: %d): %v", categoryID, limit, offset, err)
 		return nil, fmt.Errorf("could not list products: %w", err)
 	}
 	defer rows.Close()
 
-	products := []domain.Product{}
 	for rows.Next() {
 		var product domain.Product
-		var categoryID sql.NullInt64
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &categoryID); err != nil {
-			r.log.Errorf("Failed to scan product row: %v", err)
+		var catID sql.NullInt64
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &catID); err != nil {
+			log.Errorf("Failed to scan product row: %v", err)
 			return nil, fmt.Errorf("error scanning product data: %w", err)
 		}
-		product.CategoryID = 0
-		if categoryID.Valid {
-			product.CategoryID = int(categoryID.Int64)
+		if catID.Valid {
+			product.CategoryID = int(catID.Int64)
 		}
-		products = append(products, product)
+		page.Products = append(page.Products, product)
 	}
 	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during products list iteration: %v", err)
+		log.Errorf("Error during products list iteration: %v", err)
 		return nil, fmt.Errorf("error iterating products: %w", err)
 	}
-	r.log.Infof("Retrieved %d products (limit: %d, offset: %d)", len(products), limit, offset)
-	return products, nil
-}
 
-func (r *postgresProductRepository) ListProductsByCategory(categoryID, limit, offset int) ([]domain.Product, error) {
-	if limit <= 0 {
-		limit = 10
+	if len(page.Products) > limit {
+		page.Products = page.Products[:limit]
+		page.NextPageToken = keysetToken{Scope: scope, AfterID: page.Products[limit-1].ID}.encode()
 	}
-	if limit > 100 {
-		limit = 100
+	if err := r.attachVariants(ctx, page.Products); err != nil {
+		log.Errorf("Failed to load variants of %d listed products: %v", len(page.Products), err)
+		return nil, err
 	}
-	if offset < 0 {
-		offset = 0
+	log.Infof("Retrieved %d of %d products (category: %d, limit: %d, more: %t)",
+		len(page.Products), page.TotalSize, categoryID, limit, page.NextPageToken != "")
+	return page, nil
+}
+
+func (r *postgresProductRepository) ReleaseStock(ctx context.Context, items []domain.StockItem, idempotencyKey string) ([]domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.ReleaseStock")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	products := []domain.Product{}
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		if idempotencyKey != "" {
+			result, err := tx.ExecContext(ctx,
+				`INSERT INTO stock_releases (idempotency_key) VALUES ($1) ON CONFLICT (idempotency_key) DO NOTHING`,
+				idempotencyKey)
+			if err != nil {
+				return fmt.Errorf("could not record stock release: %w", err)
+			}
+			if n, err := result.RowsAffected(); err == nil && n == 0 {
+				log.Infof("Repository: Stock release '%s' was already applied, skipping", idempotencyKey)
+				return nil
+			}
+		}
+
+		var err error
+		products, err = releaseItemsTx(ctx, tx, items, domain.StockChange{Reason: domain.ReasonOrderCancel, ReferenceID: idempotencyKey})
+		return err
+	})
+	if err != nil {
+		log.Errorf("Repository: Stock release failed, nothing was released: %v", err)
+		return nil, err
 	}
+	log.Infof("Repository: Released stock for %d products", len(products))
+	return products, nil
+}
 
-	query := `
-        SELECT id, name, price, stock, category_id
-        FROM products
-        WHERE category_id = $1
-        ORDER BY id ASC
-        LIMIT $2 OFFSET $3`
-	rows, err := r.db.Query(query, categoryID, limit, offset)
+func (r *postgresProductRepository) AppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.AppliedReleases")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT idempotency_key FROM stock_releases WHERE idempotency_key = ANY($1::text[]) ORDER BY idempotency_key`,
+		pq.Array(idempotencyKeys))
 	if err != nil {
-		r.log.Errorf("Failed to list products for category %d (limit %d, offset %d): %v", categoryID, limit, offset, err)
-		return nil, fmt.Errorf("could not list products by category: %w", err)
+		log.Errorf("Repository: Failed to look up %d stock releases: %v", len(idempotencyKeys), err)
+		return nil, fmt.Errorf("could not look up stock releases: %w", err)
 	}
 	defer rows.Close()
 
-	products := []domain.Product{}
+	applied := []string{}
 	for rows.Next() {
-		var product domain.Product
-		var catID sql.NullInt64
-		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &catID); err != nil {
-			r.log.Errorf("Failed to scan product row for category %d: %v", categoryID, err)
-			return nil, fmt.Errorf("error scanning product data for category: %w", err)
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error scanning stock release: %w", err)
 		}
-		product.CategoryID = 0
-		if catID.Valid {
-			product.CategoryID = int(catID.Int64)
+		applied = append(applied, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock releases: %w", err)
+	}
+	return applied, nil
+}
+
+// reserveItemsTx applies conditional decrements (stock >= n) for every item and records them in the ledger;
+// the first shortage aborts the batch. Items of products sold in variants are taken from the variant.
+func reserveItemsTx(ctx context.Context, tx *sql.Tx, items []domain.StockItem, change domain.StockChange) ([]domain.Product, error) {
+	query := `
+        UPDATE products
+        SET stock = stock - $1
+        WHERE id = $2 AND stock >= $1
+          AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id)
+        RETURNING id, name, price, stock, category_id`
+
+	products := make([]domain.Product, 0, len(items))
+	for _, item := range items {
+		if item.VariantID > 0 {
+			product, err := adjustVariantStockTx(ctx, tx, item, -item.Quantity, change)
+			if err != nil {
+				return nil, err
+			}
+			products = append(products, *product)
+			continue
 		}
-		products = append(products, product)
+
+		product, err := scanProductRow(tx.QueryRowContext(ctx, query, item.Quantity, item.ProductID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, stockShortageError(ctx, tx, item)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not reserve stock for product %d: %w", item.ProductID, err)
+		}
+		if err := recordStockMovementTx(ctx, tx, product.ID, 0, -item.Quantity, product.Stock, change); err != nil {
+			return nil, err
+		}
+		products = append(products, *product)
 	}
-	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during products by category list iteration: %v", err)
-		return nil, fmt.Errorf("error iterating products by category: %w", err)
+	return products, nil
+}
+
+// releaseItemsTx returns stock for every item and records it in the ledger. Stock reserved from a
+// product that has since been split into variants cannot be attributed to one of them, so such a
+// release fails as a whole; CreateVariant refuses to split products with stock still held.
+func releaseItemsTx(ctx context.Context, tx *sql.Tx, items []domain.StockItem, change domain.StockChange) ([]domain.Product, error) {
+	query := `
+        UPDATE products
+        SET stock = stock + $1
+        WHERE id = $2
+          AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id)
+        RETURNING id, name, price, stock, category_id`
+
+	products := make([]domain.Product, 0, len(items))
+	for _, item := range items {
+		if item.VariantID > 0 {
+			product, err := adjustVariantStockTx(ctx, tx, item, item.Quantity, change)
+			if err != nil {
+				return nil, err
+			}
+			products = append(products, *product)
+			continue
+		}
+
+		product, err := scanProductRow(tx.QueryRowContext(ctx, query, item.Quantity, item.ProductID))
+		if errors.Is(err, sql.ErrNoRows) {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, item.ProductID).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("could not check product %d: %w", item.ProductID, err)
+			}
+			if exists {
+				return nil, fmt.Errorf("cannot release stock: product %d is sold in variants, its stock cannot be returned without a variant_id", item.ProductID)
+			}
+			return nil, fmt.Errorf("product with id %d not found", item.ProductID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not release stock for product %d: %w", item.ProductID, err)
+		}
+		if err := recordStockMovementTx(ctx, tx, product.ID, 0, item.Quantity, product.Stock, change); err != nil {
+			return nil, err
+		}
+		products = append(products, *product)
 	}
-	r.log.Infof("Retrieved %d products for category %d (limit: %d, offset: %d)", len(products), categoryID, limit, offset)
 	return products, nil
 }
+
+// stockShortageError explains why a conditional decrement matched no row: the product is missing, sold
+// in variants or short on stock.
+func stockShortageError(ctx context.Context, tx *sql.Tx, item domain.StockItem) error {
+	var (
+		stock       int
+		hasVariants bool
+	)
+	err := tx.QueryRowContext(ctx, `
+        SELECT stock, EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1)
+        FROM products
+        WHERE id = $1`, item.ProductID).Scan(&stock, &hasVariants)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("product with id %d not found", item.ProductID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not check stock for product %d: %w", item.ProductID, err)
+	}
+	if hasVariants {
+		return fmt.Errorf("invalid stock item: product %d is sold in variants, a variant_id is required", item.ProductID)
+	}
+	return fmt.Errorf("insufficient stock for product %d (requested: %d, available: %d)", item.ProductID, item.Quantity, stock)
+}
+
+func scanProductRow(row *sql.Row) (*domain.Product, error) {
+	product := &domain.Product{}
+	var categoryID sql.NullInt64
+	if err := row.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &categoryID); err != nil {
+		return nil, err
+	}
+	if categoryID.Valid {
+		product.CategoryID = int(categoryID.Int64)
+	}
+	return product, nil
+}
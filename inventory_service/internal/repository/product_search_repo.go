@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
+	sortpkg "sort"
+	"strings"
+	"unicode"
+
+	"github.com/lib/pq"
+)
+
+// sellablePrices lists the prices a product sells at: the effective price of each of its variants,
+// or the product's own price if it has none. %[1]s names the products row it is correlated with.
+const sellablePrices = `
+        SELECT COALESCE(v.price, %[1]s.price) AS price FROM product_variants v WHERE v.product_id = %[1]s.id
+        UNION ALL
+        SELECT %[1]s.price WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = %[1]s.id)`
+
+// productSortClauses maps each sort to its ORDER BY; "query" is the tsquery joined in by SearchProducts.
+// Products sold in variants sort by their cheapest variant ascending and by their dearest one descending.
+var productSortClauses = map[domain.ProductSort]string{
+	domain.SortRelevance: "ts_rank(search_vector, query) DESC, id DESC",
+	domain.SortNewest:    "created_at DESC, id DESC",
+	domain.SortPriceAsc:  fmt.Sprintf("(SELECT MIN(s.price) FROM (%s) s) ASC, id ASC", fmt.Sprintf(sellablePrices, "products")),
+	domain.SortPriceDesc: fmt.Sprintf("(SELECT MAX(s.price) FROM (%s) s) DESC, id DESC", fmt.Sprintf(sellablePrices, "products")),
+	domain.SortName:      "name ASC, id ASC",
+}
+
+// productSearchQuery is the WITH, FROM and WHERE part shared by a search and its facets. Its FROM
+// exposes the tsquery as "query" when the search has text; a search of a category subtree adds
+// the recursive "subtree" CTE.
+type productSearchQuery struct {
+	with       string
+	from       string
+	conditions []string
+	args       []interface{}
+	hasText    bool
+}
+
+func newProductSearchQuery(filter domain.ProductSearchFilter) *productSearchQuery {
+	q := &productSearchQuery{from: "products"}
+	if tsQuery := prefixTSQuery(filter.Query); tsQuery != "" {
+		q.from = fmt.Sprintf("products, to_tsquery('simple', %s) AS query", q.arg(tsQuery))
+		q.conditions = append(q.conditions, "search_vector @@ query")
+		q.hasText = true
+	}
+	switch {
+	case filter.CategoryID > 0 && filter.IncludeSubcategories:
+		q.with = categorySubtreeCTE(q.arg(filter.CategoryID))
+		q.conditions = append(q.conditions, "category_id IN (SELECT id FROM subtree)")
+	case filter.CategoryID > 0:
+		q.conditions = append(q.conditions, "category_id = "+q.arg(filter.CategoryID))
+	}
+	// A product matches the price range if any of its variants sells within it
+	var priceConditions []string
+	if filter.MinPrice > 0 {
+		priceConditions = append(priceConditions, "s.price >= "+q.arg(filter.MinPrice))
+	}
+	if filter.MaxPrice > 0 {
+		priceConditions = append(priceConditions, "s.price <= "+q.arg(filter.MaxPrice))
+	}
+	if len(priceConditions) > 0 {
+		q.conditions = append(q.conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM (%s) s WHERE %s)",
+			fmt.Sprintf(sellablePrices, "products"), strings.Join(priceConditions, " AND ")))
+	}
+	if filter.InStockOnly {
+		q.conditions = append(q.conditions, "stock > 0")
+	}
+	return q
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *productSearchQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *productSearchQuery) fromWhere() string {
+	clause := " FROM " + q.from
+	if len(q.conditions) > 0 {
+		clause += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	return clause
+}
+
+func (r *postgresProductRepository) SearchProducts(ctx context.Context, filter domain.ProductSearchFilter) ([]domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.SearchProducts")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	q := newProductSearchQuery(filter)
+	sort := filter.Sort
+	if sort == domain.SortRelevance && !q.hasText {
+		// Nothing to rank by
+		sort = domain.SortNewest
+	}
+	orderBy, ok := productSortClauses[sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort: %s", filter.Sort)
+	}
+
+	query := q.with + " SELECT id, name, price, stock, category_id" + q.fromWhere() +
+		fmt.Sprintf(" ORDER BY %s LIMIT %s OFFSET %s", orderBy, q.arg(filter.Limit), q.arg(filter.Offset))
+	args := q.args
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Failed to search products: %v", err)
+		return nil, fmt.Errorf("could not search products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]domain.Product, 0, filter.Limit)
+	for rows.Next() {
+		var product domain.Product
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &categoryID); err != nil {
+			log.Errorf("Failed to scan product row of search: %v", err)
+			return nil, fmt.Errorf("error scanning product data: %w", err)
+		}
+		if categoryID.Valid {
+			product.CategoryID = int(categoryID.Int64)
+		}
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("Error during product search iteration: %v", err)
+		return nil, fmt.Errorf("error iterating products: %w", err)
+	}
+	if err := r.attachVariants(ctx, products); err != nil {
+		log.Errorf("Failed to load variants of product search results: %v", err)
+		return nil, err
+	}
+	log.Infof("Product search %q returned %d products (sort: %s)", filter.Query, len(products), sort)
+	return products, nil
+}
+
+func (r *postgresProductRepository) SearchProductFacets(ctx context.Context, filter domain.ProductSearchFilter) (*domain.ProductFacets, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.SearchProductFacets")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	// All three facets in one round trip over the same matches. width_bucket returns 0 below the
+	// first edge and len(edges) from the last edge up. A product sold in variants counts once in
+	// every bucket one of its variants is priced in, as the price filter would find it there.
+	q := newProductSearchQuery(filter)
+	edges := q.arg(pq.Array(domain.PriceBucketEdges))
+	with := "WITH"
+	if q.with != "" {
+		with = q.with + ","
+	}
+	query := with + `
+        matched AS (SELECT products.id, category_id, price, stock` + q.fromWhere() + `)
+        SELECT 'category', COALESCE(m.category_id, 0), COALESCE(c.name, ''), m.count
+        FROM (SELECT category_id, COUNT(*) AS count FROM matched GROUP BY category_id) m
+        LEFT JOIN categories c ON c.id = m.category_id
+        UNION ALL
+        SELECT 'price', width_bucket(s.price, ` + edges + `::numeric[]), '', COUNT(DISTINCT matched.id)
+        FROM matched, LATERAL (` + fmt.Sprintf(sellablePrices, "matched") + `) s
+        GROUP BY 2
+        UNION ALL
+        SELECT 'stock', CASE WHEN stock > 0 THEN 1 ELSE 0 END, '', COUNT(*) FROM matched GROUP BY 2`
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		log.Errorf("Failed to compute product search facets: %v", err)
+		return nil, fmt.Errorf("could not compute search facets: %w", err)
+	}
+	defer rows.Close()
+
+	facets := &domain.ProductFacets{
+		Categories:   []domain.CategoryFacet{},
+		PriceBuckets: make([]domain.PriceBucket, len(domain.PriceBucketEdges)+1),
+	}
+	for i := range facets.PriceBuckets {
+		if i > 0 {
+			facets.PriceBuckets[i].Min = domain.PriceBucketEdges[i-1]
+		}
+		if i < len(domain.PriceBucketEdges) {
+			facets.PriceBuckets[i].Max = domain.PriceBucketEdges[i]
+		}
+	}
+	for rows.Next() {
+		var facet, label string
+		var key, count int
+		if err := rows.Scan(&facet, &key, &label, &count); err != nil {
+			log.Errorf("Failed to scan product search facet: %v", err)
+			return nil, fmt.Errorf("error scanning search facet: %w", err)
+		}
+		switch facet {
+		case "category":
+			facets.Categories = append(facets.Categories, domain.CategoryFacet{CategoryID: key, Name: label, Count: count})
+		case "price":
+			facets.PriceBuckets[key].Count = count
+		case "stock":
+			if key == 1 {
+				facets.InStock = count
+			} else {
+				facets.OutOfStock = count
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search facets: %w", err)
+	}
+
+	sortpkg.Slice(facets.Categories, func(i, j int) bool {
+		if facets.Categories[i].Count != facets.Categories[j].Count {
+			return facets.Categories[i].Count > facets.Categories[j].Count
+		}
+		return facets.Categories[i].CategoryID < facets.Categories[j].CategoryID
+	})
+	log.Infof("Computed facets for product search %q: %d categories, %d in stock, %d out of stock",
+		filter.Query, len(facets.Categories), facets.InStock, facets.OutOfStock)
+	return facets, nil
+}
+
+// prefixTSQuery turns free text from a search box into a tsquery that requires every word as a
+// prefix, e.g. "red sh" becomes "red:* & sh:*". Punctuation is dropped so that user input can never
+// form tsquery syntax; "" means there is nothing to search for.
+func prefixTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
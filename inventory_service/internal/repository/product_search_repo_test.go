@@ -0,0 +1,31 @@
+package repository
+
+import "testing"
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "empty", text: "", want: ""},
+		{name: "only spaces", text: "   ", want: ""},
+		{name: "single word", text: "shirt", want: "shirt:*"},
+		{name: "several words", text: "red sh", want: "red:* & sh:*"},
+		{name: "lower-cased", text: "Red SHIRT", want: "red:* & shirt:*"},
+		{name: "extra whitespace", text: "  red \t shirt\n", want: "red:* & shirt:*"},
+		{name: "digits kept", text: "iphone 15", want: "iphone:* & 15:*"},
+		{name: "non-latin letters kept", text: "Футболка синяя", want: "футболка:* & синяя:*"},
+		{name: "punctuation splits words", text: "t-shirt", want: "t:* & shirt:*"},
+		{name: "tsquery operators dropped", text: "a & b | !c <-> (d):*", want: "a:* & b:* & c:* & d:*"},
+		{name: "quotes dropped", text: `'shirt' "red"`, want: "shirt:* & red:*"},
+		{name: "only punctuation", text: "&|!():*", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prefixTSQuery(tt.text); got != tt.want {
+				t.Errorf("prefixTSQuery(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const variantColumns = `id, product_id, sku, attributes, COALESCE(price, 0), stock`
+
+func (r *postgresProductRepository) CreateVariant(ctx context.Context, variant *domain.ProductVariant) (*domain.ProductVariant, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.CreateVariant")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	if variant.Attributes == nil {
+		variant.Attributes = map[string]string{}
+	}
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid variant attributes: %w", err)
+	}
+
+	err = withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		// Locking the product serializes this with stock writes on the product row
+		var (
+			ownStock    int
+			hasVariants bool
+		)
+		err := tx.QueryRowContext(ctx, `
+            SELECT stock, EXISTS (SELECT 1 FROM product_variants WHERE product_id = $1)
+            FROM products
+            WHERE id = $1
+            FOR UPDATE`, variant.ProductID).Scan(&ownStock, &hasVariants)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("product with id %d not found", variant.ProductID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read product %d: %w", variant.ProductID, err)
+		}
+
+		// Stock of the product itself cannot be split between sizes, so it has to be cleared first
+		if !hasVariants {
+			if ownStock > 0 {
+				return fmt.Errorf("cannot add variants to product %d: it still has a stock of %d of its own, set it to 0 first", variant.ProductID, ownStock)
+			}
+			// Held stock would be returned to the product, which no longer takes stock of its own
+			var held bool
+			err := tx.QueryRowContext(ctx, `
+                SELECT EXISTS (
+                    SELECT 1
+                    FROM stock_reservation_items i
+                    JOIN stock_reservations s ON s.id = i.reservation_id
+                    WHERE i.product_id = $1 AND i.variant_id IS NULL AND s.status IN ('pending', 'confirmed')
+                )`, variant.ProductID).Scan(&held)
+			if err != nil {
+				return fmt.Errorf("could not check reservations of product %d: %w", variant.ProductID, err)
+			}
+			if held {
+				return fmt.Errorf("cannot add variants to product %d: open reservations still hold its stock", variant.ProductID)
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, `
+            INSERT INTO product_variants (product_id, sku, attributes, price, stock)
+            VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+            RETURNING id`,
+			variant.ProductID, variant.SKU, attributes, variant.Price, variant.Stock,
+		).Scan(&variant.ID)
+		if err != nil {
+			return err
+		}
+		return recordStockMovementTx(ctx, tx, variant.ProductID, variant.ID, variant.Stock, variant.Stock, domain.StockChange{Reason: domain.ReasonInitialStock})
+	})
+	if err != nil {
+		err = mapVariantWriteError(err, variant.SKU)
+		log.Warnf("Repository: Failed to create variant '%s' of product %d: %v", variant.SKU, variant.ProductID, err)
+		return nil, err
+	}
+	log.Infof("Repository: Variant created with ID: %d, SKU: %s, product: %d", variant.ID, variant.SKU, variant.ProductID)
+	return variant, nil
+}
+
+func (r *postgresProductRepository) GetVariantByID(ctx context.Context, id int) (*domain.ProductVariant, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.GetVariantByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	variant, err := scanVariant(r.db.QueryRowContext(ctx, `SELECT `+variantColumns+` FROM product_variants WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warnf("Repository: Variant with ID %d not found", id)
+			return nil, fmt.Errorf("variant with id %d not found", id)
+		}
+		log.Errorf("Repository: Failed to get variant by ID %d: %v", id, err)
+		return nil, fmt.Errorf("could not get variant by id: %w", err)
+	}
+	return variant, nil
+}
+
+func (r *postgresProductRepository) UpdateVariant(ctx context.Context, id int, updates map[string]interface{}, change domain.StockChange) (*domain.ProductVariant, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.UpdateVariant")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	args := []interface{}{}
+	setClauses := []string{}
+	for key, value := range updates {
+		switch key {
+		case "sku", "stock":
+		case "price":
+			// 0 clears the override
+			if price, ok := value.(float64); ok && price == 0 {
+				value = nil
+			}
+		case "attributes":
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid variant attributes: %w", err)
+			}
+			value = encoded
+		default:
+			log.Warnf("Repository: Skipping unknown field '%s' provided for variant update ID %d", key, id)
+			continue
+		}
+		args = append(args, value)
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", key, len(args)))
+	}
+	if len(setClauses) == 0 {
+		return r.GetVariantByID(ctx, id)
+	}
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE product_variants SET %s WHERE id = $%d", strings.Join(setClauses, ", "), len(args))
+
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		var productID, oldStock int
+		// Lock the row so the recorded delta matches the stock actually replaced
+		err := tx.QueryRowContext(ctx, `SELECT product_id, stock FROM product_variants WHERE id = $1 FOR UPDATE`, id).Scan(&productID, &oldStock)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("variant with id %d not found for update", id)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read variant %d: %w", id, err)
+		}
+		newStock, stockChanged := updates["stock"].(int)
+		if stockChanged && change.Reason == domain.ReasonRestock && newStock < oldStock {
+			return fmt.Errorf("invalid stock change: restock cannot lower stock of variant %d from %d to %d", id, oldStock, newStock)
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+		if stockChanged {
+			return recordStockMovementTx(ctx, tx, productID, id, newStock-oldStock, newStock, change)
+		}
+		return nil
+	})
+	if err != nil {
+		sku, _ := updates["sku"].(string)
+		err = mapVariantWriteError(err, sku)
+		log.Warnf("Repository: Failed to update variant ID %d: %v", id, err)
+		return nil, err
+	}
+	log.Infof("Repository: Variant ID %d updated", id)
+	return r.GetVariantByID(ctx, id)
+}
+
+func (r *postgresProductRepository) DeleteVariant(ctx context.Context, id int) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "ProductRepository.DeleteVariant")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		// Locking the variant keeps new reservations of it out until the delete commits
+		var productID, stock int
+		err := tx.QueryRowContext(ctx, `SELECT product_id, stock FROM product_variants WHERE id = $1 FOR UPDATE`, id).Scan(&productID, &stock)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("variant with id %d not found for deletion", id)
+		}
+		if err != nil {
+			return fmt.Errorf("could not read variant %d: %w", id, err)
+		}
+
+		// Cancelling these reservations returns stock to the variant, so it has to outlive them
+		var held bool
+		err = tx.QueryRowContext(ctx, `
+            SELECT EXISTS (
+                SELECT 1
+                FROM stock_reservation_items i
+                JOIN stock_reservations s ON s.id = i.reservation_id
+                WHERE i.variant_id = $1 AND s.status IN ('pending', 'confirmed')
+            )`, id).Scan(&held)
+		if err != nil {
+			return fmt.Errorf("could not check reservations of variant %d: %w", id, err)
+		}
+		if held {
+			return fmt.Errorf("cannot delete variant %d: open reservations still hold its stock", id)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_variants WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("could not delete variant: %w", err)
+		}
+		// The trigger takes the stock off the product; the ledger has to show where it went
+		return recordStockMovementTx(ctx, tx, productID, id, -stock, 0, domain.StockChange{Reason: domain.ReasonManualAdjust})
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to delete variant ID %d: %v", id, err)
+		return err
+	}
+	log.Infof("Repository: Variant deleted successfully with ID: %d", id)
+	return nil
+}
+
+// attachVariants loads the variants of the given products, ordered by ID.
+func (r *postgresProductRepository) attachVariants(ctx context.Context, products []domain.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]int, len(products))
+	index := make(map[int]int, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+		index[product.ID] = i
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+variantColumns+`
+        FROM product_variants
+        WHERE product_id = ANY($1::int[])
+        ORDER BY product_id, id`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("could not get product variants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return fmt.Errorf("error scanning product variant: %w", err)
+		}
+		if i, ok := index[variant.ProductID]; ok {
+			products[i].Variants = append(products[i].Variants, *variant)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating product variants: %w", err)
+	}
+	return nil
+}
+
+// adjustVariantStockTx adds delta to a variant's stock, never below zero, records the movement and
+// returns the product with its stock as updated by the trigger.
+func adjustVariantStockTx(ctx context.Context, tx *sql.Tx, item domain.StockItem, delta int, change domain.StockChange) (*domain.Product, error) {
+	var stock int
+	err := tx.QueryRowContext(ctx, `
+        UPDATE product_variants
+        SET stock = stock + $1
+        WHERE id = $2 AND product_id = $3 AND stock + $1 >= 0
+        RETURNING stock`, delta, item.VariantID, item.ProductID).Scan(&stock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, variantShortageError(ctx, tx, item)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not change stock of variant %d: %w", item.VariantID, err)
+	}
+	if err := recordStockMovementTx(ctx, tx, item.ProductID, item.VariantID, delta, stock, change); err != nil {
+		return nil, err
+	}
+
+	product, err := scanProductRow(tx.QueryRowContext(ctx, `SELECT id, name, price, stock, category_id FROM products WHERE id = $1`, item.ProductID))
+	if err != nil {
+		return nil, fmt.Errorf("could not read product %d: %w", item.ProductID, err)
+	}
+	return product, nil
+}
+
+// variantShortageError explains why a variant stock change matched no row: the variant is missing,
+// belongs to another product or is short on stock.
+func variantShortageError(ctx context.Context, tx *sql.Tx, item domain.StockItem) error {
+	var (
+		sku   string
+		stock int
+	)
+	err := tx.QueryRowContext(ctx, `SELECT sku, stock FROM product_variants WHERE id = $1 AND product_id = $2`, item.VariantID, item.ProductID).Scan(&sku, &stock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("variant with id %d of product %d not found", item.VariantID, item.ProductID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not check stock for variant %d: %w", item.VariantID, err)
+	}
+	return fmt.Errorf("insufficient stock for product %d variant %s (requested: %d, available: %d)", item.ProductID, sku, item.Quantity, stock)
+}
+
+func mapVariantWriteError(err error, sku string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return fmt.Errorf("variant with SKU '%s' already exists", sku)
+		case "23514":
+			return fmt.Errorf("invalid variant: %s", pqErr.Message)
+		}
+		return fmt.Errorf("could not write variant: %w", err)
+	}
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVariant(row rowScanner) (*domain.ProductVariant, error) {
+	variant := &domain.ProductVariant{}
+	var attributes []byte
+	if err := row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &attributes, &variant.Price, &variant.Stock); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attributes, &variant.Attributes); err != nil {
+		return nil, fmt.Errorf("invalid attributes of variant %d: %w", variant.ID, err)
+	}
+	return variant, nil
+}
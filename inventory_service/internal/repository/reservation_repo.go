@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type postgresReservationRepository struct {
+	db  *sql.DB
+	log *logrus.Logger
+}
+
+func NewPostgresReservationRepository(db *sql.DB, logger *logrus.Logger) domain.ReservationRepository {
+	return &postgresReservationRepository{
+		db:  db,
+		log: logger,
+	}
+}
+
+func (r *postgresReservationRepository) CreateReservation(ctx context.Context, reservation *domain.Reservation) (*domain.Reservation, []domain.Product, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.CreateReservation")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var (
+		products []domain.Product
+		existing *domain.Reservation
+	)
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		// Inserting the reservation first makes a concurrent retry with the same ID wait on the primary key
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO stock_reservations (id, status, expires_at)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (id) DO NOTHING
+            RETURNING created_at, updated_at`,
+			reservation.ID, domain.ReservationPending, reservation.ExpiresAt,
+		).Scan(&reservation.CreatedAt, &reservation.UpdatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			existing, err = getReservationTx(ctx, tx, reservation.ID, false)
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("could not create reservation: %w", err)
+		}
+
+		products, err = reserveItemsTx(ctx, tx, reservation.Items, domain.StockChange{Reason: domain.ReasonOrderReserve, ReferenceID: reservation.ID})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range reservation.Items {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO stock_reservation_items (reservation_id, product_id, variant_id, quantity) VALUES ($1, $2, NULLIF($3, 0), $4)`,
+				reservation.ID, item.ProductID, item.VariantID, item.Quantity)
+			if err != nil {
+				return fmt.Errorf("could not store reservation item for product %d: %w", item.ProductID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Repository: Reservation failed, nothing was reserved: %v", err)
+		return nil, nil, err
+	}
+
+	if existing != nil {
+		log.Infof("Repository: Reservation %s already exists (%s), nothing reserved again", existing.ID, existing.Status)
+		return existing, []domain.Product{}, nil
+	}
+
+	reservation.Status = domain.ReservationPending
+	log.Infof("Repository: Reservation %s created for %d products (expires at %s)", reservation.ID, len(reservation.Items), reservation.ExpiresAt)
+	return reservation, products, nil
+}
+
+func (r *postgresReservationRepository) ConfirmReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.ConfirmReservation")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var reservation *domain.Reservation
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = getReservationTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		switch reservation.Status {
+		case domain.ReservationConfirmed:
+			return nil
+		case domain.ReservationPending:
+			// The sweeper may not have reached it yet; it will return the stock
+			if !reservation.ExpiresAt.After(time.Now()) {
+				return fmt.Errorf("cannot confirm reservation %s: it has expired", id)
+			}
+			return setReservationStatusTx(ctx, tx, reservation, domain.ReservationConfirmed)
+		default:
+			return fmt.Errorf("cannot confirm reservation %s: it is %s", id, reservation.Status)
+		}
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to confirm reservation %s: %v", id, err)
+		return nil, err
+	}
+	log.Infof("Repository: Reservation %s confirmed", id)
+	return reservation, nil
+}
+
+func (r *postgresReservationRepository) FulfillReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.FulfillReservation")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var reservation *domain.Reservation
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = getReservationTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		switch reservation.Status {
+		case domain.ReservationFulfilled:
+			return nil
+		case domain.ReservationConfirmed:
+			return setReservationStatusTx(ctx, tx, reservation, domain.ReservationFulfilled)
+		default:
+			return fmt.Errorf("cannot fulfill reservation %s: it is %s", id, reservation.Status)
+		}
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to fulfill reservation %s: %v", id, err)
+		return nil, err
+	}
+	log.Infof("Repository: Reservation %s fulfilled", id)
+	return reservation, nil
+}
+
+func (r *postgresReservationRepository) CancelReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.CancelReservation")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var reservation *domain.Reservation
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		var err error
+		reservation, err = getReservationTx(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		switch reservation.Status {
+		case domain.ReservationCancelled, domain.ReservationExpired:
+			return nil
+		case domain.ReservationPending, domain.ReservationConfirmed:
+			// Confirmed stock comes back too when a paid order is cancelled before it ships; fulfilled
+			// stock has left the warehouse and is refused below
+			if _, err := releaseItemsTx(ctx, tx, reservation.Items, domain.StockChange{Reason: domain.ReasonOrderCancel, ReferenceID: id}); err != nil {
+				return err
+			}
+			return setReservationStatusTx(ctx, tx, reservation, domain.ReservationCancelled)
+		default:
+			return fmt.Errorf("cannot cancel reservation %s: it is %s", id, reservation.Status)
+		}
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to cancel reservation %s: %v", id, err)
+		return nil, err
+	}
+	log.Infof("Repository: Reservation %s is %s", id, reservation.Status)
+	return reservation, nil
+}
+
+func (r *postgresReservationRepository) GetReservations(ctx context.Context, ids []string) ([]domain.Reservation, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.GetReservations")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, status, expires_at, created_at, updated_at
+        FROM stock_reservations
+        WHERE id = ANY($1::uuid[])
+        ORDER BY created_at ASC`, pq.Array(ids))
+	if err != nil {
+		log.Errorf("Repository: Failed to get %d reservations: %v", len(ids), err)
+		return nil, fmt.Errorf("could not get reservations: %w", err)
+	}
+	defer rows.Close()
+
+	reservations := []domain.Reservation{}
+	index := make(map[string]int)
+	for rows.Next() {
+		var reservation domain.Reservation
+		if err := rows.Scan(
+			&reservation.ID,
+			&reservation.Status,
+			&reservation.ExpiresAt,
+			&reservation.CreatedAt,
+			&reservation.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		index[reservation.ID] = len(reservations)
+		reservations = append(reservations, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reservations: %w", err)
+	}
+	if len(reservations) == 0 {
+		return reservations, nil
+	}
+
+	itemRows, err := r.db.QueryContext(ctx, `
+        SELECT reservation_id, product_id, COALESCE(variant_id, 0), quantity
+        FROM stock_reservation_items
+        WHERE reservation_id = ANY($1::uuid[])
+        ORDER BY reservation_id, product_id, variant_id ASC`, pq.Array(ids))
+	if err != nil {
+		log.Errorf("Repository: Failed to get items of %d reservations: %v", len(reservations), err)
+		return nil, fmt.Errorf("could not get reservation items: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var (
+			reservationID string
+			item          domain.StockItem
+		)
+		if err := itemRows.Scan(&reservationID, &item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("error scanning reservation item: %w", err)
+		}
+		if i, ok := index[reservationID]; ok {
+			reservations[i].Items = append(reservations[i].Items, item)
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reservation items: %w", err)
+	}
+
+	log.Infof("Repository: Found %d of %d requested reservations", len(reservations), len(ids))
+	return reservations, nil
+}
+
+func (r *postgresReservationRepository) ExpireReservations(ctx context.Context, limit int) (int, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "ReservationRepository.ExpireReservations")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	expired := 0
+	err := withTx(ctx, r.db, r.log, func(tx *sql.Tx) error {
+		// SKIP LOCKED lets several inventory replicas sweep concurrently without waiting on each other
+		rows, err := tx.QueryContext(ctx, `
+            SELECT id
+            FROM stock_reservations
+            WHERE status = 'pending' AND expires_at <= NOW()
+            ORDER BY expires_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED`, limit)
+		if err != nil {
+			return fmt.Errorf("could not select expired reservations: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("error scanning reservation id: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating expired reservations: %w", err)
+		}
+
+		for _, id := range ids {
+			reservation, err := getReservationTx(ctx, tx, id, false)
+			if err != nil {
+				return err
+			}
+			if _, err := releaseItemsTx(ctx, tx, reservation.Items, domain.StockChange{Reason: domain.ReasonReservationExpired, ReferenceID: id}); err != nil {
+				return err
+			}
+			if err := setReservationStatusTx(ctx, tx, reservation, domain.ReservationExpired); err != nil {
+				return err
+			}
+			expired++
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Repository: Failed to expire reservations: %v", err)
+		return 0, err
+	}
+	if expired > 0 {
+		log.Infof("Repository: Expired %d reservations and returned their stock", expired)
+	}
+	return expired, nil
+}
+
+// getReservationTx loads a reservation with its items, optionally locking the reservation row.
+func getReservationTx(ctx context.Context, tx *sql.Tx, id string, forUpdate bool) (*domain.Reservation, error) {
+	query := `
+        SELECT id, status, expires_at, created_at, updated_at
+        FROM stock_reservations
+        WHERE id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	reservation := &domain.Reservation{}
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.Status,
+		&reservation.ExpiresAt,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("reservation with id %s not found", id)
+		}
+		return nil, fmt.Errorf("could not get reservation: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT product_id, COALESCE(variant_id, 0), quantity
+        FROM stock_reservation_items
+        WHERE reservation_id = $1
+        ORDER BY product_id, variant_id ASC`, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get reservation items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.StockItem
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("error scanning reservation item: %w", err)
+		}
+		reservation.Items = append(reservation.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reservation items: %w", err)
+	}
+	return reservation, nil
+}
+
+func setReservationStatusTx(ctx context.Context, tx *sql.Tx, reservation *domain.Reservation, status domain.ReservationStatus) error {
+	err := tx.QueryRowContext(ctx,
+		`UPDATE stock_reservations SET status = $1, updated_at = NOW() WHERE id = $2 RETURNING updated_at`,
+		status, reservation.ID,
+	).Scan(&reservation.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("could not update reservation %s to %s: %w", reservation.ID, status, err)
+	}
+	reservation.Status = status
+	return nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inventory_service/internal/actor"
+	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"inventory_service/internal/tracing"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type postgresStockMovementRepository struct {
+	db  *sql.DB
+	log *logrus.Logger
+}
+
+func NewPostgresStockMovementRepository(db *sql.DB, logger *logrus.Logger) domain.StockMovementRepository {
+	return &postgresStockMovementRepository{
+		db:  db,
+		log: logger,
+	}
+}
+
+func (r *postgresStockMovementRepository) ListStockMovements(ctx context.Context, filter domain.StockMovementFilter) ([]domain.StockMovement, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "StockMovementRepository.ListStockMovements")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	conditions := []string{}
+	args := []interface{}{}
+	addCondition := func(clause string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+	if filter.ProductID != 0 {
+		addCondition("product_id = $%d", filter.ProductID)
+	}
+	if filter.Reason != "" {
+		addCondition("reason = $%d", filter.Reason)
+	}
+	if filter.ReferenceID != "" {
+		addCondition("reference_id = $%d", filter.ReferenceID)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+
+	query := `
+        SELECT id, product_id, COALESCE(variant_id, 0), delta, quantity_after, reason, reference_id, actor, created_at
+        FROM stock_movements`
+	if len(conditions) > 0 {
+		query += "\n        WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf("\n        ORDER BY id DESC\n        LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Repository: Failed to list stock movements: %v", err)
+		return nil, fmt.Errorf("could not list stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []domain.StockMovement{}
+	for rows.Next() {
+		var m domain.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &m.Delta, &m.QuantityAfter, &m.Reason, &m.ReferenceID, &m.Actor, &m.CreatedAt); err != nil {
+			log.Errorf("Repository: Failed to scan stock movement row: %v", err)
+			return nil, fmt.Errorf("error scanning stock movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stock movements: %w", err)
+	}
+	log.Infof("Repository: Retrieved %d stock movements", len(movements))
+	return movements, nil
+}
+
+// recordStockMovementTx appends a ledger entry in the transaction that changed the stock.
+// variantID is 0 for stock kept on the product itself. The actor is taken from ctx.
+func recordStockMovementTx(ctx context.Context, tx *sql.Tx, productID, variantID, delta, quantityAfter int, change domain.StockChange) error {
+	if delta == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO stock_movements (product_id, variant_id, delta, quantity_after, reason, reference_id, actor)
+        VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6, $7)`,
+		productID, variantID, delta, quantityAfter, change.Reason, change.ReferenceID, actor.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("could not record stock movement for product %d: %w", productID, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"inventory_service/internal/requestid"
+
+	"github.com/sirupsen/logrus"
+)
+
+// withTx runs fn inside a transaction, committing on success and rolling back on error or panic.
+func withTx(ctx context.Context, db *sql.DB, logger *logrus.Logger, fn func(tx *sql.Tx) error) (err error) {
+	log := requestid.Logger(ctx, logger)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("Repository: Failed to begin transaction: %v", err)
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Errorf("Repository: Failed to rollback transaction: %v (original error: %v)", rbErr, err)
+			}
+		} else if cErr := tx.Commit(); cErr != nil {
+			log.Errorf("Repository: Failed to commit transaction: %v", cErr)
+			err = fmt.Errorf("failed to commit transaction: %w", cErr)
+		}
+	}()
+
+	return fn(tx)
+}
@@ -1,22 +1,37 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
 
 	"github.com/sirupsen/logrus"
 )
 
 type CategoryUseCase interface {
-	CreateCategory(category *domain.Category) (*domain.Category, error)
-	GetCategoryByID(id int) (*domain.Category, error)
-	UpdateCategory(category *domain.Category) (*domain.Category, error)
-	DeleteCategory(id int) error
-	ListCategories() ([]domain.Category, error)
+	CreateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*domain.Category, error)
+	// UpdateCategory renames a category and, with reparent set, moves it under category.ParentID.
+	UpdateCategory(ctx context.Context, category *domain.Category, reparent bool) (*domain.Category, error)
+	DeleteCategory(ctx context.Context, id int) error
+	// ListCategories returns up to limit categories (default 100, at most 500) after pageToken.
+	ListCategories(ctx context.Context, limit int, pageToken string) (*domain.CategoryPage, error)
+	// GetCategoryTree returns the top-level categories with all their descendants, or only the
+	// subtree of rootID if it is set.
+	GetCategoryTree(ctx context.Context, rootID int) ([]*domain.CategoryNode, error)
+	// GetCategoryPath returns the breadcrumb of a category, from its department down to the category itself.
+	GetCategoryPath(ctx context.Context, id int) ([]domain.Category, error)
 }
 
+// Page sizes of ListCategories; categories are few, so a default page usually holds all of them.
+const (
+	defaultCategoryPageSize = 100
+	maxCategoryPageSize     = 500
+)
+
 type categoryUseCase struct {
 	categoryRepo domain.CategoryRepository
 	log          *logrus.Logger
@@ -29,88 +44,155 @@ func NewCategoryUseCase(repo domain.CategoryRepository, logger *logrus.Logger) C
 	}
 }
 
-func (uc *categoryUseCase) CreateCategory(category *domain.Category) (*domain.Category, error) {
+func (uc *categoryUseCase) CreateCategory(ctx context.Context, category *domain.Category) (*domain.Category, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if category.Name == "" {
-		uc.log.Warn("Use Case: Attempted to create category with empty name")
+		log.Warn("Use Case: Attempted to create category with empty name")
 		return nil, errors.New("category name cannot be empty")
 	}
+	if category.ParentID < 0 {
+		return nil, errors.New("invalid parent category ID")
+	}
 
-	uc.log.Infof("Use Case: Attempting to create category with name '%s'", category.Name)
-	createdCategory, err := uc.categoryRepo.CreateCategory(category)
+	log.Infof("Use Case: Attempting to create category with name '%s' (parent: %d)", category.Name, category.ParentID)
+	createdCategory, err := uc.categoryRepo.CreateCategory(ctx, category)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to create category '%s': %v", category.Name, err)
+		log.Errorf("Use Case: Repository failed to create category '%s': %v", category.Name, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Category '%s' created successfully with ID %d", createdCategory.Name, createdCategory.ID)
+	log.Infof("Use Case: Category '%s' created successfully with ID %d", createdCategory.Name, createdCategory.ID)
 	return createdCategory, nil
 }
 
-func (uc *categoryUseCase) GetCategoryByID(id int) (*domain.Category, error) {
+func (uc *categoryUseCase) GetCategoryByID(ctx context.Context, id int) (*domain.Category, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Attempted to get category with invalid ID: %d", id)
+		log.Warnf("Use Case: Attempted to get category with invalid ID: %d", id)
 		return nil, errors.New("invalid category ID")
 	}
 
-	uc.log.Infof("Use Case: Attempting to get category with ID %d", id)
-	category, err := uc.categoryRepo.GetCategoryByID(id)
+	log.Infof("Use Case: Attempting to get category with ID %d", id)
+	category, err := uc.categoryRepo.GetCategoryByID(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to get category ID %d: %v", id, err)
+		log.Warnf("Use Case: Repository failed to get category ID %d: %v", id, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Category retrieved successfully for ID %d", id)
+	log.Infof("Use Case: Category retrieved successfully for ID %d", id)
 	return category, nil
 }
 
-func (uc *categoryUseCase) UpdateCategory(category *domain.Category) (*domain.Category, error) {
+func (uc *categoryUseCase) UpdateCategory(ctx context.Context, category *domain.Category, reparent bool) (*domain.Category, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if category.ID <= 0 {
-		uc.log.Warnf("Use Case: Attempted update with invalid ID: %d", category.ID)
+		log.Warnf("Use Case: Attempted update with invalid ID: %d", category.ID)
 		return nil, errors.New("invalid category ID for update")
 	}
 	if category.Name == "" {
-		uc.log.Warnf("Use Case: Attempted update for ID %d with empty name", category.ID)
+		log.Warnf("Use Case: Attempted update for ID %d with empty name", category.ID)
 		return nil, errors.New("category name cannot be empty for update")
 	}
+	if reparent && category.ParentID < 0 {
+		return nil, errors.New("invalid parent category ID")
+	}
+	if reparent && category.ParentID == category.ID {
+		return nil, errors.New("invalid parent category: a category cannot be its own parent")
+	}
 
-	uc.log.Infof("Use Case: Attempting to update category ID %d", category.ID)
-	updatedCategory, err := uc.categoryRepo.UpdateCategory(category)
+	log.Infof("Use Case: Attempting to update category ID %d (reparent: %t)", category.ID, reparent)
+	updatedCategory, err := uc.categoryRepo.UpdateCategory(ctx, category, reparent)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to update category ID %d: %v", category.ID, err)
+		log.Errorf("Use Case: Repository failed to update category ID %d: %v", category.ID, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Category updated successfully for ID %d", updatedCategory.ID)
+	log.Infof("Use Case: Category updated successfully for ID %d", updatedCategory.ID)
 	return updatedCategory, nil
 }
 
-func (uc *categoryUseCase) DeleteCategory(id int) error {
+func (uc *categoryUseCase) DeleteCategory(ctx context.Context, id int) error {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Attempted delete with invalid ID: %d", id)
+		log.Warnf("Use Case: Attempted delete with invalid ID: %d", id)
 		return errors.New("invalid category ID for delete")
 	}
 
-	uc.log.Infof("Use Case: Attempting to delete category ID %d", id)
-	err := uc.categoryRepo.DeleteCategory(id)
+	log.Infof("Use Case: Attempting to delete category ID %d", id)
+	err := uc.categoryRepo.DeleteCategory(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to delete category ID %d: %v", id, err)
+		log.Warnf("Use Case: Repository failed to delete category ID %d: %v", id, err)
 		return err
 	}
 
-	uc.log.Infof("Use Case: Category deleted successfully for ID %d", id)
+	log.Infof("Use Case: Category deleted successfully for ID %d", id)
 	return nil
 }
 
-func (uc *categoryUseCase) ListCategories() ([]domain.Category, error) {
-	uc.log.Info("Use Case: Attempting to list all categories")
+func (uc *categoryUseCase) ListCategories(ctx context.Context, limit int, pageToken string) (*domain.CategoryPage, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if limit <= 0 {
+		limit = defaultCategoryPageSize
+	}
+	if limit > maxCategoryPageSize {
+		limit = maxCategoryPageSize
+	}
+	log.Infof("Use Case: Attempting to list categories (limit: %d, continued: %t)", limit, pageToken != "")
 
-	categories, err := uc.categoryRepo.ListCategories()
+	page, err := uc.categoryRepo.ListCategories(ctx, limit, pageToken)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to list categories: %v", err)
+		log.Errorf("Use Case: Repository failed to list categories: %v", err)
 
 		return nil, fmt.Errorf("could not retrieve categories: %w", err)
 	}
 
-	uc.log.Infof("Use Case: Retrieved %d categories", len(categories))
-	return categories, nil
+	log.Infof("Use Case: Retrieved %d of %d categories", len(page.Categories), page.TotalSize)
+	return page, nil
+}
+
+func (uc *categoryUseCase) GetCategoryTree(ctx context.Context, rootID int) ([]*domain.CategoryNode, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if rootID < 0 {
+		return nil, errors.New("invalid category ID")
+	}
+
+	categories, err := uc.categoryRepo.GetCategorySubtree(ctx, rootID)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to load category tree of %d: %v", rootID, err)
+		return nil, err
+	}
+
+	// Categories arrive sorted by name, so appending keeps every level sorted
+	nodes := make(map[int]*domain.CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &domain.CategoryNode{Category: category, Children: []*domain.CategoryNode{}}
+	}
+	roots := []*domain.CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		parent, ok := nodes[category.ParentID]
+		if category.ID == rootID || !ok {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	log.Infof("Use Case: Built category tree of %d with %d categories", rootID, len(categories))
+	return roots, nil
+}
+
+func (uc *categoryUseCase) GetCategoryPath(ctx context.Context, id int) ([]domain.Category, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if id <= 0 {
+		log.Warnf("Use Case: Attempted to get path of category with invalid ID: %d", id)
+		return nil, errors.New("invalid category ID")
+	}
+
+	path, err := uc.categoryRepo.GetCategoryPath(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to get path of category ID %d: %v", id, err)
+		return nil, err
+	}
+	return path, nil
 }
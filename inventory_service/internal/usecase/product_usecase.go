@@ -1,99 +1,245 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"inventory_service/internal/clients"
 	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"sort"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
+// maxGetProducts caps how many products a single GetProducts call may look up.
+const maxGetProducts = 500
+
+// maxGetAppliedReleases caps how many release keys a single GetAppliedReleases call may look up.
+const maxGetAppliedReleases = 100
+
+// maxSKULen caps the length of a variant SKU.
+const maxSKULen = 64
+
+// Page sizes of SearchProducts.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQueryLen  = 200
+)
+
 type ProductUseCase interface {
-	CreateProduct(product *domain.Product) (*domain.Product, error)
-	GetProductByID(id int) (*domain.Product, error)
-	UpdateProduct(id int, updates map[string]interface{}) (*domain.Product, error)
-	DeleteProduct(id int) error
-	ListProducts(limit, offset int) ([]domain.Product, error)
-	ListProductsByCategory(categoryID, limit, offset int) ([]domain.Product, error)
+	CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error)
+	GetProductByID(ctx context.Context, id int) (*domain.Product, error)
+	// GetProducts looks up to maxGetProducts products at once and also returns the requested IDs that do not exist.
+	GetProducts(ctx context.Context, ids []int) ([]domain.Product, []int, error)
+	// UpdateProduct records stock updates in the ledger under change.Reason, which must be
+	// manual_adjust (the default) or restock.
+	UpdateProduct(ctx context.Context, id int, updates map[string]interface{}, change domain.StockChange) (*domain.Product, error)
+	DeleteProduct(ctx context.Context, id int) error
+	// ListProducts pages through products in ID order. pageToken continues from a previous page's
+	// NextPageToken; offset is kept for older clients and only applies without a token.
+	ListProducts(ctx context.Context, limit, offset int, pageToken string) (*domain.ProductPage, error)
+	// ListProductsByCategory includes the products of all descendant categories with includeSubcategories.
+	ListProductsByCategory(ctx context.Context, categoryID int, includeSubcategories bool, limit, offset int, pageToken string) (*domain.ProductPage, error)
+	// SearchProducts ranks by relevance when there is a query and shows the newest products first otherwise.
+	// With includeFacets set, the result also counts all matches by category, price bucket and stock.
+	SearchProducts(ctx context.Context, filter domain.ProductSearchFilter, includeFacets bool) (*domain.ProductSearchResult, error)
+	ReleaseStock(ctx context.Context, items []domain.StockItem, idempotencyKey string) ([]domain.Product, error)
+	// GetAppliedReleases returns which of up to maxGetAppliedReleases idempotency keys a release was applied under.
+	GetAppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error)
+	// CreateVariant adds a sellable variant to a product. A product's first variant can only be added
+	// once the product's own stock is 0 and no reservation or order still holds any of it; from then on
+	// it sells only through its variants.
+	CreateVariant(ctx context.Context, variant *domain.ProductVariant) (*domain.ProductVariant, error)
+	// UpdateVariant works like UpdateProduct. A non-zero productID must be the variant's product.
+	UpdateVariant(ctx context.Context, productID, id int, updates map[string]interface{}, change domain.StockChange) (*domain.ProductVariant, error)
+	DeleteVariant(ctx context.Context, productID, id int) error
+	ListStockMovements(ctx context.Context, filter domain.StockMovementFilter) ([]domain.StockMovement, error)
 }
 
 type productUseCase struct {
 	productRepo  domain.ProductRepository
 	categoryRepo domain.CategoryRepository
+	movementRepo domain.StockMovementRepository
+	orderClient  clients.OrderClient
 	log          *logrus.Logger
 }
 
-func NewProductUseCase(pRepo domain.ProductRepository, cRepo domain.CategoryRepository, logger *logrus.Logger) ProductUseCase {
+func NewProductUseCase(pRepo domain.ProductRepository, cRepo domain.CategoryRepository, mRepo domain.StockMovementRepository, orderClient clients.OrderClient, logger *logrus.Logger) ProductUseCase {
 	return &productUseCase{
 		productRepo:  pRepo,
 		categoryRepo: cRepo,
+		movementRepo: mRepo,
+		orderClient:  orderClient,
 		log:          logger,
 	}
 }
 
-func (uc *productUseCase) CreateProduct(product *domain.Product) (*domain.Product, error) {
+func (uc *productUseCase) CreateProduct(ctx context.Context, product *domain.Product) (*domain.Product, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if product.Name == "" {
-		uc.log.Warn("Use Case: Attempted to create product with empty name")
+		log.Warn("Use Case: Attempted to create product with empty name")
 		return nil, errors.New("product name cannot be empty")
 	}
 	if product.Price <= 0 {
-		uc.log.Warnf("Use Case: Attempted to create product '%s' with invalid price: %f", product.Name, product.Price)
+		log.Warnf("Use Case: Attempted to create product '%s' with invalid price: %f", product.Name, product.Price)
 		return nil, errors.New("product price must be positive")
 	}
 	if product.Stock < 0 {
-		uc.log.Warnf("Use Case: Attempted to create product '%s' with negative stock: %d", product.Name, product.Stock)
+		log.Warnf("Use Case: Attempted to create product '%s' with negative stock: %d", product.Name, product.Stock)
 		return nil, errors.New("product stock cannot be negative")
 	}
 	if product.CategoryID != 0 {
-		_, err := uc.categoryRepo.GetCategoryByID(product.CategoryID)
+		_, err := uc.categoryRepo.GetCategoryByID(ctx, product.CategoryID)
 		if err != nil {
-			uc.log.Warnf("Use Case: Category ID %d not found during product creation: %v", product.CategoryID, err)
+			log.Warnf("Use Case: Category ID %d not found during product creation: %v", product.CategoryID, err)
 			return nil, fmt.Errorf("category with id %d does not exist", product.CategoryID)
 		}
 	}
 
-	uc.log.Infof("Use Case: Attempting to create product '%s'", product.Name)
-	createdProduct, err := uc.productRepo.CreateProduct(product)
+	log.Infof("Use Case: Attempting to create product '%s'", product.Name)
+	createdProduct, err := uc.productRepo.CreateProduct(ctx, product)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to create product '%s': %v", product.Name, err)
+		log.Errorf("Use Case: Repository failed to create product '%s': %v", product.Name, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Product '%s' created successfully with ID %d", createdProduct.Name, createdProduct.ID)
+	log.Infof("Use Case: Product '%s' created successfully with ID %d", createdProduct.Name, createdProduct.ID)
 	return createdProduct, nil
 }
 
-func (uc *productUseCase) GetProductByID(id int) (*domain.Product, error) {
+func (uc *productUseCase) GetProductByID(ctx context.Context, id int) (*domain.Product, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Attempted to get product with invalid ID: %d", id)
+		log.Warnf("Use Case: Attempted to get product with invalid ID: %d", id)
 		return nil, errors.New("invalid product ID")
 	}
 
-	uc.log.Infof("Use Case: Attempting to get product with ID %d", id)
-	product, err := uc.productRepo.GetProductByID(id)
+	log.Infof("Use Case: Attempting to get product with ID %d", id)
+	product, err := uc.productRepo.GetProductByID(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to get product ID %d: %v", id, err)
+		log.Warnf("Use Case: Repository failed to get product ID %d: %v", id, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Product retrieved successfully for ID %d", id)
+	log.Infof("Use Case: Product retrieved successfully for ID %d", id)
 	return product, nil
 }
 
-func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{}) (*domain.Product, error) {
+func (uc *productUseCase) GetProducts(ctx context.Context, ids []int) ([]domain.Product, []int, error) {
+	log := requestid.Logger(ctx, uc.log)
+
+	unique := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, nil, fmt.Errorf("invalid product ID: %d", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, nil, errors.New("invalid request: at least one product ID is required")
+	}
+	if len(unique) > maxGetProducts {
+		return nil, nil, fmt.Errorf("invalid request: at most %d product IDs can be requested at once", maxGetProducts)
+	}
+
+	log.Infof("Use Case: Attempting to get %d products", len(unique))
+	products, err := uc.productRepo.GetProductsByIDs(ctx, unique)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to get %d products: %v", len(unique), err)
+		return nil, nil, err
+	}
+
+	for _, product := range products {
+		delete(seen, product.ID)
+	}
+	missing := make([]int, 0, len(seen))
+	for id := range seen {
+		missing = append(missing, id)
+	}
+	sort.Ints(missing)
+
+	log.Infof("Use Case: Retrieved %d products, %d not found", len(products), len(missing))
+	return products, missing, nil
+}
+
+func (uc *productUseCase) SearchProducts(ctx context.Context, filter domain.ProductSearchFilter, includeFacets bool) (*domain.ProductSearchResult, error) {
+	log := requestid.Logger(ctx, uc.log)
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	if len(filter.Query) > maxSearchQueryLen {
+		return nil, fmt.Errorf("invalid search query: must be at most %d characters", maxSearchQueryLen)
+	}
+	if filter.MinPrice < 0 || filter.MaxPrice < 0 {
+		return nil, errors.New("invalid price range: prices cannot be negative")
+	}
+	if filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice {
+		return nil, errors.New("invalid price range: min_price is greater than max_price")
+	}
+	if filter.CategoryID < 0 {
+		return nil, errors.New("invalid category ID filter")
+	}
+	if filter.Sort == "" {
+		filter.Sort = domain.SortNewest
+		if filter.Query != "" {
+			filter.Sort = domain.SortRelevance
+		}
+	}
+	if !domain.IsValidProductSort(filter.Sort) {
+		return nil, fmt.Errorf("invalid sort %q: expected one of relevance, newest, price_asc, price_desc, name", filter.Sort)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	log.Infof("Use Case: Searching products (query: %q, category: %d, subcategories: %t, price: %.2f-%.2f, in stock only: %t, sort: %s)",
+		filter.Query, filter.CategoryID, filter.IncludeSubcategories, filter.MinPrice, filter.MaxPrice, filter.InStockOnly, filter.Sort)
+	products, err := uc.productRepo.SearchProducts(ctx, filter)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to search products: %v", err)
+		return nil, err
+	}
+	result := &domain.ProductSearchResult{Products: products}
+
+	if includeFacets {
+		// Facets cover every match, not just the requested page
+		result.Facets, err = uc.productRepo.SearchProductFacets(ctx, filter)
+		if err != nil {
+			log.Errorf("Use Case: Repository failed to compute search facets: %v", err)
+			return nil, err
+		}
+	}
+	log.Infof("Use Case: Product search returned %d products", len(products))
+	return result, nil
+}
+
+func (uc *productUseCase) UpdateProduct(ctx context.Context, id int, updates map[string]interface{}, change domain.StockChange) (*domain.Product, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Attempted update with invalid product ID: %d", id)
+		log.Warnf("Use Case: Attempted update with invalid product ID: %d", id)
 		return nil, errors.New("invalid product ID for update")
 	}
 	if len(updates) == 0 {
-		uc.log.Warnf("Use Case: Attempted update for product ID %d with no fields", id)
+		log.Warnf("Use Case: Attempted update for product ID %d with no fields", id)
 
-		return uc.productRepo.GetProductByID(id)
+		return uc.productRepo.GetProductByID(ctx, id)
 	}
 
-	_, err := uc.productRepo.GetProductByID(id)
+	_, err := uc.productRepo.GetProductByID(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Product ID %d not found for update: %v", id, err)
+		log.Warnf("Use Case: Product ID %d not found for update: %v", id, err)
 		return nil, err
 	}
 
@@ -103,14 +249,14 @@ func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{})
 		case "name":
 			name, ok := value.(string)
 			if !ok || name == "" {
-				uc.log.Warnf("Use Case: Invalid or empty 'name' provided for update ID %d", id)
+				log.Warnf("Use Case: Invalid or empty 'name' provided for update ID %d", id)
 				return nil, errors.New("product name cannot be empty if provided for update")
 			}
 			validUpdates[key] = name
 		case "price":
 			price, ok := value.(float64)
 			if !ok || price <= 0 {
-				uc.log.Warnf("Use Case: Invalid or non-positive 'price' provided for update ID %d", id)
+				log.Warnf("Use Case: Invalid or non-positive 'price' provided for update ID %d", id)
 				return nil, errors.New("product price must be positive if provided for update")
 			}
 			validUpdates[key] = price
@@ -121,7 +267,7 @@ func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{})
 			if stockFloat, okFloat := value.(float64); okFloat {
 				stock = int(stockFloat)
 				if float64(stock) != stockFloat {
-					uc.log.Warnf("Use Case: Potential precision loss converting stock '%v' to int for update ID %d", value, id)
+					log.Warnf("Use Case: Potential precision loss converting stock '%v' to int for update ID %d", value, id)
 					return nil, errors.New("invalid type or precision for stock")
 				}
 				ok = true
@@ -131,7 +277,7 @@ func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{})
 			}
 
 			if !ok || stock < 0 {
-				uc.log.Warnf("Use Case: Invalid or negative 'stock' provided for update ID %d", id)
+				log.Warnf("Use Case: Invalid or negative 'stock' provided for update ID %d", id)
 				return nil, errors.New("product stock cannot be negative if provided for update")
 			}
 			validUpdates[key] = stock
@@ -141,7 +287,7 @@ func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{})
 			if catIDFloat, okFloat := value.(float64); okFloat {
 				catID = int(catIDFloat)
 				if float64(catID) != catIDFloat {
-					uc.log.Warnf("Use Case: Potential precision loss converting category_id '%v' to int for update ID %d", value, id)
+					log.Warnf("Use Case: Potential precision loss converting category_id '%v' to int for update ID %d", value, id)
 					return nil, errors.New("invalid type or precision for category_id")
 				}
 				ok = true
@@ -154,95 +300,364 @@ func (uc *productUseCase) UpdateProduct(id int, updates map[string]interface{})
 			}
 
 			if !ok {
-				uc.log.Warnf("Use Case: Invalid type for 'category_id' provided for update ID %d", id)
+				log.Warnf("Use Case: Invalid type for 'category_id' provided for update ID %d", id)
 				return nil, errors.New("invalid type for category_id")
 			}
 
 			if catID == 0 {
 				validUpdates[key] = catID
 			} else if catID > 0 {
-				_, err := uc.categoryRepo.GetCategoryByID(catID)
+				_, err := uc.categoryRepo.GetCategoryByID(ctx, catID)
 				if err != nil {
-					uc.log.Warnf("Use Case: Category ID %d not found during product update for ID %d: %v", catID, id, err)
+					log.Warnf("Use Case: Category ID %d not found during product update for ID %d: %v", catID, id, err)
 					return nil, fmt.Errorf("category with id %d does not exist", catID)
 				}
 				validUpdates[key] = catID
 			} else {
-				uc.log.Warnf("Use Case: Invalid 'category_id' (%d) provided for update ID %d", catID, id)
+				log.Warnf("Use Case: Invalid 'category_id' (%d) provided for update ID %d", catID, id)
 				return nil, errors.New("category_id must be positive or 0/null")
 			}
 
 		default:
-			uc.log.Warnf("Use Case: Attempted to update unknown or unsupported field '%s' for product ID %d", key, id)
+			log.Warnf("Use Case: Attempted to update unknown or unsupported field '%s' for product ID %d", key, id)
 
 		}
 	}
 
 	if len(validUpdates) == 0 {
-		uc.log.Info("Use Case: No valid fields remaining after validation for update ID %d", id)
-		return uc.productRepo.GetProductByID(id)
+		log.Infof("Use Case: No valid fields remaining after validation for update ID %d", id)
+		return uc.productRepo.GetProductByID(ctx, id)
+	}
+
+	if change.Reason == "" {
+		change.Reason = domain.ReasonManualAdjust
+	}
+	if _, ok := validUpdates["stock"]; ok && change.Reason != domain.ReasonManualAdjust && change.Reason != domain.ReasonRestock {
+		log.Warnf("Use Case: Invalid stock change reason '%s' for update ID %d", change.Reason, id)
+		return nil, fmt.Errorf("invalid stock reason '%s': must be %s or %s", change.Reason, domain.ReasonManualAdjust, domain.ReasonRestock)
 	}
 
-	uc.log.Infof("Use Case: Attempting partial update for product ID %d with valid fields: %v", id, validUpdates)
+	log.Infof("Use Case: Attempting partial update for product ID %d with valid fields: %v", id, validUpdates)
 
-	updatedProduct, err := uc.productRepo.UpdateProduct(id, validUpdates)
+	updatedProduct, err := uc.productRepo.UpdateProduct(ctx, id, validUpdates, change)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed partial update for product ID %d: %v", id, err)
+		log.Errorf("Use Case: Repository failed partial update for product ID %d: %v", id, err)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Product updated successfully for ID %d", updatedProduct.ID)
+	log.Infof("Use Case: Product updated successfully for ID %d", updatedProduct.ID)
 	return updatedProduct, nil
 }
 
-func (uc *productUseCase) DeleteProduct(id int) error {
+func (uc *productUseCase) DeleteProduct(ctx context.Context, id int) error {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Attempted delete with invalid product ID: %d", id)
+		log.Warnf("Use Case: Attempted delete with invalid product ID: %d", id)
 		return errors.New("invalid product ID for delete")
 	}
-	uc.log.Infof("Use Case: Attempting to delete product ID %d", id)
-	err := uc.productRepo.DeleteProduct(id)
+	log.Infof("Use Case: Attempting to delete product ID %d", id)
+	err := uc.productRepo.DeleteProduct(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to delete product ID %d: %v", id, err)
+		log.Warnf("Use Case: Repository failed to delete product ID %d: %v", id, err)
 		return err
 	}
-	uc.log.Infof("Use Case: Product deleted successfully for ID %d", id)
+	log.Infof("Use Case: Product deleted successfully for ID %d", id)
 	return nil
 }
 
-func (uc *productUseCase) ListProducts(limit, offset int) ([]domain.Product, error) {
+func (uc *productUseCase) ListProducts(ctx context.Context, limit, offset int, pageToken string) (*domain.ProductPage, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if limit < 0 || offset < 0 {
-		uc.log.Warnf("Use Case: Invalid pagination parameters (limit: %d, offset: %d)", limit, offset)
+		log.Warnf("Use Case: Invalid pagination parameters (limit: %d, offset: %d)", limit, offset)
 	}
-	uc.log.Infof("Use Case: Attempting to list products (limit: %d, offset: %d)", limit, offset)
-	products, err := uc.productRepo.ListProducts(limit, offset)
+	log.Infof("Use Case: Attempting to list products (limit: %d, offset: %d, continued: %t)", limit, offset, pageToken != "")
+	page, err := uc.productRepo.ListProducts(ctx, limit, offset, pageToken)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to list products: %v", err)
+		log.Errorf("Use Case: Repository failed to list products: %v", err)
 		return nil, fmt.Errorf("could not retrieve products: %w", err)
 	}
-	uc.log.Infof("Use Case: Retrieved %d products", len(products))
-	return products, nil
+	log.Infof("Use Case: Retrieved %d of %d products", len(page.Products), page.TotalSize)
+	return page, nil
 }
 
-func (uc *productUseCase) ListProductsByCategory(categoryID, limit, offset int) ([]domain.Product, error) {
+func (uc *productUseCase) ListProductsByCategory(ctx context.Context, categoryID int, includeSubcategories bool, limit, offset int, pageToken string) (*domain.ProductPage, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if categoryID <= 0 {
-		uc.log.Warnf("Use Case: Attempted list by category with invalid category ID: %d", categoryID)
+		log.Warnf("Use Case: Attempted list by category with invalid category ID: %d", categoryID)
 		return nil, errors.New("invalid category ID")
 	}
 	if limit < 0 || offset < 0 {
-		uc.log.Warnf("Use Case: Invalid pagination parameters for category listing (limit: %d, offset: %d)", limit, offset)
+		log.Warnf("Use Case: Invalid pagination parameters for category listing (limit: %d, offset: %d)", limit, offset)
 	}
-	_, err := uc.categoryRepo.GetCategoryByID(categoryID)
+	_, err := uc.categoryRepo.GetCategoryByID(ctx, categoryID)
 	if err != nil {
-		uc.log.Warnf("Use Case: Category ID %d not found: %v", categoryID, err)
+		log.Warnf("Use Case: Category ID %d not found: %v", categoryID, err)
 		return nil, fmt.Errorf("category with id %d not found", categoryID)
 	}
-	uc.log.Infof("Use Case: Attempting to list products for category %d (limit: %d, offset: %d)", categoryID, limit, offset)
-	products, err := uc.productRepo.ListProductsByCategory(categoryID, limit, offset)
+	log.Infof("Use Case: Attempting to list products for category %d (subcategories: %t, limit: %d, offset: %d, continued: %t)",
+		categoryID, includeSubcategories, limit, offset, pageToken != "")
+	page, err := uc.productRepo.ListProductsByCategory(ctx, categoryID, includeSubcategories, limit, offset, pageToken)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to list products for category %d: %v", categoryID, err)
+		log.Errorf("Use Case: Repository failed to list products for category %d: %v", categoryID, err)
 		return nil, fmt.Errorf("could not retrieve products for category %d: %w", categoryID, err)
 	}
-	uc.log.Infof("Use Case: Retrieved %d products for category %d", len(products), categoryID)
+	log.Infof("Use Case: Retrieved %d of %d products for category %d", len(page.Products), page.TotalSize, categoryID)
+	return page, nil
+}
+
+func (uc *productUseCase) ReleaseStock(ctx context.Context, items []domain.StockItem, idempotencyKey string) ([]domain.Product, error) {
+	log := requestid.Logger(ctx, uc.log)
+	merged, err := normalizeStockItems(items)
+	if err != nil {
+		log.Warnf("Use Case: Invalid stock release request: %v", err)
+		return nil, err
+	}
+	log.Infof("Use Case: Attempting to release stock for %d products", len(merged))
+	products, err := uc.productRepo.ReleaseStock(ctx, merged, idempotencyKey)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to release stock: %v", err)
+		return nil, err
+	}
+	log.Infof("Use Case: Stock released for %d products", len(products))
 	return products, nil
 }
+
+func (uc *productUseCase) GetAppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if len(idempotencyKeys) == 0 {
+		return nil, errors.New("invalid request: at least one idempotency key is required")
+	}
+	if len(idempotencyKeys) > maxGetAppliedReleases {
+		return nil, fmt.Errorf("invalid request: at most %d idempotency keys can be requested at once", maxGetAppliedReleases)
+	}
+	for _, key := range idempotencyKeys {
+		if key == "" {
+			return nil, errors.New("invalid request: idempotency key cannot be empty")
+		}
+	}
+
+	log.Infof("Use Case: Looking up %d stock releases", len(idempotencyKeys))
+	applied, err := uc.productRepo.AppliedReleases(ctx, idempotencyKeys)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to look up stock releases: %v", err)
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (uc *productUseCase) CreateVariant(ctx context.Context, variant *domain.ProductVariant) (*domain.ProductVariant, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if variant.ProductID <= 0 {
+		return nil, errors.New("invalid product ID")
+	}
+	variant.SKU = strings.TrimSpace(variant.SKU)
+	if err := validateVariant(variant.SKU, variant.Attributes, variant.Price, variant.Stock); err != nil {
+		log.Warnf("Use Case: Invalid variant for product %d: %v", variant.ProductID, err)
+		return nil, err
+	}
+
+	// Orders placed before reservations took stock straight from the product and give it back to
+	// the product when cancelled, which is impossible once its stock lives in variants. No such
+	// orders are placed any more, so their count can only go down after this check.
+	product, err := uc.productRepo.GetProductByID(ctx, variant.ProductID)
+	if err != nil {
+		log.Warnf("Use Case: Product ID %d not found for new variant: %v", variant.ProductID, err)
+		return nil, err
+	}
+	if len(product.Variants) == 0 {
+		holding, err := uc.orderClient.CountLegacyOrdersHoldingStock(ctx, variant.ProductID)
+		if err != nil {
+			log.Errorf("Use Case: Could not check orders holding stock of product %d: %v", variant.ProductID, err)
+			return nil, fmt.Errorf("could not check orders holding stock of product %d: %w", variant.ProductID, err)
+		}
+		if holding > 0 {
+			log.Warnf("Use Case: %d orders without a reservation still hold stock of product %d", holding, variant.ProductID)
+			return nil, fmt.Errorf("cannot add variants to product %d: %d orders placed without a reservation still hold its stock", variant.ProductID, holding)
+		}
+	}
+
+	log.Infof("Use Case: Attempting to create variant '%s' of product %d", variant.SKU, variant.ProductID)
+	created, err := uc.productRepo.CreateVariant(ctx, variant)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to create variant '%s': %v", variant.SKU, err)
+		return nil, err
+	}
+	log.Infof("Use Case: Variant '%s' created with ID %d", created.SKU, created.ID)
+	return created, nil
+}
+
+func (uc *productUseCase) UpdateVariant(ctx context.Context, productID, id int, updates map[string]interface{}, change domain.StockChange) (*domain.ProductVariant, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if id <= 0 {
+		return nil, errors.New("invalid variant ID for update")
+	}
+	current, err := uc.variantOf(ctx, productID, id)
+	if err != nil {
+		log.Warnf("Use Case: Variant ID %d not found for update: %v", id, err)
+		return nil, err
+	}
+
+	// Validate the variant as it will look after the update
+	sku, attributes, price, stock := current.SKU, current.Attributes, current.Price, current.Stock
+	validUpdates := make(map[string]interface{}, len(updates))
+	for key, value := range updates {
+		var ok bool
+		switch key {
+		case "sku":
+			sku, ok = value.(string)
+			sku = strings.TrimSpace(sku)
+			value = sku
+		case "attributes":
+			attributes, ok = value.(map[string]string)
+		case "price":
+			price, ok = value.(float64)
+		case "stock":
+			stock, ok = value.(int)
+		default:
+			log.Warnf("Use Case: Attempted to update unknown or unsupported field '%s' for variant ID %d", key, id)
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid type for variant %s", key)
+		}
+		validUpdates[key] = value
+	}
+	if err := validateVariant(sku, attributes, price, stock); err != nil {
+		log.Warnf("Use Case: Invalid update for variant ID %d: %v", id, err)
+		return nil, err
+	}
+	if len(validUpdates) == 0 {
+		return current, nil
+	}
+
+	if change.Reason == "" {
+		change.Reason = domain.ReasonManualAdjust
+	}
+	if _, ok := validUpdates["stock"]; ok && change.Reason != domain.ReasonManualAdjust && change.Reason != domain.ReasonRestock {
+		return nil, fmt.Errorf("invalid stock reason '%s': must be %s or %s", change.Reason, domain.ReasonManualAdjust, domain.ReasonRestock)
+	}
+
+	log.Infof("Use Case: Attempting update of variant ID %d with fields: %v", id, validUpdates)
+	updated, err := uc.productRepo.UpdateVariant(ctx, id, validUpdates, change)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to update variant ID %d: %v", id, err)
+		return nil, err
+	}
+	log.Infof("Use Case: Variant updated successfully for ID %d", id)
+	return updated, nil
+}
+
+func (uc *productUseCase) DeleteVariant(ctx context.Context, productID, id int) error {
+	log := requestid.Logger(ctx, uc.log)
+	if id <= 0 {
+		return errors.New("invalid variant ID for delete")
+	}
+	if _, err := uc.variantOf(ctx, productID, id); err != nil {
+		log.Warnf("Use Case: Variant ID %d not found for deletion: %v", id, err)
+		return err
+	}
+	log.Infof("Use Case: Attempting to delete variant ID %d", id)
+	if err := uc.productRepo.DeleteVariant(ctx, id); err != nil {
+		log.Warnf("Use Case: Repository failed to delete variant ID %d: %v", id, err)
+		return err
+	}
+	log.Infof("Use Case: Variant deleted successfully for ID %d", id)
+	return nil
+}
+
+// variantOf loads a variant and checks that it belongs to productID unless that is 0.
+func (uc *productUseCase) variantOf(ctx context.Context, productID, id int) (*domain.ProductVariant, error) {
+	variant, err := uc.productRepo.GetVariantByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if productID != 0 && variant.ProductID != productID {
+		return nil, fmt.Errorf("variant with id %d of product %d not found", id, productID)
+	}
+	return variant, nil
+}
+
+func validateVariant(sku string, attributes map[string]string, price float64, stock int) error {
+	if sku == "" {
+		return errors.New("invalid variant: SKU cannot be empty")
+	}
+	if len(sku) > maxSKULen || strings.ContainsAny(sku, " \t\n") {
+		return fmt.Errorf("invalid variant: SKU must be at most %d characters without spaces", maxSKULen)
+	}
+	for name := range attributes {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("invalid variant: attribute names cannot be empty")
+		}
+	}
+	if price < 0 {
+		return errors.New("invalid variant: price cannot be negative")
+	}
+	if stock < 0 {
+		return errors.New("invalid variant: stock cannot be negative")
+	}
+	return nil
+}
+
+func (uc *productUseCase) ListStockMovements(ctx context.Context, filter domain.StockMovementFilter) ([]domain.StockMovement, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if filter.ProductID < 0 {
+		return nil, errors.New("invalid product ID")
+	}
+	if filter.Reason != "" && !domain.IsValidStockMovementReason(filter.Reason) {
+		return nil, fmt.Errorf("invalid stock movement reason '%s'", filter.Reason)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, errors.New("invalid time range: from must be before to")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	log.Infof("Use Case: Listing stock movements (product: %d, reason: '%s', limit: %d, offset: %d)", filter.ProductID, filter.Reason, filter.Limit, filter.Offset)
+	movements, err := uc.movementRepo.ListStockMovements(ctx, filter)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to list stock movements: %v", err)
+		return nil, fmt.Errorf("could not retrieve stock movements: %w", err)
+	}
+	return movements, nil
+}
+
+// normalizeStockItems validates the items, merges duplicate product variants and sorts them by
+// product and variant ID so concurrent transactions lock rows in the same order and cannot deadlock.
+func normalizeStockItems(items []domain.StockItem) ([]domain.StockItem, error) {
+	if len(items) == 0 {
+		return nil, errors.New("invalid request: at least one stock item is required")
+	}
+	type line struct{ productID, variantID int }
+	quantities := make(map[line]int, len(items))
+	for _, item := range items {
+		if item.ProductID <= 0 {
+			return nil, fmt.Errorf("invalid product ID: %d", item.ProductID)
+		}
+		if item.VariantID < 0 {
+			return nil, fmt.Errorf("invalid variant ID %d for product %d", item.VariantID, item.ProductID)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity %d for product %d: must be positive", item.Quantity, item.ProductID)
+		}
+		quantities[line{item.ProductID, item.VariantID}] += item.Quantity
+	}
+
+	merged := make([]domain.StockItem, 0, len(quantities))
+	for l, quantity := range quantities {
+		merged = append(merged, domain.StockItem{ProductID: l.productID, VariantID: l.variantID, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].ProductID != merged[j].ProductID {
+			return merged[i].ProductID < merged[j].ProductID
+		}
+		return merged[i].VariantID < merged[j].VariantID
+	})
+	return merged, nil
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inventory_service/internal/domain"
+	"inventory_service/internal/requestid"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// maxReservationTTL caps the hold a caller may request so stock cannot be locked away indefinitely.
+const maxReservationTTL = 24 * time.Hour
+
+// maxGetReservations caps how many reservations a single GetReservations call may look up.
+const maxGetReservations = 100
+
+// expireBatchSize is the number of expired reservations released per transaction by the sweeper.
+const expireBatchSize = 100
+
+type ReservationUseCase interface {
+	// ReserveStock holds stock for ttl (the configured default when zero) under a new pending reservation.
+	// An empty id generates one; reusing an existing id returns that reservation without reserving again.
+	ReserveStock(ctx context.Context, id string, items []domain.StockItem, ttl time.Duration) (*domain.Reservation, []domain.Product, error)
+	ConfirmReservation(ctx context.Context, id string) (*domain.Reservation, error)
+	FulfillReservation(ctx context.Context, id string) (*domain.Reservation, error)
+	CancelReservation(ctx context.Context, id string) (*domain.Reservation, error)
+	// GetReservations looks up reservations by ID; IDs that do not exist are left out of the result.
+	GetReservations(ctx context.Context, ids []string) ([]domain.Reservation, error)
+	ExpireReservations(ctx context.Context) (int, error)
+}
+
+type reservationUseCase struct {
+	reservationRepo domain.ReservationRepository
+	defaultTTL      time.Duration
+	log             *logrus.Logger
+}
+
+func NewReservationUseCase(repo domain.ReservationRepository, defaultTTL time.Duration, logger *logrus.Logger) ReservationUseCase {
+	return &reservationUseCase{
+		reservationRepo: repo,
+		defaultTTL:      defaultTTL,
+		log:             logger,
+	}
+}
+
+func (uc *reservationUseCase) ReserveStock(ctx context.Context, id string, items []domain.StockItem, ttl time.Duration) (*domain.Reservation, []domain.Product, error) {
+	log := requestid.Logger(ctx, uc.log)
+	merged, err := normalizeStockItems(items)
+	if err != nil {
+		log.Warnf("Use Case: Invalid stock reservation request: %v", err)
+		return nil, nil, err
+	}
+	if ttl == 0 {
+		ttl = uc.defaultTTL
+	}
+	if ttl < 0 || ttl > maxReservationTTL {
+		log.Warnf("Use Case: Invalid reservation TTL: %s", ttl)
+		return nil, nil, fmt.Errorf("invalid reservation TTL %s: must be between 1s and %s", ttl, maxReservationTTL)
+	}
+
+	if id == "" {
+		id = uuid.NewString()
+	} else if err := validateReservationID(id); err != nil {
+		return nil, nil, err
+	}
+
+	reservation := &domain.Reservation{
+		ID:        id,
+		Items:     merged,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	log.Infof("Use Case: Attempting to reserve stock for %d products (reservation %s, ttl %s)", len(merged), reservation.ID, ttl)
+	created, products, err := uc.reservationRepo.CreateReservation(ctx, reservation)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to reserve stock: %v", err)
+		return nil, nil, err
+	}
+	log.Infof("Use Case: Stock reserved under reservation %s", created.ID)
+	return created, products, nil
+}
+
+func (uc *reservationUseCase) ConfirmReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if err := validateReservationID(id); err != nil {
+		return nil, err
+	}
+	log.Infof("Use Case: Attempting to confirm reservation %s", id)
+	reservation, err := uc.reservationRepo.ConfirmReservation(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to confirm reservation %s: %v", id, err)
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (uc *reservationUseCase) FulfillReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if err := validateReservationID(id); err != nil {
+		return nil, err
+	}
+	log.Infof("Use Case: Attempting to fulfill reservation %s", id)
+	reservation, err := uc.reservationRepo.FulfillReservation(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to fulfill reservation %s: %v", id, err)
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (uc *reservationUseCase) CancelReservation(ctx context.Context, id string) (*domain.Reservation, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if err := validateReservationID(id); err != nil {
+		return nil, err
+	}
+	log.Infof("Use Case: Attempting to cancel reservation %s", id)
+	reservation, err := uc.reservationRepo.CancelReservation(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to cancel reservation %s: %v", id, err)
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (uc *reservationUseCase) GetReservations(ctx context.Context, ids []string) ([]domain.Reservation, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if len(ids) == 0 {
+		return nil, errors.New("invalid request: at least one reservation ID is required")
+	}
+	if len(ids) > maxGetReservations {
+		return nil, fmt.Errorf("invalid request: at most %d reservation IDs can be requested at once", maxGetReservations)
+	}
+	for _, id := range ids {
+		if err := validateReservationID(id); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("Use Case: Looking up %d reservations", len(ids))
+	reservations, err := uc.reservationRepo.GetReservations(ctx, ids)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to get reservations: %v", err)
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// ExpireReservations releases every expired pending reservation, one batch per transaction.
+func (uc *reservationUseCase) ExpireReservations(ctx context.Context) (int, error) {
+	log := requestid.Logger(ctx, uc.log)
+	total := 0
+	for {
+		expired, err := uc.reservationRepo.ExpireReservations(ctx, expireBatchSize)
+		if err != nil {
+			log.Errorf("Use Case: Failed to expire reservations: %v", err)
+			return total, err
+		}
+		total += expired
+		if expired < expireBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Infof("Use Case: Released stock of %d expired reservations", total)
+	}
+	return total, nil
+}
+
+func validateReservationID(id string) error {
+	if id == "" {
+		return errors.New("invalid reservation ID: cannot be empty")
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("invalid reservation ID %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+DROP TABLE stock_reservation_items;
+DROP TABLE stock_reservations;
@@ -0,0 +1,19 @@
+-- Stock held for a pending order. Stock is decremented when the reservation is created;
+-- cancelling or expiring the reservation returns it, confirming makes the decrement permanent.
+CREATE TABLE stock_reservations (
+    id UUID PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'cancelled', 'expired')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE stock_reservation_items (
+    reservation_id UUID NOT NULL REFERENCES stock_reservations(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (reservation_id, product_id)
+);
+
+-- Index for the expiry sweeper
+CREATE INDEX idx_stock_reservations_pending_expiry ON stock_reservations(expires_at) WHERE status = 'pending';
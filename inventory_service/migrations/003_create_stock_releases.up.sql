@@ -0,0 +1,5 @@
+-- Idempotency keys of applied ReleaseStock calls, so a retried release does not return stock twice
+CREATE TABLE stock_releases (
+    idempotency_key TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
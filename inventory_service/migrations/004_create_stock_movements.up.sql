@@ -0,0 +1,26 @@
+-- Append-only ledger of every stock change. product_id has no foreign key so that the
+-- history of deleted products is kept.
+CREATE TABLE stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    delta INT NOT NULL CHECK (delta <> 0),
+    quantity_after INT NOT NULL CHECK (quantity_after >= 0),
+    reason TEXT NOT NULL CHECK (reason IN ('initial_stock', 'restock', 'manual_adjust', 'order_reserve', 'order_cancel', 'reservation_expired')),
+    reference_id TEXT NOT NULL DEFAULT '', -- Reservation ID, release idempotency key or a caller-supplied reference
+    actor TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_stock_movements_product ON stock_movements(product_id, created_at);
+CREATE INDEX idx_stock_movements_reference ON stock_movements(reference_id) WHERE reference_id <> '';
+CREATE INDEX idx_stock_movements_created ON stock_movements(created_at);
+
+CREATE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_no_update_delete
+    BEFORE UPDATE OR DELETE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
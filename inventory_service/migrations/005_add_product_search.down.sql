@@ -0,0 +1,5 @@
+DROP INDEX idx_products_created_at;
+DROP INDEX idx_products_price;
+DROP INDEX idx_products_search;
+ALTER TABLE products DROP COLUMN search_vector;
+ALTER TABLE products DROP COLUMN created_at;
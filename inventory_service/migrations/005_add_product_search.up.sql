@@ -0,0 +1,9 @@
+-- Storefront search. Names are indexed with the 'simple' configuration (no stemming or stop
+-- words) because the catalogue mixes languages.
+ALTER TABLE products ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE products ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('simple', name)) STORED;
+
+CREATE INDEX idx_products_search ON products USING GIN (search_vector);
+CREATE INDEX idx_products_price ON products(price, id);
+CREATE INDEX idx_products_created_at ON products(created_at, id);
@@ -0,0 +1,2 @@
+CREATE INDEX idx_products_category ON products(category_id);
+DROP INDEX idx_products_category_id;
@@ -0,0 +1,3 @@
+-- Keyset pagination of a category's products: WHERE category_id = $1 AND id > $2 ORDER BY id
+CREATE INDEX idx_products_category_id ON products(category_id, id);
+DROP INDEX idx_products_category;
@@ -0,0 +1,4 @@
+DROP INDEX idx_categories_parent_name;
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
+DROP INDEX idx_categories_parent_id;
+ALTER TABLE categories DROP COLUMN parent_id;
@@ -0,0 +1,10 @@
+-- Categories form a tree: departments at the top, then subcategories and leaf categories.
+-- A category cannot be deleted while it still has subcategories.
+ALTER TABLE categories
+    ADD COLUMN parent_id INT REFERENCES categories(id) ON DELETE RESTRICT,
+    ADD CONSTRAINT categories_parent_not_self CHECK (parent_id <> id);
+CREATE INDEX idx_categories_parent_id ON categories(parent_id);
+
+-- Names only have to be unique among siblings, so "Accessories" can exist in several departments
+ALTER TABLE categories DROP CONSTRAINT categories_name_key;
+CREATE UNIQUE INDEX idx_categories_parent_name ON categories(COALESCE(parent_id, 0), name);
@@ -0,0 +1,12 @@
+DROP INDEX idx_stock_movements_variant;
+ALTER TABLE stock_movements DROP COLUMN variant_id;
+
+DELETE FROM stock_reservation_items WHERE variant_id IS NOT NULL;
+DROP INDEX idx_stock_reservation_items_line;
+ALTER TABLE stock_reservation_items
+    DROP COLUMN variant_id,
+    ADD PRIMARY KEY (reservation_id, product_id);
+
+DROP TRIGGER product_variants_stock ON product_variants;
+DROP FUNCTION product_variants_sync_stock();
+DROP TABLE product_variants;
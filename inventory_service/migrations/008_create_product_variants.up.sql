@@ -0,0 +1,42 @@
+-- Variants are the sellable versions of a product, e.g. one size and colour of a shirt. Each has
+-- its own SKU, stock and optionally its own price. A product with variants sells only through
+-- them; products.stock is kept equal to the sum of its variants' stock by a trigger so that
+-- listings, search and facets keep working on the product row.
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku TEXT NOT NULL UNIQUE,
+    attributes JSONB NOT NULL DEFAULT '{}', -- e.g. {"size": "M", "colour": "navy"}
+    price DECIMAL(10, 2) CHECK (price > 0), -- NULL sells at the product's price
+    stock INT NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_product_variants_product ON product_variants(product_id, id);
+
+CREATE FUNCTION product_variants_sync_stock() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE products SET stock = stock - OLD.stock WHERE id = OLD.product_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE products SET stock = stock + NEW.stock WHERE id = NEW.product_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER product_variants_stock
+    AFTER INSERT OR DELETE OR UPDATE OF stock, product_id ON product_variants
+    FOR EACH ROW EXECUTE FUNCTION product_variants_sync_stock();
+
+-- Reservations and the ledger record which variant the stock was taken from; NULL for products
+-- without variants.
+ALTER TABLE stock_reservation_items
+    DROP CONSTRAINT stock_reservation_items_pkey,
+    ADD COLUMN variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX idx_stock_reservation_items_line ON stock_reservation_items(reservation_id, product_id, COALESCE(variant_id, 0));
+
+-- No foreign key, like product_id, so that the history of deleted variants is kept
+ALTER TABLE stock_movements ADD COLUMN variant_id INT;
+CREATE INDEX idx_stock_movements_variant ON stock_movements(variant_id, created_at) WHERE variant_id IS NOT NULL;
@@ -0,0 +1,4 @@
+-- NOT VALID: lines of variants deleted in the meantime would fail the check
+ALTER TABLE stock_reservation_items
+    ADD CONSTRAINT stock_reservation_items_variant_id_fkey
+    FOREIGN KEY (variant_id) REFERENCES product_variants(id) ON DELETE CASCADE NOT VALID;
@@ -0,0 +1,4 @@
+-- Deleting a variant must not silently drop the reservation lines that hold its stock. Like
+-- stock_movements.variant_id the column keeps no foreign key, so the lines of finished reservations
+-- survive the variant; DeleteVariant refuses variants that open reservations still hold.
+ALTER TABLE stock_reservation_items DROP CONSTRAINT stock_reservation_items_variant_id_fkey;
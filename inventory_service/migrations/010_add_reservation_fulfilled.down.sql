@@ -0,0 +1,5 @@
+UPDATE stock_reservations SET status = 'confirmed' WHERE status = 'fulfilled';
+ALTER TABLE stock_reservations DROP CONSTRAINT stock_reservations_status_check;
+ALTER TABLE stock_reservations
+    ADD CONSTRAINT stock_reservations_status_check
+    CHECK (status IN ('pending', 'confirmed', 'cancelled', 'expired'));
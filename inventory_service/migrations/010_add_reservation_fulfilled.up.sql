@@ -0,0 +1,6 @@
+-- A confirmed reservation still holds its stock in the warehouse until the order ships; fulfilled
+-- marks the goods as gone, so only pending and confirmed reservations count as held stock.
+ALTER TABLE stock_reservations DROP CONSTRAINT stock_reservations_status_check;
+ALTER TABLE stock_reservations
+    ADD CONSTRAINT stock_reservations_status_check
+    CHECK (status IN ('pending', 'confirmed', 'fulfilled', 'cancelled', 'expired'));
@@ -0,0 +1,4 @@
+-- NOT VALID: lines of products deleted in the meantime would fail the check
+ALTER TABLE stock_reservation_items
+    ADD CONSTRAINT stock_reservation_items_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE NOT VALID;
@@ -0,0 +1,4 @@
+-- Deleting a product must not silently drop the reservation lines that hold its stock. As with
+-- variant_id (009) the column keeps no foreign key, so the lines of finished reservations survive
+-- the product; DeleteProduct refuses products that open reservations still hold.
+ALTER TABLE stock_reservation_items DROP CONSTRAINT stock_reservation_items_product_id_fkey;
@@ -3,12 +3,18 @@ package main
 import (
 	"context" // Import context
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net"
 	"order_service/config"
+	"order_service/internal/auth"
 	"order_service/internal/clients"
 	grpcHandler "order_service/internal/delivery/grpc"
+	"order_service/internal/healthcheck"
+	"order_service/internal/metrics"
 	"order_service/internal/repository"
+	"order_service/internal/requestid"
+	"order_service/internal/tracing"
 	"order_service/internal/usecase"
 	orderpb "order_service/proto"
 	"os"
@@ -18,7 +24,10 @@ import (
 
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -34,6 +43,18 @@ func main() {
 	}
 	logger.Infof("Starting Order Service (gRPC)...")
 
+	shutdownTracing, err := tracing.Init(context.Background(), "order_service", cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio, logger)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Error shutting down tracing: %v", err)
+		}
+	}()
+
 	database, err := connectDB(cfg.DatabaseURL, logger)
 	if err != nil {
 		logger.Fatalf("FATAL: Failed to connect to database: %v", err)
@@ -52,9 +73,13 @@ func main() {
 	// TODO: Add defer invClient.Close() - requires Close() method in interface/implementation
 
 	orderRepo := repository.NewPostgresOrderRepository(database, logger)
+	outboxRepo := repository.NewPostgresOutboxRepository(database, logger)
 	logger.Info("Repositories initialized.")
 
-	orderUseCase := usecase.NewOrderUseCase(orderRepo, invClient, logger)
+	sagaRelay := usecase.NewSagaRelay(outboxRepo, orderRepo, invClient,
+		cfg.OutboxBatchSize, cfg.OutboxMaxAttempts, cfg.OutboxBaseBackoff, cfg.OutboxMaxBackoff, logger)
+	orderUseCase := usecase.NewOrderUseCase(orderRepo, invClient, sagaRelay, logger)
+	reconciler := usecase.NewReconciler(orderRepo, outboxRepo, invClient, logger)
 	logger.Info("Use cases initialized.")
 
 	orderGrpcHandler := grpcHandler.NewOrderHandler(orderUseCase, logger)
@@ -66,13 +91,41 @@ func main() {
 	}
 	logger.Infof("gRPC server listening on %s", cfg.GrpcPort)
 
-	grpcServer := grpc.NewServer()
+	tokenVerifier := auth.NewTokenVerifier(cfg.JwtSecret, cfg.JwtIssuer)
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor(logger),
+			metrics.UnaryServerInterceptor(),
+			grpcHandler.AuthInterceptor(tokenVerifier, logger),
+		),
+	)
 
 	orderpb.RegisterOrderServiceServer(grpcServer, orderGrpcHandler)
 
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	healthMonitor := healthcheck.NewMonitor(healthServer, []string{orderpb.OrderService_ServiceDesc.ServiceName}, []healthcheck.Check{
+		{Name: "postgres", Probe: database.PingContext},
+		{Name: "inventory_service", Probe: invClient.HealthCheck},
+	}, cfg.HealthCheckInterval, logger)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go healthMonitor.Run(healthCtx)
+	logger.Info("gRPC health service registered")
+
 	reflection.Register(grpcServer)
 	logger.Info("gRPC reflection service registered")
 
+	metricsServer := metrics.Serve(cfg.MetricsPort, logger)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go runOutboxRelay(workersCtx, sagaRelay, cfg.OutboxPollInterval, cfg.OutboxBatchSize, logger)
+	if cfg.ReconcileInterval > 0 {
+		go runReconciler(workersCtx, reconciler, cfg.ReconcileInterval, cfg.ReconcileRepair, logger)
+	}
+
 	serverErrChan := make(chan error, 1)
 	go func() {
 		logger.Info("Starting gRPC server...")
@@ -99,10 +152,20 @@ func main() {
 		}
 	}
 
+	stopWorkers()
+	stopHealth()
+	healthServer.Shutdown()
+
 	logger.Info("Attempting graceful shutdown of gRPC server...")
 	grpcServer.GracefulStop()
 	logger.Info("gRPC server gracefully stopped.")
 
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelMetrics()
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.Errorf("Error shutting down metrics server: %v", err)
+	}
+
 	if clientWithCloser, ok := invClient.(interface{ Close() error }); ok {
 		logger.Info("Closing Inventory gRPC client connection...")
 		if err := clientWithCloser.Close(); err != nil {
@@ -114,6 +177,56 @@ func main() {
 
 }
 
+func runOutboxRelay(ctx context.Context, relay usecase.SagaRelay, interval time.Duration, batchSize int, logger *logrus.Logger) {
+	logger.Infof("Outbox relay started (interval: %s)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Outbox relay stopped.")
+			return
+		case <-ticker.C:
+			// Keep draining while full batches come back so a backlog does not wait for the next tick
+			for ctx.Err() == nil {
+				claimed, err := relay.ProcessDue(ctx)
+				if err != nil {
+					logger.Errorf("Outbox relay failed: %v", err)
+					break
+				}
+				if claimed < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func runReconciler(ctx context.Context, reconciler usecase.Reconciler, interval time.Duration, repair bool, logger *logrus.Logger) {
+	logger.Infof("Inventory reconciler started (interval: %s, repair: %t)", interval, repair)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Inventory reconciler stopped.")
+			return
+		case <-ticker.C:
+			report, err := reconciler.Reconcile(ctx, repair)
+			if err != nil {
+				logger.Errorf("Inventory reconciliation failed: %v", err)
+				continue
+			}
+			if len(report.Discrepancies) > 0 {
+				reportJSON, _ := json.Marshal(report)
+				logger.Warnf("Inventory reconciliation found discrepancies: %s", reportJSON)
+			}
+		}
+	}
+}
+
 func setupLogger(level string) *logrus.Logger {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
@@ -0,0 +1,73 @@
+// Command reconcile compares every order with its inventory reservation once and prints the
+// report as JSON to stdout. It exits with status 2 when discrepancies remain unresolved.
+//
+//	go run ./cmd/reconcile [-repair]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"order_service/config"
+	"order_service/internal/clients"
+	"order_service/internal/repository"
+	"order_service/internal/usecase"
+	"order_service/pkg/db"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	repair := flag.Bool("repair", false, "queue fixes for repairable discrepancies")
+	timeout := flag.Duration("timeout", 10*time.Minute, "abort the run after this long")
+	flag.Parse()
+
+	// Logs go to stderr so that stdout carries only the report
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	logger.SetOutput(os.Stderr)
+
+	cfg := config.LoadConfig(logger)
+	if logLevel, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
+		logger.SetLevel(logLevel)
+	}
+
+	database, err := db.Connect(cfg.DatabaseURL)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to connect to database: %v", err)
+	}
+	defer database.Close()
+
+	invClient, err := clients.NewInventoryGRPCClient(cfg.InventoryServiceGrpcAddr, logger, 5*time.Second)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to create Inventory gRPC client: %v", err)
+	}
+
+	reconciler := usecase.NewReconciler(
+		repository.NewPostgresOrderRepository(database, logger),
+		repository.NewPostgresOutboxRepository(database, logger),
+		invClient,
+		logger,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report, err := reconciler.Reconcile(ctx, *repair)
+	if err != nil {
+		logger.Fatalf("FATAL: Reconciliation failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.Fatalf("FATAL: Failed to write report: %v", err)
+	}
+
+	if report.Unresolved() > 0 {
+		cancel()
+		database.Close()
+		os.Exit(2)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -15,6 +16,24 @@ type Config struct {
 	GrpcPort                 string `envconfig:"GRPC_PORT"                 default:":50052"`
 	LogLevel                 string `envconfig:"LOG_LEVEL"                 default:"info"`
 	InventoryServiceGrpcAddr string `envconfig:"INVENTORY_SERVICE_GRPC_ADDR" required:"true"`
+	JwtSecret                string `envconfig:"JWT_SECRET"                required:"true"`
+	JwtIssuer                string `envconfig:"JWT_ISSUER"                default:"user_service"`
+	MetricsPort              string `envconfig:"METRICS_PORT"              default:":9092"` // Prometheus /metrics listener
+
+	HealthCheckInterval time.Duration `envconfig:"HEALTH_CHECK_INTERVAL" default:"5s"`
+
+	OutboxPollInterval time.Duration `envconfig:"OUTBOX_POLL_INTERVAL" default:"2s"`
+	OutboxBatchSize    int           `envconfig:"OUTBOX_BATCH_SIZE"    default:"50"`
+	OutboxMaxAttempts  int           `envconfig:"OUTBOX_MAX_ATTEMPTS"  default:"15"` // Before a saga step is given up and compensated
+	OutboxBaseBackoff  time.Duration `envconfig:"OUTBOX_BASE_BACKOFF"  default:"1s"`
+	OutboxMaxBackoff   time.Duration `envconfig:"OUTBOX_MAX_BACKOFF"   default:"5m"`
+
+	ReconcileInterval time.Duration `envconfig:"RECONCILE_INTERVAL" default:"1h"` // 0 disables the background reconciler
+	ReconcileRepair   bool          `envconfig:"RECONCILE_REPAIR"   default:"false"`
+
+	TracingExporter     string  `envconfig:"TRACING_EXPORTER"      default:"none"` // none, stdout or otlp
+	TracingOTLPEndpoint string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+	TracingSampleRatio  float64 `envconfig:"TRACING_SAMPLE_RATIO"  default:"1"`
 }
 
 var (
@@ -46,6 +65,9 @@ func LoadConfig(logger *logrus.Logger) *Config {
 		if config.InventoryServiceGrpcAddr == "" {
 			logger.Fatal("Configuration error: INVENTORY_SERVICE_GRPC_ADDR is not set")
 		}
+		if config.JwtSecret == "" {
+			logger.Fatal("Configuration error: JWT_SECRET is not set")
+		}
 
 	})
 	return &config
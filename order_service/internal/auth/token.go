@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"fmt"
+	"order_service/internal/domain"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claims mirrors the access token claims issued by user_service.
+type claims struct {
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenVerifier checks access tokens forwarded by the API gateway in the x-auth-token metadata.
+type TokenVerifier struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+func NewTokenVerifier(secret, issuer string) *TokenVerifier {
+	return &TokenVerifier{
+		secret: []byte(secret),
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithIssuer(issuer),
+			jwt.WithExpirationRequired(),
+		),
+	}
+}
+
+// Verify validates the token signature, issuer and expiry and returns the caller it identifies.
+func (v *TokenVerifier) Verify(token string) (*domain.Caller, error) {
+	c := &claims{}
+	_, err := v.parser.ParseWithClaims(token, c, func(t *jwt.Token) (interface{}, error) {
+		return v.secret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil || userID <= 0 {
+		return nil, fmt.Errorf("invalid token: bad subject %q", c.Subject)
+	}
+	return &domain.Caller{UserID: userID, Roles: c.Roles}, nil
+}
@@ -2,16 +2,20 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"order_service/internal/requestid"
 	inventorypb "order_service/proto/inventorypb"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 type Product struct {
@@ -19,11 +23,91 @@ type Product struct {
 	Name  string
 	Price float64
 	Stock int
+	// Variants is set for products sold in sizes, colours and the like; such a product can only
+	// be ordered through one of them.
+	Variants []ProductVariant
+}
+
+// ProductVariant is one sellable version of a product. Price already falls back to the
+// product's price when the variant has no price of its own.
+type ProductVariant struct {
+	ID    int
+	SKU   string
+	Price float64
+	Stock int
+}
+
+// Variant returns the variant with the given ID.
+func (p Product) Variant(id int) (ProductVariant, bool) {
+	for _, variant := range p.Variants {
+		if variant.ID == id {
+			return variant, true
+		}
+	}
+	return ProductVariant{}, false
+}
+
+// StockItem is a quantity of a product to reserve or release. VariantID is 0 for products
+// without variants.
+type StockItem struct {
+	ProductID int
+	VariantID int
+	Quantity  int
+}
+
+// Reservation statuses reported by inventory_service.
+const (
+	ReservationPending   = "pending"
+	ReservationConfirmed = "confirmed"
+	ReservationFulfilled = "fulfilled"
+	ReservationCancelled = "cancelled"
+	ReservationExpired   = "expired"
+)
+
+// Reservation is inventory's view of the stock held for an order
+type Reservation struct {
+	ID        string
+	Status    string
+	Items     []StockItem
+	ExpiresAt time.Time
+}
+
+// RejectionError is returned when inventory_service refused a stock call on its merits
+// (insufficient stock, unknown product or reservation, invalid request); retrying it will not help.
+type RejectionError struct {
+	Message string
+}
+
+func (e *RejectionError) Error() string {
+	return e.Message
+}
+
+// IsRejection reports whether err is a definitive refusal rather than a transient failure.
+func IsRejection(err error) bool {
+	var rejection *RejectionError
+	return errors.As(err, &rejection)
 }
 
 type InventoryClient interface {
 	GetProduct(ctx context.Context, productID int) (*Product, error)
-	UpdateStock(ctx context.Context, productID int, newStock int) error
+	// GetProducts looks up to 500 distinct products in one call, keyed by ID; unknown IDs are left out
+	GetProducts(ctx context.Context, productIDs []int) (map[int]Product, error)
+	// ReserveStock holds stock for all items (all-or-nothing) under the caller-chosen reservation ID.
+	// Repeating the call with the same ID is safe. The hold expires unless confirmed;
+	// CancelReservation returns it immediately.
+	ReserveStock(ctx context.Context, reservationID string, items []StockItem) error
+	ConfirmReservation(ctx context.Context, reservationID string) error
+	// FulfillReservation marks a confirmed reservation as shipped; repeating the call is safe
+	FulfillReservation(ctx context.Context, reservationID string) error
+	CancelReservation(ctx context.Context, reservationID string) error
+	// ReleaseStock returns stock for orders placed without a reservation; a release already
+	// applied under idempotencyKey is not applied again
+	ReleaseStock(ctx context.Context, items []StockItem, idempotencyKey string) error
+	// GetAppliedReleases returns which of up to 100 release idempotency keys were applied
+	GetAppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error)
+	// GetReservations looks up to 100 reservations by ID; unknown IDs are left out
+	GetReservations(ctx context.Context, reservationIDs []string) ([]Reservation, error)
+	HealthCheck(ctx context.Context) error
 }
 
 type inventoryGRPCClient struct {
@@ -40,6 +124,8 @@ func NewInventoryGRPCClient(target string, logger *logrus.Logger, timeout time.D
 	conn, err := grpc.DialContext(ctx, target,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
+		grpc.WithChainUnaryInterceptor(requestid.UnaryClientInterceptor(), actorUnaryClientInterceptor()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 	)
 	if err != nil {
 		logger.Errorf("InventoryClient: Failed to dial %s: %v", target, err)
@@ -56,6 +142,16 @@ func NewInventoryGRPCClient(target string, logger *logrus.Logger, timeout time.D
 	}, nil
 }
 
+// actorMetadataKey tells inventory_service on whose behalf stock is changed; it is recorded in the stock ledger.
+const actorMetadataKey = "x-actor"
+
+func actorUnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, actorMetadataKey, "service:order_service")
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
 func (c *inventoryGRPCClient) Close() error {
 	if c.conn != nil {
 		c.log.Info("InventoryClient: Closing gRPC connection")
@@ -88,12 +184,7 @@ func (c *inventoryGRPCClient) GetProduct(ctx context.Context, productID int) (*P
 		return nil, fmt.Errorf("failed to communicate with inventory service: %w", err)
 	}
 
-	product := &Product{
-		ID:    int(res.GetId()),
-		Name:  res.GetName(),
-		Price: res.GetPrice(),
-		Stock: int(res.GetStock()),
-	}
+	product := mapProtoProduct(res)
 
 	c.log.Infof("InventoryClient(gRPC): Parsed product data for ID %d: Name='%s', Stock=%d",
 		productID, product.Name, product.Stock)
@@ -101,44 +192,222 @@ func (c *inventoryGRPCClient) GetProduct(ctx context.Context, productID int) (*P
 	return product, nil
 }
 
-func (c *inventoryGRPCClient) UpdateStock(ctx context.Context, productID int, newStock int) error {
-	c.log.Infof("InventoryClient(gRPC): Requesting stock update for ID %d to %d", productID, newStock)
-	if newStock < 0 {
-		return fmt.Errorf("stock cannot be negative") // Basic validation
+func (c *inventoryGRPCClient) ReserveStock(ctx context.Context, reservationID string, items []StockItem) error {
+	c.log.Infof("InventoryClient(gRPC): Requesting stock reservation %s for %d items", reservationID, len(items))
+	req := &inventorypb.ReserveStockRequest{Items: mapStockItemsToProto(items), ReservationId: reservationID}
+
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := c.client.ReserveStock(callCtx, req)
+	if err != nil {
+		return c.stockError("ReserveStock", err)
+	}
+
+	reservation := res.GetReservation()
+	c.log.Infof("InventoryClient(gRPC): Reservation %s for %d items is %s (expires at %s)",
+		reservation.GetId(), len(items), reservation.GetStatus(), reservation.GetExpiresAt().AsTime().Format(time.RFC3339))
+	return nil
+}
+
+func (c *inventoryGRPCClient) ReleaseStock(ctx context.Context, items []StockItem, idempotencyKey string) error {
+	c.log.Infof("InventoryClient(gRPC): Requesting stock release for %d items (key %q)", len(items), idempotencyKey)
+	req := &inventorypb.ReleaseStockRequest{Items: mapStockItemsToProto(items), IdempotencyKey: idempotencyKey}
+
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if _, err := c.client.ReleaseStock(callCtx, req); err != nil {
+		return c.stockError("ReleaseStock", err)
 	}
 
-	req := &inventorypb.UpdateProductRequest{
-		Product: &inventorypb.Product{
-			Id:    int64(productID),
-			Stock: int32(newStock),
-		},
-		UpdateMask: &fieldmaskpb.FieldMask{
-			Paths: []string{"stock"},
-		},
+	c.log.Infof("InventoryClient(gRPC): Successfully released stock for %d items", len(items))
+	return nil
+}
+
+func (c *inventoryGRPCClient) ConfirmReservation(ctx context.Context, reservationID string) error {
+	c.log.Infof("InventoryClient(gRPC): Confirming reservation %s", reservationID)
+
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if _, err := c.client.ConfirmReservation(callCtx, &inventorypb.ConfirmReservationRequest{ReservationId: reservationID}); err != nil {
+		return c.stockError("ConfirmReservation", err)
 	}
+	return nil
+}
+
+func (c *inventoryGRPCClient) FulfillReservation(ctx context.Context, reservationID string) error {
+	c.log.Infof("InventoryClient(gRPC): Fulfilling reservation %s", reservationID)
 
 	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	_, err := c.client.UpdateProduct(callCtx, req)
+	if _, err := c.client.FulfillReservation(callCtx, &inventorypb.FulfillReservationRequest{ReservationId: reservationID}); err != nil {
+		return c.stockError("FulfillReservation", err)
+	}
+	return nil
+}
+
+func (c *inventoryGRPCClient) CancelReservation(ctx context.Context, reservationID string) error {
+	c.log.Infof("InventoryClient(gRPC): Cancelling reservation %s", reservationID)
+
+	callCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := c.client.CancelReservation(callCtx, &inventorypb.CancelReservationRequest{ReservationId: reservationID})
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok {
-			if st.Code() == codes.NotFound {
-				c.log.Warnf("InventoryClient(gRPC): Product with ID %d not found for stock update", productID)
-				return fmt.Errorf("product with ID %d not found in inventory for update", productID)
-			}
-			if st.Code() == codes.InvalidArgument {
-				c.log.Warnf("InventoryClient(gRPC): Invalid request updating stock for ID %d: %s", productID, st.Message())
-				return fmt.Errorf("invalid stock update request for product %d: %s", productID, st.Message())
-			}
-			c.log.Errorf("InventoryClient(gRPC): UpdateProduct failed for ID %d with code %s: %s", productID, st.Code(), st.Message())
-			return fmt.Errorf("inventory service gRPC error (%s): %s", st.Code(), st.Message())
+		return c.stockError("CancelReservation", err)
+	}
+	c.log.Infof("InventoryClient(gRPC): Reservation %s is %s", reservationID, res.GetStatus())
+	return nil
+}
+
+func (c *inventoryGRPCClient) GetProducts(ctx context.Context, productIDs []int) (map[int]Product, error) {
+	c.log.Infof("InventoryClient(gRPC): Requesting product info for %d IDs", len(productIDs))
+	req := &inventorypb.GetProductsRequest{Ids: make([]int64, 0, len(productIDs))}
+	for _, id := range productIDs {
+		req.Ids = append(req.Ids, int64(id))
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := c.client.GetProducts(callCtx, req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			c.log.Errorf("InventoryClient(gRPC): GetProducts failed with code %s: %s", st.Code(), st.Message())
+			return nil, fmt.Errorf("inventory service gRPC error (%s): %s", st.Code(), st.Message())
+		}
+		c.log.Errorf("InventoryClient(gRPC): Failed to execute GetProducts request: %v", err)
+		return nil, fmt.Errorf("failed to communicate with inventory service: %w", err)
+	}
+
+	products := make(map[int]Product, len(res.GetProducts()))
+	for _, p := range res.GetProducts() {
+		products[int(p.GetId())] = *mapProtoProduct(p)
+	}
+	if len(res.GetMissingIds()) > 0 {
+		c.log.Warnf("InventoryClient(gRPC): Products not found in inventory: %v", res.GetMissingIds())
+	}
+	c.log.Infof("InventoryClient(gRPC): Received %d products", len(products))
+	return products, nil
+}
+
+func (c *inventoryGRPCClient) GetReservations(ctx context.Context, reservationIDs []string) ([]Reservation, error) {
+	c.log.Infof("InventoryClient(gRPC): Requesting %d reservations", len(reservationIDs))
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := c.client.GetReservations(callCtx, &inventorypb.GetReservationsRequest{ReservationIds: reservationIDs})
+	if err != nil {
+		return nil, c.stockError("GetReservations", err)
+	}
+
+	reservations := make([]Reservation, 0, len(res.GetReservations()))
+	for _, r := range res.GetReservations() {
+		reservation := Reservation{
+			ID:        r.GetId(),
+			Status:    r.GetStatus(),
+			Items:     make([]StockItem, 0, len(r.GetItems())),
+			ExpiresAt: r.GetExpiresAt().AsTime(),
+		}
+		for _, item := range r.GetItems() {
+			reservation.Items = append(reservation.Items, StockItem{
+				ProductID: int(item.GetProductId()),
+				VariantID: int(item.GetVariantId()),
+				Quantity:  int(item.GetQuantity()),
+			})
+		}
+		reservations = append(reservations, reservation)
+	}
+	c.log.Infof("InventoryClient(gRPC): Received %d of %d reservations", len(reservations), len(reservationIDs))
+	return reservations, nil
+}
+
+func (c *inventoryGRPCClient) GetAppliedReleases(ctx context.Context, idempotencyKeys []string) ([]string, error) {
+	c.log.Infof("InventoryClient(gRPC): Requesting %d stock releases", len(idempotencyKeys))
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := c.client.GetAppliedReleases(callCtx, &inventorypb.GetAppliedReleasesRequest{IdempotencyKeys: idempotencyKeys})
+	if err != nil {
+		return nil, c.stockError("GetAppliedReleases", err)
+	}
+	c.log.Infof("InventoryClient(gRPC): %d of %d stock releases were applied", len(res.GetIdempotencyKeys()), len(idempotencyKeys))
+	return res.GetIdempotencyKeys(), nil
+}
+
+// stockError translates a stock call failure into an error the order use case understands;
+// refusals become a *RejectionError, everything else is treated as transient.
+func (c *inventoryGRPCClient) stockError(method string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		c.log.Errorf("InventoryClient(gRPC): Failed to execute %s request: %v", method, err)
+		return fmt.Errorf("failed to communicate with inventory service: %w", err)
+	}
+	switch st.Code() {
+	case codes.FailedPrecondition:
+		c.log.Warnf("InventoryClient(gRPC): %s rejected: %s", method, st.Message())
+		return &RejectionError{Message: st.Message()}
+	case codes.NotFound:
+		c.log.Warnf("InventoryClient(gRPC): %s failed, not found: %s", method, st.Message())
+		return &RejectionError{Message: fmt.Sprintf("inventory check failed: %s", st.Message())}
+	case codes.InvalidArgument:
+		c.log.Warnf("InventoryClient(gRPC): Invalid %s request: %s", method, st.Message())
+		return &RejectionError{Message: fmt.Sprintf("invalid stock request: %s", st.Message())}
+	default:
+		c.log.Errorf("InventoryClient(gRPC): %s failed with code %s: %s", method, st.Code(), st.Message())
+		return fmt.Errorf("inventory service gRPC error (%s): %s", st.Code(), st.Message())
+	}
+}
+
+func mapStockItemsToProto(items []StockItem) []*inventorypb.StockItem {
+	result := make([]*inventorypb.StockItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, &inventorypb.StockItem{
+			ProductId: int64(item.ProductID),
+			VariantId: int64(item.VariantID),
+			Quantity:  int32(item.Quantity),
+		})
+	}
+	return result
+}
+
+func mapProtoProduct(p *inventorypb.Product) *Product {
+	product := &Product{
+		ID:    int(p.GetId()),
+		Name:  p.GetName(),
+		Price: p.GetPrice(),
+		Stock: int(p.GetStock()),
+	}
+	for _, v := range p.GetVariants() {
+		price := v.GetPrice()
+		if price == 0 {
+			price = p.GetPrice()
 		}
-		c.log.Errorf("InventoryClient(gRPC): Failed to execute UpdateStock request for ID %d: %v", productID, err)
-		return fmt.Errorf("failed to communicate with inventory service for stock update: %w", err)
+		product.Variants = append(product.Variants, ProductVariant{
+			ID:    int(v.GetId()),
+			SKU:   v.GetSku(),
+			Price: price,
+			Stock: int(v.GetStock()),
+		})
 	}
+	return product
+}
 
-	c.log.Infof("InventoryClient(gRPC): Successfully updated stock for product ID %d to %d", productID, newStock)
+// HealthCheck asks the inventory service's grpc.health.v1 endpoint whether it is serving.
+func (c *inventoryGRPCClient) HealthCheck(ctx context.Context) error {
+	res, err := healthpb.NewHealthClient(c.conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: inventorypb.InventoryService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		return fmt.Errorf("inventory service health check failed: %w", err)
+	}
+	if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("inventory service is %s", res.GetStatus())
+	}
 	return nil
 }
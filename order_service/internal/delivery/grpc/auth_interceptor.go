@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+	"order_service/internal/auth"
+	"order_service/internal/domain"
+	"order_service/internal/requestid"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthInterceptor resolves the caller from the x-auth-token metadata and attaches it to the
+// request context. Requests without a token pass through; the use case decides whether
+// an anonymous call is allowed.
+func AuthInterceptor(verifier *auth.TokenVerifier, logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+		tokens := md.Get("x-auth-token")
+		if len(tokens) == 0 || tokens[0] == "" {
+			return handler(ctx, req)
+		}
+
+		caller, err := verifier.Verify(tokens[0])
+		if err != nil {
+			requestid.Logger(ctx, logger).Warnf("gRPC Interceptor: Rejected x-auth-token for %s: %v", info.FullMethod, err)
+			return nil, status.Error(codes.Unauthenticated, "Invalid or expired auth token")
+		}
+
+		requestid.Logger(ctx, logger).Debugf("gRPC Interceptor: %s called by UserID %d (roles %v)", info.FullMethod, caller.UserID, caller.Roles)
+		return handler(domain.ContextWithCaller(ctx, caller), req)
+	}
+}
@@ -30,10 +30,20 @@ func mapProtoStatusToDomain(protoStatus orderpb.OrderStatus) domain.OrderStatus
 	switch protoStatus {
 	case orderpb.OrderStatus_PENDING:
 		return domain.StatusPending
+	case orderpb.OrderStatus_PAID:
+		return domain.StatusPaid
+	case orderpb.OrderStatus_PROCESSING:
+		return domain.StatusProcessing
+	case orderpb.OrderStatus_SHIPPED:
+		return domain.StatusShipped
+	case orderpb.OrderStatus_DELIVERED:
+		return domain.StatusDelivered
 	case orderpb.OrderStatus_COMPLETED:
 		return domain.StatusCompleted
 	case orderpb.OrderStatus_CANCELLED:
 		return domain.StatusCancelled
+	case orderpb.OrderStatus_REFUNDED:
+		return domain.StatusRefunded
 	default:
 		return ""
 	}
@@ -43,10 +53,20 @@ func mapDomainStatusToProto(domainStatus domain.OrderStatus) orderpb.OrderStatus
 	switch domainStatus {
 	case domain.StatusPending:
 		return orderpb.OrderStatus_PENDING
+	case domain.StatusPaid:
+		return orderpb.OrderStatus_PAID
+	case domain.StatusProcessing:
+		return orderpb.OrderStatus_PROCESSING
+	case domain.StatusShipped:
+		return orderpb.OrderStatus_SHIPPED
+	case domain.StatusDelivered:
+		return orderpb.OrderStatus_DELIVERED
 	case domain.StatusCompleted:
 		return orderpb.OrderStatus_COMPLETED
 	case domain.StatusCancelled:
 		return orderpb.OrderStatus_CANCELLED
+	case domain.StatusRefunded:
+		return orderpb.OrderStatus_REFUNDED
 	default:
 		return orderpb.OrderStatus_ORDER_STATUS_UNSPECIFIED
 	}
@@ -58,6 +78,7 @@ func mapProtoItemsToDomain(protoItems []*orderpb.OrderItem) []domain.OrderItem {
 		if item != nil {
 			domainItems = append(domainItems, domain.OrderItem{
 				ProductID: int(item.GetProductId()),
+				VariantID: int(item.GetVariantId()),
 				Quantity:  int(item.GetQuantity()),
 				Price:     item.GetPrice(),
 			})
@@ -71,6 +92,8 @@ func mapDomainItemsToProto(domainItems []domain.OrderItem) []*orderpb.OrderItem
 	for _, item := range domainItems {
 		protoItems = append(protoItems, &orderpb.OrderItem{
 			ProductId: int64(item.ProductID),
+			VariantId: int64(item.VariantID),
+			Sku:       item.SKU,
 			Quantity:  int32(item.Quantity),
 			Price:     item.Price,
 		})
@@ -89,6 +112,7 @@ func mapDomainOrderToProto(order *domain.Order) *orderpb.Order {
 		Status:    mapDomainStatusToProto(order.Status),
 		CreatedAt: timestamppb.New(order.CreatedAt),
 		UpdatedAt: timestamppb.New(order.UpdatedAt),
+		Total:     order.Total(),
 	}
 }
 
@@ -104,8 +128,9 @@ func (h *OrderHandler) CreateOrder(ctx context.Context, req *orderpb.CreateOrder
 	}
 
 	domainOrder := &domain.Order{
-		UserID: int(userID),
-		Items:  mapProtoItemsToDomain(req.GetItems()),
+		UserID:         int(userID),
+		Items:          mapProtoItemsToDomain(req.GetItems()),
+		IdempotencyKey: strings.TrimSpace(req.GetIdempotencyKey()),
 	}
 
 	createdOrder, err := h.useCase.CreateOrder(ctx, domainOrder)
@@ -123,19 +148,15 @@ func (h *OrderHandler) GetOrder(ctx context.Context, req *orderpb.GetOrderReques
 	orderID := int(req.GetId())
 	h.log.Infof("gRPC Handler: Received GetOrder request for OrderID: %d", orderID)
 
-	// TODO (Future): Extract UserID from context metadata for authorization check
-
 	if orderID <= 0 {
 		return nil, status.Error(codes.InvalidArgument, "Invalid Order ID")
 	}
-	order, err := h.useCase.GetOrderByID(orderID)
+	order, err := h.useCase.GetOrderByID(ctx, orderID)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: GetOrderByID use case error for OrderID %d: %v", orderID, err)
 		return nil, mapOrderDomainErrorToGrpcStatus(err)
 	}
 
-	// TODO (Future): Perform authorization check here
-
 	h.log.Infof("gRPC Handler: Order retrieved successfully: OrderID=%d", order.ID)
 	return mapDomainOrderToProto(order), nil
 }
@@ -145,8 +166,6 @@ func (h *OrderHandler) UpdateOrderStatus(ctx context.Context, req *orderpb.Updat
 	newStatus := mapProtoStatusToDomain(req.GetStatus())
 	h.log.Infof("gRPC Handler: Received UpdateOrderStatus request for OrderID: %d to Status: %s", orderID, newStatus)
 
-	// TODO (Future): Extract UserID from context metadata for authorization check
-
 	if orderID <= 0 {
 		return nil, status.Error(codes.InvalidArgument, "Invalid Order ID")
 	}
@@ -154,9 +173,7 @@ func (h *OrderHandler) UpdateOrderStatus(ctx context.Context, req *orderpb.Updat
 		return nil, status.Errorf(codes.InvalidArgument, "Invalid status value provided: %s", newStatus)
 	}
 
-	// TODO (Future): Get current order first to check ownership *before* calling update use case
-
-	updatedOrder, err := h.useCase.UpdateOrderStatus(ctx, orderID, newStatus)
+	updatedOrder, err := h.useCase.UpdateOrderStatus(ctx, orderID, newStatus, strings.TrimSpace(req.GetReason()))
 	if err != nil {
 		h.log.Errorf("gRPC Handler: UpdateOrderStatus use case error for OrderID %d: %v", orderID, err)
 		return nil, mapOrderDomainErrorToGrpcStatus(err)
@@ -172,35 +189,137 @@ func (h *OrderHandler) ListOrders(ctx context.Context, req *orderpb.ListOrdersRe
 	offset := int(req.GetOffset())
 	h.log.Infof("gRPC Handler: Received ListOrders request for UserID: %d, Limit: %d, Offset: %d", userID, limit, offset)
 
-	// TODO (Future): Compare userID from request with UserID from metadata for authorization
-
 	if userID <= 0 {
 		return nil, status.Error(codes.InvalidArgument, "Invalid User ID")
 	}
 
-	orders, err := h.useCase.ListOrdersByUserID(userID, limit, offset)
+	page, err := h.useCase.ListOrdersByUserID(ctx, userID, limit, offset, req.GetPageToken())
 	if err != nil {
 		h.log.Errorf("gRPC Handler: ListOrdersByUserID use case error for UserID %d: %v", userID, err)
 		return nil, mapOrderDomainErrorToGrpcStatus(err)
 	}
 
 	resp := &orderpb.ListOrdersResponse{
-		Orders: make([]*orderpb.Order, 0, len(orders)),
+		Orders:        make([]*orderpb.Order, 0, len(page.Orders)),
+		NextPageToken: page.NextPageToken,
+		TotalSize:     int32(page.TotalSize),
 	}
-	for i := range orders {
-		resp.Orders = append(resp.Orders, mapDomainOrderToProto(&orders[i]))
+	for i := range page.Orders {
+		resp.Orders = append(resp.Orders, mapDomainOrderToProto(&page.Orders[i]))
 	}
 
 	h.log.Infof("gRPC Handler: Listed %d orders successfully for UserID %d", len(resp.Orders), userID)
 	return resp, nil
 }
 
+func (h *OrderHandler) SearchOrders(ctx context.Context, req *orderpb.SearchOrdersRequest) (*orderpb.SearchOrdersResponse, error) {
+	h.log.Infof("gRPC Handler: Received SearchOrders request: Statuses=%v, UserID=%d, ProductID=%d, SortBy=%s, Limit=%d",
+		req.GetStatuses(), req.GetUserId(), req.GetProductId(), req.GetSortBy(), req.GetLimit())
+
+	filter := domain.OrderSearchFilter{
+		UserID:    int(req.GetUserId()),
+		ProductID: int(req.GetProductId()),
+		MinTotal:  req.GetMinTotal(),
+		SortBy:    domain.OrderSortField(req.GetSortBy()),
+		Ascending: req.GetAscending(),
+		Limit:     int(req.GetLimit()),
+		Cursor:    req.GetCursor(),
+	}
+	for _, protoStatus := range req.GetStatuses() {
+		domainStatus := mapProtoStatusToDomain(protoStatus)
+		if domainStatus == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid status filter: %s", protoStatus)
+		}
+		filter.Statuses = append(filter.Statuses, domainStatus)
+	}
+	if req.GetCreatedFrom() != nil {
+		filter.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		filter.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+
+	result, err := h.useCase.SearchOrders(ctx, filter)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: SearchOrders use case error: %v", err)
+		return nil, mapOrderDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &orderpb.SearchOrdersResponse{
+		Orders:     make([]*orderpb.Order, 0, len(result.Orders)),
+		NextCursor: result.NextCursor,
+	}
+	for i := range result.Orders {
+		resp.Orders = append(resp.Orders, mapDomainOrderToProto(&result.Orders[i]))
+	}
+	h.log.Infof("gRPC Handler: Order search returned %d orders", len(resp.Orders))
+	return resp, nil
+}
+
+func (h *OrderHandler) GetOrderHistory(ctx context.Context, req *orderpb.GetOrderHistoryRequest) (*orderpb.GetOrderHistoryResponse, error) {
+	orderID := int(req.GetId())
+	h.log.Infof("gRPC Handler: Received GetOrderHistory request for OrderID: %d", orderID)
+
+	if orderID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid Order ID")
+	}
+	changes, err := h.useCase.GetOrderHistory(ctx, orderID)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GetOrderHistory use case error for OrderID %d: %v", orderID, err)
+		return nil, mapOrderDomainErrorToGrpcStatus(err)
+	}
+
+	resp := &orderpb.GetOrderHistoryResponse{
+		OrderId: int64(orderID),
+		Changes: make([]*orderpb.OrderStatusChange, 0, len(changes)),
+	}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, &orderpb.OrderStatusChange{
+			Id:         change.ID,
+			FromStatus: mapDomainStatusToProto(change.FromStatus),
+			ToStatus:   mapDomainStatusToProto(change.ToStatus),
+			Actor:      change.Actor,
+			Reason:     change.Reason,
+			ChangedAt:  timestamppb.New(change.ChangedAt),
+		})
+	}
+
+	h.log.Infof("gRPC Handler: Returned %d status changes for OrderID %d", len(resp.Changes), orderID)
+	return resp, nil
+}
+
+func (h *OrderHandler) CountLegacyOrdersHoldingStock(ctx context.Context, req *orderpb.CountLegacyOrdersHoldingStockRequest) (*orderpb.CountLegacyOrdersHoldingStockResponse, error) {
+	productID := int(req.GetProductId())
+	h.log.Infof("gRPC Handler: Received CountLegacyOrdersHoldingStock request for ProductID: %d", productID)
+
+	if productID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Invalid Product ID")
+	}
+	count, err := h.useCase.CountLegacyOrdersHoldingStock(ctx, productID)
+	if err != nil {
+		h.log.Warnf("gRPC Handler: CountLegacyOrdersHoldingStock use case error for ProductID %d: %v", productID, err)
+		return nil, mapOrderDomainErrorToGrpcStatus(err)
+	}
+	return &orderpb.CountLegacyOrdersHoldingStockResponse{Count: int64(count)}, nil
+}
+
 func mapOrderDomainErrorToGrpcStatus(err error) error {
 	if err == nil {
 		return nil
 	}
 	errMsg := strings.ToLower(err.Error())
 
+	if strings.Contains(errMsg, "unauthenticated") {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if strings.Contains(errMsg, "permission denied") {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if strings.Contains(errMsg, "idempotency key conflict") {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+
 	if strings.Contains(errMsg, "insufficient stock") {
 		return status.Error(codes.FailedPrecondition, err.Error()) // Or ResourceExhausted?
 	}
@@ -208,7 +327,10 @@ func mapOrderDomainErrorToGrpcStatus(err error) error {
 
 		return status.Error(codes.NotFound, err.Error())
 	}
-	if strings.Contains(errMsg, "cannot cancel a completed order") || strings.Contains(errMsg, "cannot change status of a cancelled order") {
+	if strings.Contains(errMsg, "cannot confirm reservation") {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if strings.Contains(errMsg, "status transition not allowed") {
 		return status.Error(codes.FailedPrecondition, err.Error())
 	}
 
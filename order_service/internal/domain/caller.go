@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// Caller is the authenticated user on whose behalf an RPC is executed.
+type Caller struct {
+	UserID int
+	Roles  []string
+}
+
+// IsStaff reports whether the caller may act on orders of other users.
+func (c *Caller) IsStaff() bool {
+	for _, role := range c.Roles {
+		if role == "staff" || role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the caller may use administrative features such as searching all orders.
+func (c *Caller) IsAdmin() bool {
+	for _, role := range c.Roles {
+		if role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+type callerKey struct{}
+
+func ContextWithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller attached by the gRPC auth interceptor, if any.
+func CallerFromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(*Caller)
+	return caller, ok && caller != nil
+}
+
+// ActorSagaRelay is recorded for status changes the saga makes on its own, such as aborting an
+// order whose stock could not be reserved.
+const ActorSagaRelay = "system:saga_relay"
+
+// ActorReconciler is recorded for status changes made by the reconciler's repairs.
+const ActorReconciler = "system:reconciler"
+
+type actorKey struct{}
+
+// ContextWithActor overrides who is recorded as making changes in ctx.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext names who is acting in ctx: an actor set with ContextWithActor, otherwise the
+// authenticated caller as "user:<id>", otherwise "unknown".
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	if caller, ok := CallerFromContext(ctx); ok {
+		return fmt.Sprintf("user:%d", caller.UserID)
+	}
+	return "unknown"
+}
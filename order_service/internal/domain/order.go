@@ -8,43 +8,93 @@ import (
 type OrderStatus string
 
 const (
-	StatusPending   OrderStatus = "pending"
-	StatusCompleted OrderStatus = "completed"
-	StatusCancelled OrderStatus = "cancelled"
+	StatusPending    OrderStatus = "pending"
+	StatusPaid       OrderStatus = "paid"
+	StatusProcessing OrderStatus = "processing"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCompleted  OrderStatus = "completed"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusRefunded   OrderStatus = "refunded"
 )
 
 type Order struct {
-	ID        int         `json:"id"`
-	UserID    int         `json:"user_id"`
-	Items     []OrderItem `json:"items"`
-	Status    OrderStatus `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID            int         `json:"id"`
+	UserID        int         `json:"user_id"`
+	Items         []OrderItem `json:"items"`
+	Status        OrderStatus `json:"status"`
+	ReservationID string      `json:"reservation_id,omitempty"` // Inventory stock hold; empty for legacy orders
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	// IdempotencyKey is the client's key for the request that created the order, if it sent one.
+	// IdempotencyFingerprint identifies that request's items so a replay can be checked against it.
+	IdempotencyKey         string `json:"-"`
+	IdempotencyFingerprint string `json:"-"`
+}
+
+// Total is the amount charged for the order.
+func (o *Order) Total() float64 {
+	total := 0.0
+	for _, item := range o.Items {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
 }
 
 type OrderItem struct {
-	ProductID int     `json:"product_id"`
+	ProductID int `json:"product_id"`
+	// VariantID is the size, colour or other version ordered; 0 for products without variants.
+	// SKU is copied from inventory when the order is placed.
+	VariantID int     `json:"variant_id,omitempty"`
+	SKU       string  `json:"sku,omitempty"`
 	Quantity  int     `json:"quantity"`
 	Price     float64 `json:"price"`
 }
 
+// OrderRepository writes the given outbox events in the same transaction as the order change
+// and sets their ID and OrderID.
 type OrderRepository interface {
-	CreateOrder(order *Order) (*Order, error)
-	GetOrderByID(id int) (*Order, error)
-	UpdateOrderStatus(id int, status OrderStatus) (*Order, error)
-	ListOrdersByUserID(userID int, limit, offset int) ([]Order, error)
+	CreateOrder(ctx context.Context, order *Order, events ...*OutboxEvent) (*Order, error)
+	GetOrderByID(ctx context.Context, id int) (*Order, error)
+	// GetOrderByIdempotencyKey returns the order the user created with this key, or nil if there is none.
+	GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (*Order, error)
+	// UpdateOrderStatus only moves an order that is still in the from status, so a concurrent
+	// change between reading the order and updating it fails instead of being overwritten.
+	// The change is added to the order's status history with the actor from ctx (see ActorFromContext).
+	UpdateOrderStatus(ctx context.Context, id int, from, to OrderStatus, reason string, events ...*OutboxEvent) (*Order, error)
+	// ListOrdersByUserID pages through a user's orders, newest first. pageToken is the NextPageToken
+	// of the previous page; offset is only applied to the first page.
+	ListOrdersByUserID(ctx context.Context, userID int, limit, offset int, pageToken string) (*OrderPage, error)
+	// ListStatusHistory returns the status changes of an order, oldest first.
+	ListStatusHistory(ctx context.Context, orderID int) ([]OrderStatusChange, error)
+	SearchOrders(ctx context.Context, filter OrderSearchFilter) (*OrderSearchResult, error)
+	// ScanOrders returns up to limit orders of all users with an ID greater than afterID, in ID order.
+	ScanOrders(ctx context.Context, afterID int, limit int) ([]Order, error)
+	// CountLegacyOrdersHoldingStock counts orders placed without a reservation that contain the
+	// product and have not given its stock back yet: they are in one of the given statuses or
+	// still have a pending stock release.
+	CountLegacyOrdersHoldingStock(ctx context.Context, productID int, statuses []OrderStatus) (int, error)
 }
 
+// OrderUseCase methods read the caller from ctx (see CallerFromContext) and only let
+// customers touch their own orders; staff may act on any order.
 type OrderUseCase interface {
 	CreateOrder(ctx context.Context, order *Order) (*Order, error)
-	GetOrderByID(id int) (*Order, error)
-	UpdateOrderStatus(ctx context.Context, id int, status OrderStatus) (*Order, error)
-	ListOrdersByUserID(userID int, limit, offset int) ([]Order, error)
+	GetOrderByID(ctx context.Context, id int) (*Order, error)
+	UpdateOrderStatus(ctx context.Context, id int, status OrderStatus, reason string) (*Order, error)
+	ListOrdersByUserID(ctx context.Context, userID int, limit, offset int, pageToken string) (*OrderPage, error)
+	GetOrderHistory(ctx context.Context, id int) ([]OrderStatusChange, error)
+	// SearchOrders is restricted to admins.
+	SearchOrders(ctx context.Context, filter OrderSearchFilter) (*OrderSearchResult, error)
+	// CountLegacyOrdersHoldingStock is restricted to staff. inventory_service uses it to make sure
+	// no order placed without a reservation still expects to return stock to the product.
+	CountLegacyOrdersHoldingStock(ctx context.Context, productID int) (int, error)
 }
 
 func IsValidStatus(status OrderStatus) bool {
 	switch status {
-	case StatusPending, StatusCompleted, StatusCancelled:
+	case StatusPending, StatusPaid, StatusProcessing, StatusShipped, StatusDelivered,
+		StatusCompleted, StatusCancelled, StatusRefunded:
 		return true
 	default:
 		return false
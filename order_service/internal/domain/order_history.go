@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// MaxStatusReasonLength bounds the free-text reason stored with a status change.
+const MaxStatusReasonLength = 500
+
+// OrderStatusChange is one entry of an order's status history.
+type OrderStatusChange struct {
+	ID         int64       `json:"id"`
+	OrderID    int         `json:"order_id"`
+	FromStatus OrderStatus `json:"from_status,omitempty"` // Empty for the entry recorded when the order was created
+	ToStatus   OrderStatus `json:"to_status"`
+	Actor      string      `json:"actor"` // See ActorFromContext
+	Reason     string      `json:"reason,omitempty"`
+	ChangedAt  time.Time   `json:"changed_at"`
+}
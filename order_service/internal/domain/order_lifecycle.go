@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TransitionEffect is a set of side effects that run when an order changes status.
+type TransitionEffect uint8
+
+const (
+	// EffectConfirmStock makes the order's inventory reservation permanent
+	EffectConfirmStock TransitionEffect = 1 << iota
+	// EffectReleaseStock returns the order's stock to inventory
+	EffectReleaseStock
+	// EffectFulfillStock marks the order's confirmed reservation as shipped, so its stock is no
+	// longer held
+	EffectFulfillStock
+	// EffectRefund pays the order total back to the customer
+	EffectRefund
+)
+
+// Has reports whether all effects in other are part of e.
+func (e TransitionEffect) Has(other TransitionEffect) bool {
+	return e&other == other
+}
+
+// orderTransitions lists every status an order may move to from its current status, together
+// with the side effects of that move. The happy path is
+//
+//	pending -> paid -> processing -> shipped -> delivered -> completed
+//
+// Orders can be cancelled until they ship and refunded right after payment or once delivered.
+// Cancelled and refunded orders are final.
+var orderTransitions = map[OrderStatus]map[OrderStatus]TransitionEffect{
+	StatusPending: {
+		StatusPaid:      EffectConfirmStock,
+		StatusCompleted: EffectConfirmStock | EffectFulfillStock, // Orders settled outside the payment flow
+		StatusCancelled: EffectReleaseStock,
+	},
+	StatusPaid: {
+		StatusProcessing: 0,
+		StatusCancelled:  EffectReleaseStock | EffectRefund,
+		StatusRefunded:   EffectReleaseStock | EffectRefund,
+	},
+	StatusProcessing: {
+		StatusShipped:   EffectFulfillStock,
+		StatusCancelled: EffectReleaseStock | EffectRefund,
+	},
+	StatusShipped: {
+		StatusDelivered: 0,
+	},
+	// Delivered goods have left the warehouse, so refunding them does not return stock
+	StatusDelivered: {
+		StatusCompleted: 0,
+		StatusRefunded:  EffectRefund,
+	},
+	StatusCompleted: {
+		StatusRefunded: EffectRefund,
+	},
+}
+
+// TransitionEffects returns the side effects of moving an order from one status to another,
+// or an error if the order lifecycle does not allow that move.
+func TransitionEffects(from, to OrderStatus) (TransitionEffect, error) {
+	effects, ok := orderTransitions[from][to]
+	if !ok {
+		return 0, fmt.Errorf("status transition not allowed: %s -> %s", from, to)
+	}
+	return effects, nil
+}
+
+// StockHoldingStatuses lists the statuses in which an order still holds stock: those it can
+// leave with EffectReleaseStock.
+func StockHoldingStatuses() []OrderStatus {
+	statuses := []OrderStatus{}
+	for from, targets := range orderTransitions {
+		for _, effects := range targets {
+			if effects.Has(EffectReleaseStock) {
+				statuses = append(statuses, from)
+				break
+			}
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
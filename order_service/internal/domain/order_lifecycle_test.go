@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransitionEffects(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    OrderStatus
+		to      OrderStatus
+		want    TransitionEffect
+		wantErr bool
+	}{
+		{name: "pay", from: StatusPending, to: StatusPaid, want: EffectConfirmStock},
+		{name: "settle outside payment flow", from: StatusPending, to: StatusCompleted, want: EffectConfirmStock | EffectFulfillStock},
+		{name: "cancel unpaid", from: StatusPending, to: StatusCancelled, want: EffectReleaseStock},
+		{name: "start processing", from: StatusPaid, to: StatusProcessing, want: 0},
+		{name: "cancel paid", from: StatusPaid, to: StatusCancelled, want: EffectReleaseStock | EffectRefund},
+		{name: "refund paid", from: StatusPaid, to: StatusRefunded, want: EffectReleaseStock | EffectRefund},
+		{name: "ship", from: StatusProcessing, to: StatusShipped, want: EffectFulfillStock},
+		{name: "cancel processing", from: StatusProcessing, to: StatusCancelled, want: EffectReleaseStock | EffectRefund},
+		{name: "deliver", from: StatusShipped, to: StatusDelivered, want: 0},
+		{name: "complete", from: StatusDelivered, to: StatusCompleted, want: 0},
+		{name: "refund delivered keeps stock out", from: StatusDelivered, to: StatusRefunded, want: EffectRefund},
+		{name: "refund completed keeps stock out", from: StatusCompleted, to: StatusRefunded, want: EffectRefund},
+		{name: "skip payment", from: StatusPending, to: StatusShipped, wantErr: true},
+		{name: "cancel shipped", from: StatusShipped, to: StatusCancelled, wantErr: true},
+		{name: "revive cancelled", from: StatusCancelled, to: StatusPending, wantErr: true},
+		{name: "leave refunded", from: StatusRefunded, to: StatusCompleted, wantErr: true},
+		{name: "stay put", from: StatusPaid, to: StatusPaid, wantErr: true},
+		{name: "unknown status", from: OrderStatus("lost"), to: StatusCancelled, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TransitionEffects(tt.from, tt.to)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("TransitionEffects(%s, %s) = %v, want an error", tt.from, tt.to, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TransitionEffects(%s, %s) returned error: %v", tt.from, tt.to, err)
+			}
+			if got != tt.want {
+				t.Errorf("TransitionEffects(%s, %s) = %b, want %b", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitionEffectHas(t *testing.T) {
+	tests := []struct {
+		name    string
+		effects TransitionEffect
+		other   TransitionEffect
+		want    bool
+	}{
+		{name: "single effect", effects: EffectReleaseStock | EffectRefund, other: EffectRefund, want: true},
+		{name: "all of several", effects: EffectReleaseStock | EffectRefund, other: EffectReleaseStock | EffectRefund, want: true},
+		{name: "only some of several", effects: EffectRefund, other: EffectReleaseStock | EffectRefund, want: false},
+		{name: "missing effect", effects: EffectConfirmStock, other: EffectReleaseStock, want: false},
+		{name: "no effects", effects: 0, other: EffectFulfillStock, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.effects.Has(tt.other); got != tt.want {
+				t.Errorf("%b.Has(%b) = %t, want %t", tt.effects, tt.other, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStockHoldingStatuses(t *testing.T) {
+	want := []OrderStatus{StatusPaid, StatusPending, StatusProcessing}
+	if got := StockHoldingStatuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("StockHoldingStatuses() = %v, want %v", got, want)
+	}
+
+	// Every status listed must be able to return its stock, and every other one must not
+	holding := make(map[OrderStatus]bool)
+	for _, status := range StockHoldingStatuses() {
+		holding[status] = true
+	}
+	for from, targets := range orderTransitions {
+		releases := false
+		for _, effects := range targets {
+			releases = releases || effects.Has(EffectReleaseStock)
+		}
+		if releases != holding[from] {
+			t.Errorf("status %s: releases stock on some transition = %t, listed as holding stock = %t", from, releases, holding[from])
+		}
+	}
+}
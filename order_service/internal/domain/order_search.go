@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// OrderSortField is a column SearchOrders can sort by; ties are broken by order ID.
+type OrderSortField string
+
+const (
+	OrderSortCreatedAt OrderSortField = "created_at"
+	OrderSortTotal     OrderSortField = "total"
+)
+
+// OrderSearchFilter selects orders across all users. Zero values leave a filter out.
+type OrderSearchFilter struct {
+	Statuses    []OrderStatus
+	UserID      int
+	CreatedFrom time.Time // Inclusive
+	CreatedTo   time.Time // Exclusive
+	ProductID   int       // Orders containing this product
+	MinTotal    float64
+	SortBy      OrderSortField
+	Ascending   bool
+	Limit       int
+	Cursor      string // NextCursor of the previous page, searched with the same filter and sorting
+}
+
+// OrderSearchResult is one page of a search; NextCursor is empty on the last page.
+type OrderSearchResult struct {
+	Orders     []Order
+	NextCursor string
+}
+
+// OrderPage is one page of a user's orders. NextPageToken is empty on the last page and
+// TotalSize counts all orders of the user.
+type OrderPage struct {
+	Orders        []Order
+	NextPageToken string
+	TotalSize     int
+}
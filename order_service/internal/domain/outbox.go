@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Saga steps delivered to inventory_service through the outbox.
+const (
+	EventStockReserve = "stock.reserve"
+	EventStockRelease = "stock.release"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxDone    OutboxStatus = "done"
+	OutboxFailed  OutboxStatus = "failed" // Gave up or rejected; needs attention if it is a compensation
+)
+
+// OutboxEvent is a saga step stored in the same transaction as the order change that caused it.
+type OutboxEvent struct {
+	ID            int64             `json:"id"`
+	OrderID       int               `json:"order_id"`
+	Type          string            `json:"type"`
+	Payload       StockEventPayload `json:"payload"`
+	Status        OutboxStatus      `json:"status"`
+	Attempts      int               `json:"attempts"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	LastError     string            `json:"last_error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// StockEventPayload carries everything needed to repeat the inventory call safely.
+type StockEventPayload struct {
+	ReservationID  string      `json:"reservation_id,omitempty"`
+	IdempotencyKey string      `json:"idempotency_key,omitempty"` // Used by releases of orders without a reservation
+	Items          []OrderItem `json:"items"`
+}
+
+type OutboxRepository interface {
+	// ClaimDueEvents leases up to limit due pending events so that other workers skip them until the lease ends.
+	ClaimDueEvents(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error)
+	// ClaimEvent leases a single event; it returns nil if the event is not pending or is held by another worker.
+	ClaimEvent(ctx context.Context, id int64, lease time.Duration) (*OutboxEvent, error)
+	MarkEventDone(ctx context.Context, id int64) error
+	ScheduleRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	MarkEventFailed(ctx context.Context, id int64, lastError string) error
+	// EnqueueEvents stores new events for existing orders; each event must have OrderID set.
+	EnqueueEvents(ctx context.Context, events ...*OutboxEvent) error
+	// OrdersWithPendingEvents reports which of the given orders still have undelivered events.
+	OrdersWithPendingEvents(ctx context.Context, orderIDs []int) (map[int]bool, error)
+	// OrdersWithEvent reports which of the given orders have an event of the given type in any status.
+	OrdersWithEvent(ctx context.Context, orderIDs []int, eventType string) (map[int]bool, error)
+	// LatestEvent returns the newest event of the given type for the order, or nil if there is none.
+	LatestEvent(ctx context.Context, orderID int, eventType string) (*OutboxEvent, error)
+}
@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// DiscrepancyKind names a way an order and its inventory reservation can disagree.
+type DiscrepancyKind string
+
+const (
+	// A cancelled or refunded order whose reservation still holds stock
+	DiscrepancyStockNotReturned DiscrepancyKind = "stock_not_returned"
+	// A live order with no reservation in inventory and no saga step left to create it
+	DiscrepancyReservationMissing DiscrepancyKind = "reservation_missing"
+	// A live order whose reservation expired or was cancelled, so its stock is no longer held. A pending
+	// order can no longer be paid then and is repaired by cancelling it.
+	DiscrepancyReservationLapsed DiscrepancyKind = "reservation_lapsed"
+	// A paid or later order whose reservation was never confirmed and will be returned to stock on expiry
+	DiscrepancyReservationUnconfirmed DiscrepancyKind = "reservation_unconfirmed"
+	// A shipped or later order whose reservation is still confirmed, so its stock counts as held
+	DiscrepancyReservationUnfulfilled DiscrepancyKind = "reservation_unfulfilled"
+	// The reserved quantities differ from the order items
+	DiscrepancyQuantityMismatch DiscrepancyKind = "quantity_mismatch"
+	// A cancelled or refunded order placed without a reservation whose release went through the outbox
+	// but which inventory has no record of applying
+	DiscrepancyReleaseUnverified DiscrepancyKind = "release_unverified"
+)
+
+// Discrepancy is a single order found out of sync with inventory.
+type Discrepancy struct {
+	OrderID           int             `json:"order_id"`
+	OrderStatus       OrderStatus     `json:"order_status"`
+	ReservationID     string          `json:"reservation_id"`               // Empty for orders placed without a reservation
+	ReservationStatus string          `json:"reservation_status,omitempty"` // Empty if inventory has no such reservation
+	Kind              DiscrepancyKind `json:"kind"`
+	Detail            string          `json:"detail"`
+	Repairable        bool            `json:"repairable"`
+	Repaired          bool            `json:"repaired"`
+	RepairError       string          `json:"repair_error,omitempty"`
+}
+
+// ReconciliationReport is the machine-readable result of one reconciliation run.
+type ReconciliationReport struct {
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Repair        bool      `json:"repair"`
+	OrdersChecked int       `json:"orders_checked"`
+	// Orders with a saga step in flight, and orders placed without a reservation that are live or
+	// returned their stock before releases went through the outbox
+	OrdersSkipped int           `json:"orders_skipped"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Unresolved counts the discrepancies that are still present after the run.
+func (r *ReconciliationReport) Unresolved() int {
+	count := 0
+	for _, d := range r.Discrepancies {
+		if !d.Repaired {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons used for the stock reservation failure counter.
+const (
+	ReasonInsufficientStock = "insufficient_stock"
+	ReasonInventoryError    = "inventory_error"
+)
+
+// Operations used for the rollback failure counter.
+const (
+	OperationCancel = "cancel"
+)
+
+var (
+	OrdersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_created_total",
+		Help: "Total number of orders created.",
+	})
+
+	OrdersCancelled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_cancelled_total",
+		Help: "Total number of orders cancelled.",
+	})
+
+	OrdersRefunded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orders_refunded_total",
+		Help: "Total number of orders whose payment is due back to the customer.",
+	})
+
+	StockReservationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_stock_reservation_failures_total",
+		Help: "Total number of orders rejected because stock could not be reserved, by reason.",
+	}, []string{"reason"})
+
+	// RollbackFailures counts inventory compensations that failed and need manual intervention.
+	RollbackFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_inventory_rollback_failures_total",
+		Help: "Total number of inventory rollbacks that failed and require manual intervention, by operation.",
+	}, []string{"operation"})
+
+	// InventoryDiscrepancies is set by every reconciliation run to the orders still out of sync with inventory.
+	InventoryDiscrepancies = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_inventory_discrepancies",
+		Help: "Orders found out of sync with their inventory reservation by the last reconciliation run, by kind.",
+	}, []string{"kind"})
+)
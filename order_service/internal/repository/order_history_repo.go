@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"order_service/internal/domain"
+	"order_service/internal/requestid"
+	"order_service/internal/tracing"
+)
+
+func (r *postgresOrderRepository) ListStatusHistory(ctx context.Context, orderID int) ([]domain.OrderStatusChange, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.ListStatusHistory")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	query := `
+        SELECT id, order_id, COALESCE(from_status::text, ''), to_status, actor, reason, changed_at
+        FROM order_status_history
+        WHERE order_id = $1
+        ORDER BY id
+    `
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		log.Errorf("Repository: Failed to query status history of order %d: %v", orderID, err)
+		return nil, fmt.Errorf("could not retrieve status history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []domain.OrderStatusChange{}
+	for rows.Next() {
+		var change domain.OrderStatusChange
+		err := rows.Scan(
+			&change.ID,
+			&change.OrderID,
+			&change.FromStatus,
+			&change.ToStatus,
+			&change.Actor,
+			&change.Reason,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			log.Errorf("Repository: Failed to scan status change of order %d: %v", orderID, err)
+			return nil, fmt.Errorf("error scanning status change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating status history: %w", err)
+	}
+	return changes, nil
+}
+
+// insertStatusChangeTx appends a status change to the order's history inside the caller's
+// transaction. An empty from status marks the order's creation.
+func insertStatusChangeTx(ctx context.Context, tx *sql.Tx, orderID int, from, to domain.OrderStatus, reason string) error {
+	query := `
+        INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason)
+        VALUES ($1, NULLIF($2, '')::order_status, $3, $4, $5)
+    `
+	if _, err := tx.ExecContext(ctx, query, orderID, from, to, domain.ActorFromContext(ctx), reason); err != nil {
+		return fmt.Errorf("could not record status change of order %d: %w", orderID, err)
+	}
+	return nil
+}
@@ -1,11 +1,17 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"order_service/internal/domain"
-	_ "time"
+	"order_service/internal/requestid"
+	"order_service/internal/tracing"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
@@ -23,27 +29,30 @@ func NewPostgresOrderRepository(db *sql.DB, logger *logrus.Logger) domain.OrderR
 	}
 }
 
-func (r *postgresOrderRepository) CreateOrder(order *domain.Order) (*domain.Order, error) {
-	tx, err := r.db.Begin()
+func (r *postgresOrderRepository) CreateOrder(ctx context.Context, order *domain.Order, events ...*domain.OutboxEvent) (created *domain.Order, err error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.CreateOrder")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.log.Errorf("Failed to begin transaction: %v", err)
+		log.Errorf("Failed to begin transaction: %v", err)
 		return nil, fmt.Errorf("could not start transaction: %w", err)
 	}
 
 	defer func() {
 		if p := recover(); p != nil {
-			r.log.Error("Recovered from panic, rolling back transaction")
+			log.Error("Recovered from panic, rolling back transaction")
 			_ = tx.Rollback()
 			panic(p)
 		} else if err != nil {
-			r.log.Warnf("Rolling back transaction due to error: %v", err)
+			log.Warnf("Rolling back transaction due to error: %v", err)
 			if rbErr := tx.Rollback(); rbErr != nil {
-				r.log.Errorf("Failed to rollback transaction: %v", rbErr)
+				log.Errorf("Failed to rollback transaction: %v", rbErr)
 			}
 		} else {
-			r.log.Info("Committing transaction")
+			log.Info("Committing transaction")
 			if cErr := tx.Commit(); cErr != nil {
-				r.log.Errorf("Failed to commit transaction: %v", cErr)
+				log.Errorf("Failed to commit transaction: %v", cErr)
 
 				err = fmt.Errorf("failed to commit transaction: %w", cErr)
 
@@ -52,101 +61,146 @@ func (r *postgresOrderRepository) CreateOrder(order *domain.Order) (*domain.Orde
 	}()
 
 	orderQuery := `
-        INSERT INTO orders (user_id, status)
-        VALUES ($1, $2)
+        INSERT INTO orders (user_id, status, reservation_id, idempotency_key, idempotency_fingerprint, total)
+        VALUES ($1, $2, NULLIF($3, '')::uuid, NULLIF($4, ''), NULLIF($5, ''), $6)
         RETURNING id, status, created_at, updated_at
     `
-	err = tx.QueryRow(orderQuery, order.UserID, order.Status).Scan(
+	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.Status, order.ReservationID,
+		order.IdempotencyKey, order.IdempotencyFingerprint, order.Total()).Scan(
 		&order.ID,
 		&order.Status,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
 	if err != nil {
-		r.log.Errorf("Failed to insert order for user %d: %v", order.UserID, err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "idx_orders_user_idempotency_key" {
+			log.Warnf("Order with idempotency key %q already exists for user %d", order.IdempotencyKey, order.UserID)
+			return nil, fmt.Errorf("duplicate idempotency key %q for user %d", order.IdempotencyKey, order.UserID)
+		}
+		log.Errorf("Failed to insert order for user %d: %v", order.UserID, err)
 
 		return nil, fmt.Errorf("could not create order entry: %w", err)
 	}
-	r.log.Infof("Order entry created with ID: %d for user: %d", order.ID, order.UserID)
+	log.Infof("Order entry created with ID: %d for user: %d", order.ID, order.UserID)
 
 	itemQuery := `
-        INSERT INTO order_items (order_id, product_id, quantity, price)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, price)
+        VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6)
         
     `
-	stmt, err := tx.Prepare(itemQuery)
+	stmt, err := tx.PrepareContext(ctx, itemQuery)
 	if err != nil {
-		r.log.Errorf("Failed to prepare order item statement: %v", err)
+		log.Errorf("Failed to prepare order item statement: %v", err)
 		return nil, fmt.Errorf("could not prepare item statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for i := range order.Items {
 		item := &order.Items[i]
-		_, err = stmt.Exec(order.ID, item.ProductID, item.Quantity, item.Price)
+		_, err = stmt.ExecContext(ctx, order.ID, item.ProductID, item.VariantID, item.SKU, item.Quantity, item.Price)
 		if err != nil {
-			r.log.Errorf("Failed to insert order item (product_id: %d, quantity: %d) for order %d: %v", item.ProductID, item.Quantity, order.ID, err)
+			log.Errorf("Failed to insert order item (product_id: %d, quantity: %d) for order %d: %v", item.ProductID, item.Quantity, order.ID, err)
 
 			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" {
 				return nil, fmt.Errorf("invalid item data (product_id: %d): %s", item.ProductID, pqErr.Message)
 			}
 			return nil, fmt.Errorf("could not create order item (product_id: %d): %w", item.ProductID, err)
 		}
-		r.log.Infof("Order item inserted for order %d, product %d", order.ID, item.ProductID)
+		log.Infof("Order item inserted for order %d, product %d", order.ID, item.ProductID)
 	}
 
-	r.log.Infof("Order %d created successfully with %d items.", order.ID, len(order.Items))
+	if err = insertStatusChangeTx(ctx, tx, order.ID, "", order.Status, ""); err != nil {
+		log.Errorf("Failed to record initial status of order %d: %v", order.ID, err)
+		return nil, err
+	}
 
-	if err == nil && tx.Commit() != nil {
+	if err = insertOutboxEventsTx(ctx, tx, order.ID, events); err != nil {
+		log.Errorf("Failed to write outbox events for order %d: %v", order.ID, err)
 		return nil, err
 	}
 
+	log.Infof("Order %d created successfully with %d items.", order.ID, len(order.Items))
+
 	return order, nil
 }
 
-func (r *postgresOrderRepository) GetOrderByID(id int) (*domain.Order, error) {
+func (r *postgresOrderRepository) GetOrderByID(ctx context.Context, id int) (*domain.Order, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.GetOrderByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	order := &domain.Order{}
 	orderQuery := `
-        SELECT id, user_id, status, created_at, updated_at
+        SELECT id, user_id, status, COALESCE(reservation_id::text, ''), created_at, updated_at
         FROM orders
         WHERE id = $1
     `
-	err := r.db.QueryRow(orderQuery, id).Scan(
+	err := r.db.QueryRowContext(ctx, orderQuery, id).Scan(
 		&order.ID,
 		&order.UserID,
 		&order.Status,
+		&order.ReservationID,
 		&order.CreatedAt,
 		&order.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Order with ID %d not found", id)
+			log.Warnf("Order with ID %d not found", id)
 			return nil, fmt.Errorf("order with id %d not found", id)
 		}
-		r.log.Errorf("Failed to get order by ID %d: %v", id, err)
+		log.Errorf("Failed to get order by ID %d: %v", id, err)
 		return nil, fmt.Errorf("could not retrieve order: %w", err)
 	}
 
-	items, err := r.getOrderItems(id)
+	items, err := r.getOrderItems(ctx, id)
 	if err != nil {
 
 		return nil, err
 	}
 	order.Items = items
 
-	r.log.Infof("Order %d retrieved successfully with %d items.", order.ID, len(order.Items))
+	log.Infof("Order %d retrieved successfully with %d items.", order.ID, len(order.Items))
+	return order, nil
+}
+
+func (r *postgresOrderRepository) GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (*domain.Order, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.GetOrderByIdempotencyKey")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var id int
+	var fingerprint string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, COALESCE(idempotency_fingerprint, '') FROM orders WHERE user_id = $1 AND idempotency_key = $2`,
+		userID, key).Scan(&id, &fingerprint)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Errorf("Failed to look up order of user %d by idempotency key: %v", userID, err)
+		return nil, fmt.Errorf("could not look up idempotency key: %w", err)
+	}
+
+	order, err := r.GetOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	order.IdempotencyKey = key
+	order.IdempotencyFingerprint = fingerprint
 	return order, nil
 }
 
-func (r *postgresOrderRepository) getOrderItems(orderID int) ([]domain.OrderItem, error) {
+func (r *postgresOrderRepository) getOrderItems(ctx context.Context, orderID int) ([]domain.OrderItem, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.getOrderItems")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	itemsQuery := `
-        SELECT product_id, quantity, price
+        SELECT product_id, COALESCE(variant_id, 0), sku, quantity, price
         FROM order_items
         WHERE order_id = $1
     `
-	rows, err := r.db.Query(itemsQuery, orderID)
+	rows, err := r.db.QueryContext(ctx, itemsQuery, orderID)
 	if err != nil {
-		r.log.Errorf("Failed to query order items for order ID %d: %v", orderID, err)
+		log.Errorf("Failed to query order items for order ID %d: %v", orderID, err)
 		return nil, fmt.Errorf("could not retrieve order items: %w", err)
 	}
 	defer rows.Close()
@@ -154,8 +208,8 @@ func (r *postgresOrderRepository) getOrderItems(orderID int) ([]domain.OrderItem
 	var items []domain.OrderItem
 	for rows.Next() {
 		var item domain.OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.Price); err != nil {
-			r.log.Errorf("Failed to scan order item row for order ID %d: %v", orderID, err)
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.Price); err != nil {
+			log.Errorf("Failed to scan order item row for order ID %d: %v", orderID, err)
 
 			return nil, fmt.Errorf("error scanning order item: %w", err)
 		}
@@ -163,19 +217,22 @@ func (r *postgresOrderRepository) getOrderItems(orderID int) ([]domain.OrderItem
 	}
 
 	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during order items iteration for order ID %d: %v", orderID, err)
+		log.Errorf("Error during order items iteration for order ID %d: %v", orderID, err)
 		return nil, fmt.Errorf("error iterating order items: %w", err)
 	}
 
-	r.log.Debugf("Retrieved %d items for order ID %d", len(items), orderID)
+	log.Debugf("Retrieved %d items for order ID %d", len(items), orderID)
 	return items, nil
 }
 
-func (r *postgresOrderRepository) UpdateOrderStatus(id int, status domain.OrderStatus) (*domain.Order, error) {
+func (r *postgresOrderRepository) UpdateOrderStatus(ctx context.Context, id int, from, to domain.OrderStatus, reason string, events ...*domain.OutboxEvent) (updated *domain.Order, err error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.UpdateOrderStatus")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 
-	tx, err := r.db.Begin()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.log.Errorf("Failed to begin transaction for status update: %v", err)
+		log.Errorf("Failed to begin transaction for status update: %v", err)
 		return nil, fmt.Errorf("could not start transaction: %w", err)
 	}
 
@@ -185,12 +242,12 @@ func (r *postgresOrderRepository) UpdateOrderStatus(id int, status domain.OrderS
 			panic(p)
 		} else if err != nil {
 			if rbErr := tx.Rollback(); rbErr != nil {
-				r.log.Errorf("UpdateOrderStatus: Failed to rollback transaction: %v (original error: %v)", rbErr, err)
+				log.Errorf("UpdateOrderStatus: Failed to rollback transaction: %v (original error: %v)", rbErr, err)
 			}
 		} else {
 			if cErr := tx.Commit(); cErr != nil {
 				err = fmt.Errorf("failed to commit status update transaction: %w", cErr)
-				r.log.Errorf("UpdateOrderStatus: %v", err)
+				log.Errorf("UpdateOrderStatus: %v", err)
 			}
 		}
 	}()
@@ -198,56 +255,78 @@ func (r *postgresOrderRepository) UpdateOrderStatus(id int, status domain.OrderS
 	query := `
         UPDATE orders
         SET status = $1, updated_at = NOW() 
-        WHERE id = $2
-        RETURNING id, user_id, status, created_at, updated_at
+        WHERE id = $2 AND status = $3
+        RETURNING id, user_id, status, COALESCE(reservation_id::text, ''), created_at, updated_at
     `
 	updatedOrder := &domain.Order{}
 
-	err = tx.QueryRow(query, status, id).Scan(
+	err = tx.QueryRowContext(ctx, query, to, id, from).Scan(
 		&updatedOrder.ID,
 		&updatedOrder.UserID,
 		&updatedOrder.Status,
+		&updatedOrder.ReservationID,
 		&updatedOrder.CreatedAt,
 		&updatedOrder.UpdatedAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Order with ID %d not found for status update", id)
-
-			return nil, fmt.Errorf("order with id %d not found for update", id)
+			var current domain.OrderStatus
+			lookupErr := tx.QueryRowContext(ctx, `SELECT status FROM orders WHERE id = $1`, id).Scan(&current)
+			if errors.Is(lookupErr, sql.ErrNoRows) {
+				log.Warnf("Order with ID %d not found for status update", id)
+				return nil, fmt.Errorf("order with id %d not found for update", id)
+			}
+			if lookupErr != nil {
+				return nil, fmt.Errorf("could not update order status: %w", lookupErr)
+			}
+			log.Warnf("Order %d moved from '%s' to '%s' before its update to '%s'", id, from, current, to)
+			return nil, fmt.Errorf("status transition not allowed: order %d is %s, no longer %s", id, current, from)
 		}
 
-		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" { // check_violation или invalid enum
-			r.log.Warnf("Invalid status value '%s' for order ID %d: %v", status, id, err)
-			return nil, fmt.Errorf("invalid order status provided: %s", status)
+		if pqErr, ok := err.(*pq.Error); ok && (pqErr.Code == "23514" || pqErr.Code == "22P02") { // check_violation или invalid enum
+			log.Warnf("Invalid status value '%s' for order ID %d: %v", to, id, err)
+			return nil, fmt.Errorf("invalid order status provided: %s", to)
 		}
-		r.log.Errorf("Failed to update status for order ID %d: %v", id, err)
+		log.Errorf("Failed to update status for order ID %d: %v", id, err)
 
 		return nil, fmt.Errorf("could not update order status: %w", err)
 	}
 
-	items, err := r.getOrderItemsTx(tx, id)
+	items, err := r.getOrderItemsTx(ctx, tx, id)
 	if err != nil {
 
 		return nil, fmt.Errorf("order status updated, but failed to retrieve items: %w", err)
 	}
 	updatedOrder.Items = items
 
-	r.log.Infof("Status and items retrieved successfully for order %d after update to '%s'.", updatedOrder.ID, updatedOrder.Status)
+	if err = insertStatusChangeTx(ctx, tx, id, from, to, reason); err != nil {
+		log.Errorf("Failed to record status change of order %d: %v", id, err)
+		return nil, err
+	}
+
+	if err = insertOutboxEventsTx(ctx, tx, id, events); err != nil {
+		log.Errorf("Failed to write outbox events for order %d: %v", id, err)
+		return nil, err
+	}
+
+	log.Infof("Status and items retrieved successfully for order %d after update to '%s'.", updatedOrder.ID, updatedOrder.Status)
 
 	return updatedOrder, nil
 }
 
-func (r *postgresOrderRepository) getOrderItemsTx(tx *sql.Tx, orderID int) ([]domain.OrderItem, error) {
+func (r *postgresOrderRepository) getOrderItemsTx(ctx context.Context, tx *sql.Tx, orderID int) ([]domain.OrderItem, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.getOrderItemsTx")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	itemsQuery := `
-        SELECT product_id, quantity, price
+        SELECT product_id, COALESCE(variant_id, 0), sku, quantity, price
         FROM order_items
         WHERE order_id = $1
     `
 
-	rows, err := tx.Query(itemsQuery, orderID)
+	rows, err := tx.QueryContext(ctx, itemsQuery, orderID)
 	if err != nil {
-		r.log.Errorf("Failed to query order items within tx for order ID %d: %v", orderID, err)
+		log.Errorf("Failed to query order items within tx for order ID %d: %v", orderID, err)
 		return nil, fmt.Errorf("could not retrieve order items within tx: %w", err)
 	}
 	defer rows.Close()
@@ -255,100 +334,354 @@ func (r *postgresOrderRepository) getOrderItemsTx(tx *sql.Tx, orderID int) ([]do
 	var items []domain.OrderItem
 	for rows.Next() {
 		var item domain.OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.Price); err != nil {
-			r.log.Errorf("Failed to scan order item row within tx for order ID %d: %v", orderID, err)
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.Price); err != nil {
+			log.Errorf("Failed to scan order item row within tx for order ID %d: %v", orderID, err)
 			return nil, fmt.Errorf("error scanning order item within tx: %w", err)
 		}
 		items = append(items, item)
 	}
 
 	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during order items iteration within tx for order ID %d: %v", orderID, err)
+		log.Errorf("Error during order items iteration within tx for order ID %d: %v", orderID, err)
 		return nil, fmt.Errorf("error iterating order items within tx: %w", err)
 	}
 
-	r.log.Debugf("Retrieved %d items within tx for order ID %d", len(items), orderID)
+	log.Debugf("Retrieved %d items within tx for order ID %d", len(items), orderID)
 	return items, nil
 }
 
-func (r *postgresOrderRepository) ListOrdersByUserID(userID int, limit, offset int) ([]domain.Order, error) {
+func (r *postgresOrderRepository) ListOrdersByUserID(ctx context.Context, userID int, limit, offset int, pageToken string) (*domain.OrderPage, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.ListOrdersByUserID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 
-	if limit <= 0 || limit > 100 {
-		limit = 10
-	}
-	if offset < 0 {
+	if offset < 0 || pageToken != "" {
 		offset = 0
 	}
 
-	ordersQuery := `
-        SELECT id, user_id, status, created_at, updated_at
+	// Page tokens are search cursors of a newest-first search, so deep pages seek through
+	// idx_orders_user_created_at instead of skipping rows
+	args := []interface{}{userID}
+	keyset := ""
+	if pageToken != "" {
+		cursor, err := decodeSearchCursor(pageToken)
+		if err != nil || cursor.SortBy != domain.OrderSortCreatedAt || cursor.Ascending {
+			return nil, errors.New("invalid page token")
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		keyset = "AND (created_at, id) < ($2::timestamptz, $3)"
+	}
+
+	page := &domain.OrderPage{Orders: []domain.Order{}}
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE user_id = $1`, userID).Scan(&page.TotalSize); err != nil {
+		log.Errorf("Failed to count orders for user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("could not count orders: %w", err)
+	}
+
+	// One extra row tells whether there is a next page
+	args = append(args, limit+1, offset)
+	ordersQuery := fmt.Sprintf(`
+        SELECT id, user_id, status, COALESCE(reservation_id::text, ''), created_at, updated_at
         FROM orders
-        WHERE user_id = $1
-        ORDER BY created_at DESC -- Сначала новые заказы
-        LIMIT $2 OFFSET $3
-    `
-	rows, err := r.db.Query(ordersQuery, userID, limit, offset)
+        WHERE user_id = $1 %s
+        ORDER BY created_at DESC, id DESC -- Сначала новые заказы
+        LIMIT $%d OFFSET $%d
+    `, keyset, len(args)-1, len(args))
+	rows, err := r.db.QueryContext(ctx, ordersQuery, args...)
 	if err != nil {
-		r.log.Errorf("Failed to list orders for user ID %d: %v", userID, err)
+		log.Errorf("Failed to list orders for user ID %d: %v", userID, err)
 		return nil, fmt.Errorf("could not retrieve orders: %w", err)
 	}
 	defer rows.Close()
 
-	var orders []domain.Order
-	orderIDs := []int{}
+	for rows.Next() {
+		var order domain.Order
+		if err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.ReservationID,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		); err != nil {
+			log.Errorf("Failed to scan order row for user ID %d: %v", userID, err)
+			return nil, fmt.Errorf("error scanning order data: %w", err)
+		}
+		page.Orders = append(page.Orders, order)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("Error during orders iteration for user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	if len(page.Orders) > limit {
+		page.Orders = page.Orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextPageToken = searchCursor{
+			SortBy: domain.OrderSortCreatedAt,
+			Value:  last.CreatedAt.Format(time.RFC3339Nano),
+			ID:     last.ID,
+		}.encode()
+	}
+
+	if err := r.attachOrderItems(ctx, page.Orders); err != nil {
+		log.Errorf("Failed to load items of orders for user ID %d: %v", userID, err)
+		return nil, err
+	}
+
+	log.Infof("Retrieved %d of %d orders for user ID %d (limit %d, more: %t)", len(page.Orders), page.TotalSize, userID, limit, page.NextPageToken != "")
+	return page, nil
+}
+
+func (r *postgresOrderRepository) ScanOrders(ctx context.Context, afterID int, limit int) ([]domain.Order, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.ScanOrders")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, user_id, status, COALESCE(reservation_id::text, ''), created_at, updated_at
+        FROM orders
+        WHERE id > $1
+        ORDER BY id ASC
+        LIMIT $2`, afterID, limit)
+	if err != nil {
+		log.Errorf("Failed to scan orders after ID %d: %v", afterID, err)
+		return nil, fmt.Errorf("could not retrieve orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
 	for rows.Next() {
 		var order domain.Order
 		if err := rows.Scan(
 			&order.ID,
 			&order.UserID,
 			&order.Status,
+			&order.ReservationID,
 			&order.CreatedAt,
 			&order.UpdatedAt,
 		); err != nil {
-			r.log.Errorf("Failed to scan order row for user ID %d: %v", userID, err)
+			log.Errorf("Failed to scan order row after ID %d: %v", afterID, err)
 			return nil, fmt.Errorf("error scanning order data: %w", err)
 		}
 		orders = append(orders, order)
-		orderIDs = append(orderIDs, order.ID)
 	}
 	if err = rows.Err(); err != nil {
-		r.log.Errorf("Error during orders iteration for user ID %d: %v", userID, err)
 		return nil, fmt.Errorf("error iterating orders: %w", err)
 	}
 
+	if err := r.attachOrderItems(ctx, orders); err != nil {
+		log.Errorf("Failed to load items of %d orders: %v", len(orders), err)
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *postgresOrderRepository) CountLegacyOrdersHoldingStock(ctx context.Context, productID int, statuses []domain.OrderStatus) (int, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.CountLegacyOrdersHoldingStock")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	statusNames := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		statusNames = append(statusNames, string(status))
+	}
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(DISTINCT o.id)
+        FROM orders o
+        JOIN order_items i ON i.order_id = o.id
+        WHERE o.reservation_id IS NULL
+          AND i.product_id = $1
+          AND (o.status = ANY($2::order_status[])
+               OR EXISTS (SELECT 1 FROM outbox_events e
+                          WHERE e.order_id = o.id AND e.event_type = $3 AND e.status = 'pending'))`,
+		productID, pq.Array(statusNames), domain.EventStockRelease).Scan(&count)
+	if err != nil {
+		log.Errorf("Failed to count legacy orders holding stock of product %d: %v", productID, err)
+		return 0, fmt.Errorf("could not count orders holding stock of product %d: %w", productID, err)
+	}
+	return count, nil
+}
+
+// searchCursor is the keyset position after the last order of a search page. It carries the
+// sorting it was made for so that it cannot be replayed against a differently sorted search.
+type searchCursor struct {
+	SortBy    domain.OrderSortField `json:"s"`
+	Ascending bool                  `json:"a"`
+	Value     string                `json:"v"`
+	ID        int                   `json:"id"`
+}
+
+func (c searchCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSearchCursor(encoded string) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("invalid cursor: malformed")
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, errors.New("invalid cursor: malformed")
+	}
+	return &cursor, nil
+}
+
+func (r *postgresOrderRepository) SearchOrders(ctx context.Context, filter domain.OrderSearchFilter) (*domain.OrderSearchResult, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OrderRepository.SearchOrders")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, status := range filter.Statuses {
+			statuses = append(statuses, string(status))
+		}
+		where("status = ANY($%d::order_status[])", pq.Array(statuses))
+	}
+	if filter.UserID > 0 {
+		where("user_id = $%d", filter.UserID)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where("created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		where("created_at < $%d", filter.CreatedTo)
+	}
+	if filter.ProductID > 0 {
+		where("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product_id = $%d)", filter.ProductID)
+	}
+	if filter.MinTotal > 0 {
+		where("total >= $%d", filter.MinTotal)
+	}
+
+	// Both sort columns are indexed together with id, which breaks ties
+	sortColumn, sortType := "created_at", "timestamptz"
+	if filter.SortBy == domain.OrderSortTotal {
+		sortColumn, sortType = "total", "numeric"
+	}
+	direction, comparison := "DESC", "<"
+	if filter.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+	if filter.Cursor != "" {
+		cursor, err := decodeSearchCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != filter.SortBy || cursor.Ascending != filter.Ascending {
+			return nil, errors.New("invalid cursor: it belongs to a search with different sorting")
+		}
+		where("("+sortColumn+", id) "+comparison+" ($%d::"+sortType+", $%d)", cursor.Value, cursor.ID)
+	}
+
+	query := `
+        SELECT id, user_id, status, COALESCE(reservation_id::text, ''), total::text, created_at, updated_at
+        FROM orders`
+	if len(conditions) > 0 {
+		query += "\n        WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, filter.Limit+1)
+	query += fmt.Sprintf("\n        ORDER BY %s %s, id %s\n        LIMIT $%d", sortColumn, direction, direction, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Errorf("Failed to search orders: %v", err)
+		return nil, fmt.Errorf("could not search orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []domain.Order{}
+	var totals []string
+	for rows.Next() {
+		var order domain.Order
+		var total string
+		if err := rows.Scan(
+			&order.ID,
+			&order.UserID,
+			&order.Status,
+			&order.ReservationID,
+			&total,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		); err != nil {
+			log.Errorf("Failed to scan order row of search: %v", err)
+			return nil, fmt.Errorf("error scanning order data: %w", err)
+		}
+		orders = append(orders, order)
+		totals = append(totals, total)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orders: %w", err)
+	}
+
+	result := &domain.OrderSearchResult{}
+	if len(orders) > filter.Limit {
+		orders = orders[:filter.Limit]
+		last := orders[len(orders)-1]
+		cursor := searchCursor{SortBy: filter.SortBy, Ascending: filter.Ascending, ID: last.ID, Value: totals[len(orders)-1]}
+		if filter.SortBy != domain.OrderSortTotal {
+			cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		result.NextCursor = cursor.encode()
+	}
+
+	if err := r.attachOrderItems(ctx, orders); err != nil {
+		log.Errorf("Failed to load items of %d orders: %v", len(orders), err)
+		return nil, err
+	}
+	result.Orders = orders
+	log.Infof("Order search returned %d orders (more: %t)", len(orders), result.NextCursor != "")
+	return result, nil
+}
+
+// attachOrderItems loads the items of all given orders with one query.
+func (r *postgresOrderRepository) attachOrderItems(ctx context.Context, orders []domain.Order) error {
 	if len(orders) == 0 {
-		r.log.Infof("No orders found for user ID %d with limit %d, offset %d", userID, limit, offset)
-		return []domain.Order{}, nil
+		return nil
+	}
+	orderIDs := make([]int, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
 	}
 
-	itemsQuery := `
-        SELECT order_id, product_id, quantity, price
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT order_id, product_id, COALESCE(variant_id, 0), sku, quantity, price
         FROM order_items
-        WHERE order_id = ANY($1::int[]) -- Используем массив ID
-		ORDER BY order_id -- Опционально, для группировки
-    `
-
-	itemRows, err := r.db.Query(itemsQuery, pq.Array(orderIDs))
+        WHERE order_id = ANY($1::int[])
+        ORDER BY order_id`, pq.Array(orderIDs))
 	if err != nil {
-		r.log.Errorf("Failed to query items for multiple orders (%v): %v", orderIDs, err)
-		return nil, fmt.Errorf("could not retrieve order items for list: %w", err)
+		return fmt.Errorf("could not retrieve order items for list: %w", err)
 	}
-	defer itemRows.Close()
+	defer rows.Close()
 
 	itemsMap := make(map[int][]domain.OrderItem)
-	for itemRows.Next() {
+	for rows.Next() {
 		var item domain.OrderItem
 		var orderID int
-		if err := itemRows.Scan(&orderID, &item.ProductID, &item.Quantity, &item.Price); err != nil {
-			r.log.Errorf("Failed to scan order item row during multi-order fetch: %v", err)
-			return nil, fmt.Errorf("error scanning order item data for list: %w", err)
+		if err := rows.Scan(&orderID, &item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.Price); err != nil {
+			return fmt.Errorf("error scanning order item data for list: %w", err)
 		}
 		itemsMap[orderID] = append(itemsMap[orderID], item)
 	}
-	if err = itemRows.Err(); err != nil {
-		r.log.Errorf("Error during multi-order items iteration: %v", err)
-		return nil, fmt.Errorf("error iterating order items for list: %w", err)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating order items for list: %w", err)
 	}
 
 	for i := range orders {
@@ -358,7 +691,5 @@ func (r *postgresOrderRepository) ListOrdersByUserID(userID int, limit, offset i
 			orders[i].Items = []domain.OrderItem{}
 		}
 	}
-
-	r.log.Infof("Retrieved %d orders for user ID %d (limit %d, offset %d)", len(orders), userID, limit, offset)
-	return orders, nil
+	return nil
 }
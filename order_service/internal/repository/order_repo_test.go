@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"encoding/base64"
+	"order_service/internal/domain"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeSearchCursor(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	valid := searchCursor{SortBy: domain.OrderSortTotal, Ascending: true, Value: "129.90", ID: 42}
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    *searchCursor
+		wantErr bool
+	}{
+		{name: "round trip", encoded: valid.encode(), want: &valid},
+		{name: "round trip of created_at", encoded: searchCursor{SortBy: domain.OrderSortCreatedAt, Value: "2024-05-01T10:00:00.123456Z", ID: 7}.encode(),
+			want: &searchCursor{SortBy: domain.OrderSortCreatedAt, Value: "2024-05-01T10:00:00.123456Z", ID: 7}},
+		{name: "empty", encoded: "", wantErr: true},
+		{name: "not base64", encoded: "not a cursor!", wantErr: true},
+		{name: "padded base64", encoded: base64.URLEncoding.EncodeToString([]byte(`{"s":"total","v":"1","id":1}`)), wantErr: true},
+		{name: "not JSON", encoded: encode("42"), wantErr: true},
+		{name: "wrong field type", encoded: encode(`{"s":"total","v":"1","id":"1"}`), wantErr: true},
+		{name: "missing ID", encoded: encode(`{"s":"total","v":"1"}`), wantErr: true},
+		{name: "negative ID", encoded: encode(`{"s":"total","v":"1","id":-3}`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSearchCursor(tt.encoded)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decodeSearchCursor(%q) = %+v, want an error", tt.encoded, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeSearchCursor(%q) returned error: %v", tt.encoded, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeSearchCursor(%q) = %+v, want %+v", tt.encoded, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order_service/internal/domain"
+	"order_service/internal/requestid"
+	"order_service/internal/tracing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+type postgresOutboxRepository struct {
+	db  *sql.DB
+	log *logrus.Logger
+}
+
+func NewPostgresOutboxRepository(db *sql.DB, logger *logrus.Logger) domain.OutboxRepository {
+	return &postgresOutboxRepository{
+		db:  db,
+		log: logger,
+	}
+}
+
+const outboxColumns = `id, order_id, event_type, payload, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at`
+
+func (r *postgresOutboxRepository) ClaimDueEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.ClaimDueEvents")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	// SKIP LOCKED lets several order_service replicas run the relay without picking the same event
+	query := `
+        UPDATE outbox_events
+        SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+        WHERE id IN (
+            SELECT id FROM outbox_events
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING ` + outboxColumns
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		log.Errorf("Repository: Failed to claim outbox events: %v", err)
+		return nil, fmt.Errorf("could not claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []domain.OutboxEvent{}
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			log.Errorf("Repository: Failed to scan outbox event: %v", err)
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+	return events, nil
+}
+
+func (r *postgresOutboxRepository) ClaimEvent(ctx context.Context, id int64, lease time.Duration) (*domain.OutboxEvent, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.ClaimEvent")
+	defer span.End()
+
+	query := `
+        UPDATE outbox_events
+        SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+        WHERE id = $1 AND status = 'pending' AND next_attempt_at <= NOW()
+        RETURNING ` + outboxColumns
+	event, err := scanOutboxEvent(r.db.QueryRowContext(ctx, query, id, lease.Milliseconds()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		requestid.Logger(ctx, r.log).Errorf("Repository: Failed to claim outbox event %d: %v", id, err)
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *postgresOutboxRepository) MarkEventDone(ctx context.Context, id int64) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.MarkEventDone")
+	defer span.End()
+	return r.finish(ctx, id, `UPDATE outbox_events SET status = 'done', last_error = NULL, processed_at = NOW() WHERE id = $1`)
+}
+
+func (r *postgresOutboxRepository) ScheduleRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.ScheduleRetry")
+	defer span.End()
+	return r.finish(ctx, id, `UPDATE outbox_events SET next_attempt_at = $2, last_error = $3 WHERE id = $1`, nextAttemptAt, lastError)
+}
+
+func (r *postgresOutboxRepository) MarkEventFailed(ctx context.Context, id int64, lastError string) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.MarkEventFailed")
+	defer span.End()
+	return r.finish(ctx, id, `UPDATE outbox_events SET status = 'failed', last_error = $2, processed_at = NOW() WHERE id = $1`, lastError)
+}
+
+func (r *postgresOutboxRepository) EnqueueEvents(ctx context.Context, events ...*domain.OutboxEvent) (err error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.EnqueueEvents")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("could not commit outbox events: %w", err)
+		}
+	}()
+
+	for _, event := range events {
+		if err = insertOutboxEventsTx(ctx, tx, event.OrderID, []*domain.OutboxEvent{event}); err != nil {
+			log.Errorf("Repository: Failed to enqueue %s event for order %d: %v", event.Type, event.OrderID, err)
+			return err
+		}
+	}
+	log.Infof("Repository: Enqueued %d outbox events", len(events))
+	return nil
+}
+
+func (r *postgresOutboxRepository) OrdersWithPendingEvents(ctx context.Context, orderIDs []int) (map[int]bool, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.OrdersWithPendingEvents")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT order_id FROM outbox_events WHERE status = 'pending' AND order_id = ANY($1::int[])`,
+		pq.Array(orderIDs))
+	if err != nil {
+		requestid.Logger(ctx, r.log).Errorf("Repository: Failed to look up pending outbox events: %v", err)
+		return nil, fmt.Errorf("could not look up pending outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	pending := make(map[int]bool)
+	for rows.Next() {
+		var orderID int
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, fmt.Errorf("error scanning order id: %w", err)
+		}
+		pending[orderID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending outbox events: %w", err)
+	}
+	return pending, nil
+}
+
+func (r *postgresOutboxRepository) OrdersWithEvent(ctx context.Context, orderIDs []int, eventType string) (map[int]bool, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.OrdersWithEvent")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT order_id FROM outbox_events WHERE event_type = $1 AND order_id = ANY($2::int[])`,
+		eventType, pq.Array(orderIDs))
+	if err != nil {
+		requestid.Logger(ctx, r.log).Errorf("Repository: Failed to look up %s outbox events: %v", eventType, err)
+		return nil, fmt.Errorf("could not look up %s outbox events: %w", eventType, err)
+	}
+	defer rows.Close()
+
+	found := make(map[int]bool)
+	for rows.Next() {
+		var orderID int
+		if err := rows.Scan(&orderID); err != nil {
+			return nil, fmt.Errorf("error scanning order id: %w", err)
+		}
+		found[orderID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s outbox events: %w", eventType, err)
+	}
+	return found, nil
+}
+
+func (r *postgresOutboxRepository) LatestEvent(ctx context.Context, orderID int, eventType string) (*domain.OutboxEvent, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "OutboxRepository.LatestEvent")
+	defer span.End()
+
+	query := `SELECT ` + outboxColumns + ` FROM outbox_events WHERE order_id = $1 AND event_type = $2 ORDER BY id DESC LIMIT 1`
+	event, err := scanOutboxEvent(r.db.QueryRowContext(ctx, query, orderID, eventType))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		requestid.Logger(ctx, r.log).Errorf("Repository: Failed to get %s outbox event of order %d: %v", eventType, orderID, err)
+		return nil, fmt.Errorf("could not get outbox event of order %d: %w", orderID, err)
+	}
+	return event, nil
+}
+
+func (r *postgresOutboxRepository) finish(ctx context.Context, id int64, query string, args ...interface{}) error {
+	if _, err := r.db.ExecContext(ctx, query, append([]interface{}{id}, args...)...); err != nil {
+		requestid.Logger(ctx, r.log).Errorf("Repository: Failed to update outbox event %d: %v", id, err)
+		return fmt.Errorf("could not update outbox event %d: %w", id, err)
+	}
+	return nil
+}
+
+// insertOutboxEventsTx stores the events inside the caller's transaction and fills in their IDs.
+func insertOutboxEventsTx(ctx context.Context, tx *sql.Tx, orderID int, events []*domain.OutboxEvent) error {
+	query := `
+        INSERT INTO outbox_events (order_id, event_type, payload)
+        VALUES ($1, $2, $3)
+        RETURNING id, status, next_attempt_at, created_at`
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return fmt.Errorf("could not encode %s event payload: %w", event.Type, err)
+		}
+		event.OrderID = orderID
+		err = tx.QueryRowContext(ctx, query, orderID, event.Type, payload).Scan(
+			&event.ID,
+			&event.Status,
+			&event.NextAttemptAt,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("could not write %s outbox event: %w", event.Type, err)
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanOutboxEvent(row rowScanner) (*domain.OutboxEvent, error) {
+	event := &domain.OutboxEvent{}
+	var payload []byte
+	err := row.Scan(
+		&event.ID,
+		&event.OrderID,
+		&event.Type,
+		&payload,
+		&event.Status,
+		&event.Attempts,
+		&event.NextAttemptAt,
+		&event.LastError,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning outbox event: %w", err)
+	}
+	if err := json.Unmarshal(payload, &event.Payload); err != nil {
+		return nil, fmt.Errorf("could not decode payload of outbox event %d: %w", event.ID, err)
+	}
+	return event, nil
+}
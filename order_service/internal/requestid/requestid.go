@@ -0,0 +1,88 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MetadataKey is the gRPC metadata key the API gateway uses to forward the request ID.
+const MetadataKey = "x-request-id"
+
+// validID matches the IDs the API gateway accepts; anything else is not trusted into the logs.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type ctxKey struct{}
+
+// NewContext returns a copy of ctx carrying the request ID.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// New generates a random request ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Logger returns a log entry tagged with the request ID from ctx.
+func Logger(ctx context.Context, logger *logrus.Logger) *logrus.Entry {
+	if id := FromContext(ctx); id != "" {
+		return logger.WithField("request_id", id)
+	}
+	return logrus.NewEntry(logger)
+}
+
+// UnaryServerInterceptor takes the request ID from the incoming metadata (or generates one when it
+// is missing or malformed), stores it in the request context, echoes it back in the response
+// header and logs the call.
+func UnaryServerInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(MetadataKey); len(values) > 0 {
+				id = values[0]
+			}
+		}
+		if !validID.MatchString(id) {
+			id = New()
+		}
+		ctx = NewContext(ctx, id)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		Logger(ctx, logger).WithFields(logrus.Fields{
+			"method":     info.FullMethod,
+			"code":       status.Code(err).String(),
+			"latency_ms": time.Since(start).Milliseconds(),
+		}).Info("gRPC request completed")
+		return resp, err
+	}
+}
+
+// UnaryClientInterceptor forwards the request ID from ctx to downstream services.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if id := FromContext(ctx); id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
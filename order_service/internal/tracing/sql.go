@@ -0,0 +1,17 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSQLSpan starts a client span around a repository call that talks to PostgreSQL.
+func StartSQLSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer("repository").Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL),
+	)
+}
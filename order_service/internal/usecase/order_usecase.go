@@ -2,36 +2,55 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"order_service/internal/clients"
 	"order_service/internal/domain"
+	"order_service/internal/metrics"
+	"order_service/internal/requestid"
+	"sort"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
 
 var _ domain.OrderUseCase = (*orderUseCase)(nil)
 
+const maxIdempotencyKeyLength = 255
+
+// Page sizes of SearchOrders.
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+// Page sizes of ListOrdersByUserID.
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
 type orderUseCase struct {
 	orderRepo       domain.OrderRepository
 	inventoryClient clients.InventoryClient
+	sagaRelay       SagaRelay
 	log             *logrus.Logger
 }
 
-func NewOrderUseCase(repo domain.OrderRepository, invClient clients.InventoryClient, logger *logrus.Logger) domain.OrderUseCase {
+func NewOrderUseCase(repo domain.OrderRepository, invClient clients.InventoryClient, relay SagaRelay, logger *logrus.Logger) domain.OrderUseCase {
 	return &orderUseCase{
 		orderRepo:       repo,
 		inventoryClient: invClient,
+		sagaRelay:       relay,
 		log:             logger,
 	}
 }
 
-type productCheckInfo struct {
-	Product       *clients.Product
-	OrderQuantity int
-}
-
 func (uc *orderUseCase) CreateOrder(ctx context.Context, order *domain.Order) (*domain.Order, error) {
+	log := requestid.Logger(ctx, uc.log)
 
 	if order.UserID <= 0 {
 		return nil, errors.New("invalid user ID")
@@ -43,6 +62,9 @@ func (uc *orderUseCase) CreateOrder(ctx context.Context, order *domain.Order) (*
 		if item.ProductID <= 0 {
 			return nil, fmt.Errorf("item %d: invalid product ID", i)
 		}
+		if item.VariantID < 0 {
+			return nil, fmt.Errorf("item %d (product %d): invalid variant ID", i, item.ProductID)
+		}
 		if item.Quantity <= 0 {
 			return nil, fmt.Errorf("item %d (product %d): quantity must be positive", i, item.ProductID)
 		}
@@ -57,115 +79,164 @@ func (uc *orderUseCase) CreateOrder(ctx context.Context, order *domain.Order) (*
 	if order.Status != domain.StatusPending {
 		return nil, fmt.Errorf("order can only be created with '%s' status", domain.StatusPending)
 	}
-	uc.log.Infof("Use Case: Validated basic order data for user %d. Status set to %s.", order.UserID, order.Status)
-
-	uc.log.Infof("Use Case: Starting inventory check and reservation for order (user %d)", order.UserID)
-
-	productsInfo := make(map[int]productCheckInfo)
 
-	for i, item := range order.Items {
-		uc.log.Infof("Use Case: Checking inventory for Product ID: %d (Quantity: %d)", item.ProductID, item.Quantity)
-
-		product, err := uc.inventoryClient.GetProduct(ctx, item.ProductID)
-		if err != nil {
-			uc.log.Warnf("Use Case: Inventory check failed for Product ID %d: %v", item.ProductID, err)
+	caller, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID != order.UserID && !caller.IsStaff() {
+		log.Warnf("Use Case: User %d attempted to create an order for user %d", caller.UserID, order.UserID)
+		return nil, errors.New("permission denied: cannot create orders for another user")
+	}
+	if len(order.IdempotencyKey) > maxIdempotencyKeyLength {
+		return nil, fmt.Errorf("invalid idempotency key: must be at most %d characters", maxIdempotencyKeyLength)
+	}
+	log.Infof("Use Case: Validated basic order data for user %d. Status set to %s.", order.UserID, order.Status)
 
-			return nil, fmt.Errorf("inventory check failed for product %d: %w", item.ProductID, err)
+	if order.IdempotencyKey != "" {
+		order.IdempotencyFingerprint = orderFingerprint(order.Items)
+		if existing, err := uc.replayOrder(ctx, order); err != nil || existing != nil {
+			return existing, err
 		}
+	}
 
-		order.Items[i].Price = product.Price
-		uc.log.Infof("Use Case: Updated item price for Product ID %d to %.2f from inventory", item.ProductID, product.Price)
+	log.Infof("Use Case: Starting inventory check and reservation for order (user %d)", order.UserID)
 
-		currentRequested := item.Quantity
-		if existing, ok := productsInfo[item.ProductID]; ok {
-			currentRequested += existing.OrderQuantity
-		}
+	productIDs := make([]int, 0, len(order.Items))
+	for _, item := range order.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	log.Infof("Use Case: Fetching prices for %d items from inventory", len(order.Items))
+	products, err := uc.inventoryClient.GetProducts(ctx, productIDs)
+	if err != nil {
+		log.Warnf("Use Case: Inventory check failed for order of user %d: %v", order.UserID, err)
+		return nil, fmt.Errorf("inventory check failed: %w", err)
+	}
 
-		if product.Stock < currentRequested {
-			uc.log.Warnf("Use Case: Insufficient stock for Product ID %d (Requested total: %d, Available: %d)", item.ProductID, currentRequested, product.Stock)
-			return nil, fmt.Errorf("insufficient stock for product %d (requested total: %d, available: %d)", item.ProductID, currentRequested, product.Stock)
+	for i, item := range order.Items {
+		product, ok := products[item.ProductID]
+		if !ok {
+			log.Warnf("Use Case: Inventory check failed for Product ID %d: not found", item.ProductID)
+			return nil, fmt.Errorf("inventory check failed for product %d: product with ID %d not found in inventory", item.ProductID, item.ProductID)
 		}
-
-		if existing, ok := productsInfo[item.ProductID]; ok {
-			existing.OrderQuantity += item.Quantity
-			productsInfo[item.ProductID] = existing
-		} else {
-			productsInfo[item.ProductID] = productCheckInfo{
-				Product:       product,
-				OrderQuantity: item.Quantity,
+		switch {
+		case item.VariantID != 0:
+			variant, ok := product.Variant(item.VariantID)
+			if !ok {
+				log.Warnf("Use Case: Inventory check failed for Product ID %d: variant %d not found", item.ProductID, item.VariantID)
+				return nil, fmt.Errorf("inventory check failed for product %d: variant with ID %d not found", item.ProductID, item.VariantID)
 			}
+			order.Items[i].Price = variant.Price
+			order.Items[i].SKU = variant.SKU
+		case len(product.Variants) > 0:
+			return nil, fmt.Errorf("item %d (product %d): invalid item, the product is sold in variants and needs a variant_id", i, item.ProductID)
+		default:
+			order.Items[i].Price = product.Price
+			order.Items[i].SKU = ""
 		}
-		uc.log.Infof("Use Case: Inventory check OK for Product ID %d (Stock: %d >= Requested: %d)", item.ProductID, product.Stock, productsInfo[item.ProductID].OrderQuantity)
 	}
+	log.Infof("Use Case: Updated item prices for order of user %d from inventory", order.UserID)
 
-	successfullyDecreased := make(map[int]int)
-
-	for productID, info := range productsInfo {
-		newStock := info.Product.Stock - info.OrderQuantity
-		uc.log.Infof("Use Case: Attempting to decrease stock via gRPC for Product ID %d from %d to %d", productID, info.Product.Stock, newStock)
-
-		err := uc.inventoryClient.UpdateStock(ctx, productID, newStock)
-		if err != nil {
-			uc.log.Errorf("Use Case: Failed to decrease stock for Product ID %d via gRPC: %v. Rolling back...", productID, err)
-
-			uc.log.Warnf("Use Case: Rolling back inventory changes due to error.")
-			for idToRollback, quantityDecreased := range successfullyDecreased {
-				currentInfoToRollback := productsInfo[idToRollback]
-				quantityDecreased++
-				rollbackStock := currentInfoToRollback.Product.Stock
-				uc.log.Warnf("Use Case: Rolling back Product ID %d to stock %d via gRPC", idToRollback, rollbackStock)
-
-				if rollbackErr := uc.inventoryClient.UpdateStock(ctx, idToRollback, rollbackStock); rollbackErr != nil {
-					uc.log.Errorf("Use Case: CRITICAL! Failed to rollback stock via gRPC for Product ID %d: %v. Manual intervention required!", idToRollback, rollbackErr)
+	// The reservation ID is chosen up front so that every retry of the reserve step hits the same reservation
+	order.ReservationID = uuid.NewString()
+	reserveEvent := &domain.OutboxEvent{
+		Type:    domain.EventStockReserve,
+		Payload: domain.StockEventPayload{ReservationID: order.ReservationID, Items: order.Items},
+	}
 
-				}
-			}
-			return nil, fmt.Errorf("failed to reserve stock for product %d: %w", productID, err)
+	log.Infof("Use Case: Attempting to save order for user %d together with its stock reservation step.", order.UserID)
+	createdOrder, err := uc.orderRepo.CreateOrder(ctx, order, reserveEvent)
+	if err != nil && strings.Contains(err.Error(), "duplicate idempotency key") {
+		// A concurrent retry with the same key got there first
+		if existing, replayErr := uc.replayOrder(ctx, order); replayErr != nil || existing != nil {
+			return existing, replayErr
 		}
-		successfullyDecreased[productID] = info.OrderQuantity
-		uc.log.Infof("Use Case: Successfully decreased stock via gRPC for Product ID %d", productID)
+	}
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to create order for user %d: %v", order.UserID, err)
+		return nil, fmt.Errorf("failed to save order: %w", err)
 	}
 
-	uc.log.Info("Use Case: Inventory reservation successful.")
+	// Reserve right away so the caller learns about missing stock; if inventory is unreachable
+	// the order stays pending and the relay keeps retrying
+	if err := uc.sagaRelay.Dispatch(ctx, reserveEvent.ID); err != nil {
+		if clients.IsRejection(err) {
+			log.Warnf("Use Case: Stock reservation rejected for order %d, order cancelled: %v", createdOrder.ID, err)
+			return nil, reservationFailure(err)
+		}
+		log.Warnf("Use Case: Stock reservation %s for order %d delayed, the relay will retry: %v", order.ReservationID, createdOrder.ID, err)
+	} else {
+		log.Infof("Use Case: Inventory reservation %s successful.", order.ReservationID)
+	}
 
-	uc.log.Infof("Use Case: Attempting to save order for user %d to repository.", order.UserID)
-	createdOrder, err := uc.orderRepo.CreateOrder(order)
-	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to create order for user %d AFTER inventory update: %v. Attempting rollback...", order.UserID, err)
+	log.Infof("Use Case: Order created successfully with ID %d for user %d", createdOrder.ID, createdOrder.UserID)
+	metrics.OrdersCreated.Inc()
+	return createdOrder, nil
+}
 
-		uc.log.Warnf("Use Case: Rolling back inventory changes due to DB error.")
-		for idToRollback, quantityDecreased := range successfullyDecreased {
-			quantityDecreased++
-			currentInfoToRollback := productsInfo[idToRollback]
-			rollbackStock := currentInfoToRollback.Product.Stock
-			uc.log.Warnf("Use Case: Rolling back Product ID %d to stock %d via gRPC due to DB error", idToRollback, rollbackStock)
+// replayOrder returns the order the user already created with the request's idempotency key, or
+// nil if the key is new. Reusing a key for different items is a conflict. If inventory rejected the
+// order's reservation, the retry gets the same error as the request that created the order.
+func (uc *orderUseCase) replayOrder(ctx context.Context, order *domain.Order) (*domain.Order, error) {
+	log := requestid.Logger(ctx, uc.log)
 
-			if rollbackErr := uc.inventoryClient.UpdateStock(ctx, idToRollback, rollbackStock); rollbackErr != nil {
-				uc.log.Errorf("Use Case: CRITICAL! Failed to rollback stock via gRPC for Product ID %d after DB error: %v. Manual intervention required!", idToRollback, rollbackErr)
-			}
+	existing, err := uc.orderRepo.GetOrderByIdempotencyKey(ctx, order.UserID, order.IdempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	if existing.IdempotencyFingerprint != order.IdempotencyFingerprint {
+		log.Warnf("Use Case: User %d reused idempotency key %q of order %d for a different request", order.UserID, order.IdempotencyKey, existing.ID)
+		return nil, fmt.Errorf("idempotency key conflict: key %q was already used for a different order", order.IdempotencyKey)
+	}
+	if existing.Status == domain.StatusCancelled {
+		rejection, err := uc.sagaRelay.ReservationRejection(ctx, existing.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+		}
+		if rejection != nil {
+			log.Infof("Use Case: Order %d created earlier with idempotency key %q was cancelled, its reservation was rejected: %v", existing.ID, order.IdempotencyKey, rejection)
+			return nil, reservationFailure(rejection)
 		}
-		return nil, fmt.Errorf("failed to save order after reserving stock: %w", err)
 	}
+	log.Infof("Use Case: Returning order %d created earlier with idempotency key %q for user %d", existing.ID, order.IdempotencyKey, order.UserID)
+	return existing, nil
+}
 
-	uc.log.Infof("Use Case: Order created successfully with ID %d for user %d", createdOrder.ID, createdOrder.UserID)
-	return createdOrder, nil
+// reservationFailure is the error CreateOrder returns when inventory rejected the order's reservation.
+func reservationFailure(rejection error) error {
+	if strings.Contains(rejection.Error(), "insufficient stock") {
+		return rejection
+	}
+	return fmt.Errorf("failed to reserve stock: %w", rejection)
 }
 
-func (uc *orderUseCase) GetOrderByID(id int) (*domain.Order, error) {
+func (uc *orderUseCase) GetOrderByID(ctx context.Context, id int) (*domain.Order, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if id <= 0 {
 		return nil, errors.New("invalid order ID")
 	}
-	uc.log.Infof("Use Case: Attempting to get order with ID %d", id)
-	order, err := uc.orderRepo.GetOrderByID(id)
+	caller, err := requireCaller(ctx)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to get order ID %d: %v", id, err)
 		return nil, err
 	}
-	uc.log.Infof("Use Case: Order retrieved successfully for ID %d", id)
+	log.Infof("Use Case: Attempting to get order with ID %d", id)
+	order, err := uc.orderRepo.GetOrderByID(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to get order ID %d: %v", id, err)
+		return nil, err
+	}
+	if err := uc.checkOwnership(ctx, caller, order); err != nil {
+		return nil, err
+	}
+	log.Infof("Use Case: Order retrieved successfully for ID %d", id)
 	return order, nil
 }
 
-func (uc *orderUseCase) UpdateOrderStatus(ctx context.Context, id int, status domain.OrderStatus) (*domain.Order, error) {
+func (uc *orderUseCase) UpdateOrderStatus(ctx context.Context, id int, status domain.OrderStatus, reason string) (*domain.Order, error) {
+	log := requestid.Logger(ctx, uc.log)
 
 	if id <= 0 {
 		return nil, errors.New("invalid order ID for status update")
@@ -173,77 +244,304 @@ func (uc *orderUseCase) UpdateOrderStatus(ctx context.Context, id int, status do
 	if !domain.IsValidStatus(status) {
 		return nil, fmt.Errorf("invalid target order status: %s", status)
 	}
+	if len(reason) > domain.MaxStatusReasonLength {
+		return nil, fmt.Errorf("invalid reason: must be at most %d characters", domain.MaxStatusReasonLength)
+	}
 
-	uc.log.Infof("Use Case: Attempting to update status for order ID %d to '%s'", id, status)
-
-	currentOrder, err := uc.orderRepo.GetOrderByID(id)
+	caller, err := requireCaller(ctx)
 	if err != nil {
-		uc.log.Warnf("Use Case: Could not get current order %d for status update check: %v", id, err)
 		return nil, err
 	}
-	uc.log.Infof("Use Case: Current status for order %d is '%s'", id, currentOrder.Status)
 
-	if currentOrder.Status == domain.StatusCompleted && status == domain.StatusCancelled {
-		uc.log.Warnf("Use Case: Attempt to cancel an already completed order %d", id)
-		return nil, errors.New("cannot cancel a completed order")
+	log.Infof("Use Case: Attempting to update status for order ID %d to '%s'", id, status)
+
+	currentOrder, err := uc.orderRepo.GetOrderByID(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Could not get current order %d for status update check: %v", id, err)
+		return nil, err
 	}
-	if currentOrder.Status == domain.StatusCancelled && status != domain.StatusCancelled {
-		uc.log.Warnf("Use Case: Attempt to change status of an already cancelled order %d", id)
-		return nil, errors.New("cannot change status of a cancelled order")
+	if err := uc.checkOwnership(ctx, caller, currentOrder); err != nil {
+		return nil, err
 	}
+	if status != domain.StatusCancelled && !caller.IsStaff() {
+		log.Warnf("Use Case: User %d attempted to set status '%s' on order %d without staff role", caller.UserID, status, id)
+		return nil, errors.New("permission denied: customers can only cancel their orders")
+	}
+	log.Infof("Use Case: Current status for order %d is '%s'", id, currentOrder.Status)
 
-	isCancelling := status == domain.StatusCancelled && currentOrder.Status != domain.StatusCancelled
-	if isCancelling {
-		uc.log.Infof("Use Case: Order %d is being cancelled. Returning items to inventory via gRPC.", id)
-		for _, item := range currentOrder.Items {
-
-			product, err := uc.inventoryClient.GetProduct(ctx, item.ProductID)
-			if err != nil {
+	if currentOrder.Status == status {
+		log.Infof("Use Case: Order %d is already '%s', nothing to do", id, status)
+		return currentOrder, nil
+	}
+	effects, err := domain.TransitionEffects(currentOrder.Status, status)
+	if err != nil {
+		log.Warnf("Use Case: Rejected status change of order %d: %v", id, err)
+		return nil, err
+	}
 
-				uc.log.Errorf("Use Case: CRITICAL! Failed to get product %d info via gRPC to return stock for cancelled order %d: %v. Manual stock adjustment needed!", item.ProductID, id, err)
-				continue
-			}
+	var events []*domain.OutboxEvent
+	if effects.Has(domain.EffectReleaseStock) {
+		log.Infof("Use Case: Order %d is moving to '%s'. Queueing the return of its stock to inventory.", id, status)
+		events = append(events, releaseEvent(currentOrder))
+	}
 
-			newStock := product.Stock + item.Quantity
-			uc.log.Warnf("Use Case: Returning stock via gRPC for Product ID %d (Order: %d). Current: %d, Quantity: %d, New: %d", item.ProductID, id, product.Stock, item.Quantity, newStock)
+	if effects.Has(domain.EffectConfirmStock) && currentOrder.ReservationID != "" {
+		log.Infof("Use Case: Order %d is moving to '%s'. Confirming reservation %s via gRPC.", id, status, currentOrder.ReservationID)
+		if err := uc.inventoryClient.ConfirmReservation(ctx, currentOrder.ReservationID); err != nil {
+			log.Warnf("Use Case: Failed to confirm reservation %s for order %d: %v", currentOrder.ReservationID, id, err)
+			return nil, fmt.Errorf("cannot move order %d to %s: %w", id, status, err)
+		}
+	}
+	if effects.Has(domain.EffectFulfillStock) && currentOrder.ReservationID != "" {
+		log.Infof("Use Case: Order %d is moving to '%s'. Fulfilling reservation %s via gRPC.", id, status, currentOrder.ReservationID)
+		if err := uc.inventoryClient.FulfillReservation(ctx, currentOrder.ReservationID); err != nil {
+			log.Warnf("Use Case: Failed to fulfill reservation %s for order %d: %v", currentOrder.ReservationID, id, err)
+			return nil, fmt.Errorf("cannot move order %d to %s: %w", id, status, err)
+		}
+	}
 
-			err = uc.inventoryClient.UpdateStock(ctx, item.ProductID, newStock)
-			if err != nil {
+	log.Infof("Use Case: Attempting to update order status in repository for ID %d to '%s'", id, status)
+	updatedOrder, err := uc.orderRepo.UpdateOrderStatus(ctx, id, currentOrder.Status, status, reason, events...)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to update status for order ID %d: %v", id, err)
+		return nil, err
+	}
 
-				uc.log.Errorf("Use Case: CRITICAL! Failed to return stock via gRPC for Product ID %d (quantity %d) for cancelled order %d: %v. Manual stock adjustment needed!", item.ProductID, item.Quantity, id, err)
-			} else {
-				uc.log.Infof("Use Case: Successfully returned stock via gRPC for Product ID %d", item.ProductID)
-			}
+	log.Infof("Use Case: Order status updated successfully for ID %d to %s", updatedOrder.ID, updatedOrder.Status)
+	if status == domain.StatusCancelled {
+		metrics.OrdersCancelled.Inc()
+	}
+	if effects.Has(domain.EffectRefund) {
+		// There is no payment provider integration yet; the refund is recorded for finance to settle
+		log.Infof("Use Case: Refund of %.2f due to user %d for order %d", updatedOrder.Total(), updatedOrder.UserID, id)
+		metrics.OrdersRefunded.Inc()
+	}
+	if len(events) > 0 {
+		// Best effort; the release is already stored and the relay retries it if this attempt fails
+		if err := uc.sagaRelay.Dispatch(ctx, events[0].ID); err != nil {
+			log.Warnf("Use Case: Stock return for order %d not delivered yet: %v", id, err)
 		}
 	}
+	return updatedOrder, nil
+}
 
-	uc.log.Infof("Use Case: Attempting to update order status in repository for ID %d to '%s'", id, status)
-	updatedOrder, err := uc.orderRepo.UpdateOrderStatus(id, status)
+func (uc *orderUseCase) GetOrderHistory(ctx context.Context, id int) ([]domain.OrderStatusChange, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if id <= 0 {
+		return nil, errors.New("invalid order ID")
+	}
+	caller, err := requireCaller(ctx)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to update status for order ID %d: %v", id, err)
+		return nil, err
+	}
 
-		if isCancelling {
-			uc.log.Errorf("Use Case: WARNING! Failed to update order status to CANCELLED in DB after attempting inventory stock return for order %d. Potential inconsistency!", id)
-		}
+	order, err := uc.orderRepo.GetOrderByID(ctx, id)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to get order ID %d for its history: %v", id, err)
+		return nil, err
+	}
+	if err := uc.checkOwnership(ctx, caller, order); err != nil {
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: Order status updated successfully for ID %d to %s", updatedOrder.ID, updatedOrder.Status)
-	return updatedOrder, nil
+	changes, err := uc.orderRepo.ListStatusHistory(ctx, id)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to list status history of order %d: %v", id, err)
+		return nil, fmt.Errorf("could not retrieve status history of order %d: %w", id, err)
+	}
+	log.Infof("Use Case: Retrieved %d status changes for order %d", len(changes), id)
+	return changes, nil
 }
 
-func (uc *orderUseCase) ListOrdersByUserID(userID int, limit, offset int) ([]domain.Order, error) {
+func (uc *orderUseCase) ListOrdersByUserID(ctx context.Context, userID int, limit, offset int, pageToken string) (*domain.OrderPage, error) {
+	log := requestid.Logger(ctx, uc.log)
 	if userID <= 0 {
 		return nil, errors.New("invalid user ID")
 	}
+	caller, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if caller.UserID != userID && !caller.IsStaff() {
+		log.Warnf("Use Case: User %d attempted to list orders of user %d", caller.UserID, userID)
+		return nil, errors.New("permission denied: cannot list orders of another user")
+	}
+
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
 
-	uc.log.Infof("Use Case: Attempting to list orders for user %d (limit: %d, offset: %d)", userID, limit, offset)
-	orders, err := uc.orderRepo.ListOrdersByUserID(userID, limit, offset)
+	log.Infof("Use Case: Attempting to list orders for user %d (limit: %d, offset: %d, continued: %t)", userID, limit, offset, pageToken != "")
+	page, err := uc.orderRepo.ListOrdersByUserID(ctx, userID, limit, offset, pageToken)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to list orders for user %d: %v", userID, err)
+		log.Errorf("Use Case: Repository failed to list orders for user %d: %v", userID, err)
 		return nil, fmt.Errorf("could not retrieve orders for user %d: %w", userID, err)
 	}
 
-	uc.log.Infof("Use Case: Retrieved %d orders for user %d", len(orders), userID)
-	return orders, nil
+	log.Infof("Use Case: Retrieved %d of %d orders for user %d", len(page.Orders), page.TotalSize, userID)
+	return page, nil
+}
+
+// itemLine is what stock is kept for: a product, or one variant of it.
+type itemLine struct {
+	productID, variantID int
+}
+
+func (l itemLine) String() string {
+	if l.variantID == 0 {
+		return fmt.Sprintf("product %d", l.productID)
+	}
+	return fmt.Sprintf("product %d variant %d", l.productID, l.variantID)
+}
+
+// sortItemLines orders lines by product, then variant.
+func sortItemLines(lines []itemLine) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].productID != lines[j].productID {
+			return lines[i].productID < lines[j].productID
+		}
+		return lines[i].variantID < lines[j].variantID
+	})
+}
+
+// orderFingerprint identifies the items of a create request regardless of their order or how
+// quantities of one product are split across lines. Prices are not part of it: they come from inventory.
+func orderFingerprint(items []domain.OrderItem) string {
+	quantities := make(map[itemLine]int)
+	for _, item := range items {
+		quantities[itemLine{item.ProductID, item.VariantID}] += item.Quantity
+	}
+	lines := make([]itemLine, 0, len(quantities))
+	for line := range quantities {
+		lines = append(lines, line)
+	}
+	sortItemLines(lines)
+
+	// Lines without a variant hash as before variants existed, so older keys still replay
+	hash := sha256.New()
+	for _, line := range lines {
+		if line.variantID == 0 {
+			fmt.Fprintf(hash, "%d:%d;", line.productID, quantities[line])
+		} else {
+			fmt.Fprintf(hash, "%d/%d:%d;", line.productID, line.variantID, quantities[line])
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (uc *orderUseCase) SearchOrders(ctx context.Context, filter domain.OrderSearchFilter) (*domain.OrderSearchResult, error) {
+	log := requestid.Logger(ctx, uc.log)
+
+	caller, err := requireCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsAdmin() {
+		log.Warnf("Use Case: User %d attempted to search all orders without admin role", caller.UserID)
+		return nil, errors.New("permission denied: searching all orders requires the admin role")
+	}
+
+	for _, status := range filter.Statuses {
+		if !domain.IsValidStatus(status) {
+			return nil, fmt.Errorf("invalid status filter: %s", status)
+		}
+	}
+	if filter.SortBy == "" {
+		filter.SortBy = domain.OrderSortCreatedAt
+	}
+	if filter.SortBy != domain.OrderSortCreatedAt && filter.SortBy != domain.OrderSortTotal {
+		return nil, fmt.Errorf("invalid sort field %q: expected %s or %s", filter.SortBy, domain.OrderSortCreatedAt, domain.OrderSortTotal)
+	}
+	if filter.MinTotal < 0 {
+		return nil, errors.New("invalid minimum total: cannot be negative")
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		return nil, errors.New("invalid date range: created_from must be before created_to")
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSearchLimit
+	}
+	if filter.Limit > maxSearchLimit {
+		filter.Limit = maxSearchLimit
+	}
+
+	log.Infof("Use Case: Admin %d searching orders (statuses: %v, user: %d, product: %d, sort: %s)",
+		caller.UserID, filter.Statuses, filter.UserID, filter.ProductID, filter.SortBy)
+	result, err := uc.orderRepo.SearchOrders(ctx, filter)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to search orders: %v", err)
+		return nil, err
+	}
+	log.Infof("Use Case: Order search returned %d orders", len(result.Orders))
+	return result, nil
+}
+
+func (uc *orderUseCase) CountLegacyOrdersHoldingStock(ctx context.Context, productID int) (int, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if productID <= 0 {
+		return 0, errors.New("invalid product ID")
+	}
+	caller, err := requireCaller(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !caller.IsStaff() {
+		log.Warnf("Use Case: User %d attempted to count orders holding stock of product %d without staff role", caller.UserID, productID)
+		return 0, errors.New("permission denied: counting orders of all users requires a staff role")
+	}
+
+	count, err := uc.orderRepo.CountLegacyOrdersHoldingStock(ctx, productID, domain.StockHoldingStatuses())
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to count legacy orders holding stock of product %d: %v", productID, err)
+		return 0, err
+	}
+	log.Infof("Use Case: %d orders without a reservation still hold stock of product %d", count, productID)
+	return count, nil
+}
+
+func toStockItems(items []domain.OrderItem) []clients.StockItem {
+	stockItems := make([]clients.StockItem, 0, len(items))
+	for _, item := range items {
+		stockItems = append(stockItems, clients.StockItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity})
+	}
+	return stockItems
+}
+
+// releaseEvent builds the saga step that gives an order's stock back to inventory: by cancelling
+// its reservation, or for orders placed before reservations existed, by releasing the items directly.
+func releaseEvent(order *domain.Order) *domain.OutboxEvent {
+	payload := domain.StockEventPayload{ReservationID: order.ReservationID}
+	if order.ReservationID == "" {
+		payload.Items = order.Items
+		payload.IdempotencyKey = legacyReleaseKey(order.ID)
+	}
+	return &domain.OutboxEvent{Type: domain.EventStockRelease, Payload: payload}
+}
+
+// legacyReleaseKey is the idempotency key under which an order placed without a reservation
+// returns its stock. An order releases its stock at most once, whichever transition triggers it.
+func legacyReleaseKey(orderID int) string {
+	return fmt.Sprintf("order-%d-cancel", orderID)
+}
+
+// requireCaller returns the authenticated caller attached to ctx by the auth interceptor.
+func requireCaller(ctx context.Context) (*domain.Caller, error) {
+	caller, ok := domain.CallerFromContext(ctx)
+	if !ok {
+		return nil, errors.New("unauthenticated: caller identity is required")
+	}
+	return caller, nil
+}
+
+// checkOwnership hides orders of other users from non-staff callers by reporting them as not found.
+func (uc *orderUseCase) checkOwnership(ctx context.Context, caller *domain.Caller, order *domain.Order) error {
+	log := requestid.Logger(ctx, uc.log)
+	if order.UserID == caller.UserID || caller.IsStaff() {
+		return nil
+	}
+	log.Warnf("Use Case: User %d attempted to access order %d owned by user %d", caller.UserID, order.ID, order.UserID)
+	return fmt.Errorf("order with id %d not found", order.ID)
 }
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"order_service/internal/domain"
+	"testing"
+)
+
+func TestOrderFingerprint(t *testing.T) {
+	base := []domain.OrderItem{
+		{ProductID: 7, Quantity: 2, Price: 10},
+		{ProductID: 3, Quantity: 1, Price: 5},
+	}
+	tests := []struct {
+		name  string
+		items []domain.OrderItem
+		same  bool // Whether it must match the fingerprint of base
+	}{
+		{name: "same items", items: base, same: true},
+		{name: "different line order", items: []domain.OrderItem{
+			{ProductID: 3, Quantity: 1},
+			{ProductID: 7, Quantity: 2},
+		}, same: true},
+		{name: "quantity split across lines", items: []domain.OrderItem{
+			{ProductID: 7, Quantity: 1},
+			{ProductID: 3, Quantity: 1},
+			{ProductID: 7, Quantity: 1},
+		}, same: true},
+		{name: "different prices", items: []domain.OrderItem{
+			{ProductID: 7, Quantity: 2, Price: 99},
+			{ProductID: 3, Quantity: 1, Price: 1},
+		}, same: true},
+		{name: "different quantity", items: []domain.OrderItem{
+			{ProductID: 7, Quantity: 3},
+			{ProductID: 3, Quantity: 1},
+		}},
+		{name: "missing line", items: []domain.OrderItem{
+			{ProductID: 7, Quantity: 2},
+		}},
+		{name: "variant of the same product", items: []domain.OrderItem{
+			{ProductID: 7, VariantID: 9, Quantity: 2},
+			{ProductID: 3, Quantity: 1},
+		}},
+	}
+
+	want := orderFingerprint(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orderFingerprint(tt.items)
+			if (got == want) != tt.same {
+				t.Errorf("orderFingerprint(%+v) = %s, matches %s: %t, want %t", tt.items, got, want, got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestOrderFingerprintFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []domain.OrderItem
+		want  string
+	}{
+		{
+			// Keys stored before variants existed must keep replaying
+			name:  "lines without a variant",
+			items: []domain.OrderItem{{ProductID: 7, Quantity: 2}, {ProductID: 3, Quantity: 1}},
+			want:  "8b6e2a19db266e08e8a8b7f725246afe8db6c28f9c24134fddfbf74b10156b03", // sha256("3:1;7:2;")
+		},
+		{
+			name:  "line with a variant",
+			items: []domain.OrderItem{{ProductID: 7, VariantID: 9, Quantity: 2}},
+			want:  "0c96c159262b72816e75d8c8eb113eb91e2f88f719f6a7e1de217ff79a489acd", // sha256("7/9:2;")
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := orderFingerprint(tt.items); got != tt.want {
+				t.Errorf("orderFingerprint(%+v) = %s, want %s", tt.items, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,355 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order_service/internal/clients"
+	"order_service/internal/domain"
+	"order_service/internal/metrics"
+	"order_service/internal/requestid"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileBatchSize matches the most reservations inventory returns per GetReservations call.
+const reconcileBatchSize = 100
+
+// Reconciler compares every order with the reservation that inventory holds for it, or, for orders
+// placed without a reservation, with the stock releases inventory has recorded.
+type Reconciler interface {
+	// Reconcile walks all orders and reports the ones out of sync with inventory. With repair set,
+	// safe fixes are queued through the outbox (or, for confirmations, fulfilments and cancellations
+	// of abandoned orders, applied directly).
+	Reconcile(ctx context.Context, repair bool) (*domain.ReconciliationReport, error)
+}
+
+type reconciler struct {
+	orderRepo       domain.OrderRepository
+	outboxRepo      domain.OutboxRepository
+	inventoryClient clients.InventoryClient
+	log             *logrus.Logger
+}
+
+func NewReconciler(orderRepo domain.OrderRepository, outboxRepo domain.OutboxRepository, invClient clients.InventoryClient, logger *logrus.Logger) Reconciler {
+	return &reconciler{
+		orderRepo:       orderRepo,
+		outboxRepo:      outboxRepo,
+		inventoryClient: invClient,
+		log:             logger,
+	}
+}
+
+func (r *reconciler) Reconcile(ctx context.Context, repair bool) (*domain.ReconciliationReport, error) {
+	log := requestid.Logger(ctx, r.log)
+	report := &domain.ReconciliationReport{
+		StartedAt:     time.Now().UTC(),
+		Repair:        repair,
+		Discrepancies: []domain.Discrepancy{},
+	}
+	log.Infof("Use Case: Starting inventory reconciliation (repair: %t)", repair)
+
+	afterID := 0
+	for {
+		orders, err := r.orderRepo.ScanOrders(ctx, afterID, reconcileBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("reconciliation failed after order %d: %w", afterID, err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+		afterID = orders[len(orders)-1].ID
+
+		if err := r.reconcileBatch(ctx, orders, repair, report); err != nil {
+			return nil, fmt.Errorf("reconciliation failed after order %d: %w", afterID, err)
+		}
+		if len(orders) < reconcileBatchSize {
+			break
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	recordDiscrepancies(report)
+	log.Infof("Use Case: Reconciliation checked %d orders (%d skipped): %d discrepancies, %d unresolved",
+		report.OrdersChecked, report.OrdersSkipped, len(report.Discrepancies), report.Unresolved())
+	return report, nil
+}
+
+func (r *reconciler) reconcileBatch(ctx context.Context, orders []domain.Order, repair bool, report *domain.ReconciliationReport) error {
+	orderIDs := make([]int, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+	inFlight, err := r.outboxRepo.OrdersWithPendingEvents(ctx, orderIDs)
+	if err != nil {
+		return err
+	}
+
+	var toCheck, legacy []domain.Order
+	reservationIDs := []string{}
+	for _, order := range orders {
+		// In-flight sagas are not settled yet
+		if inFlight[order.ID] {
+			report.OrdersSkipped++
+			continue
+		}
+		if order.ReservationID == "" {
+			// Stock of a live order placed without a reservation left no trace in inventory to compare against
+			if order.Status != domain.StatusCancelled && order.Status != domain.StatusRefunded {
+				report.OrdersSkipped++
+				continue
+			}
+			legacy = append(legacy, order)
+			continue
+		}
+		toCheck = append(toCheck, order)
+		reservationIDs = append(reservationIDs, order.ReservationID)
+	}
+	if err := r.reconcileLegacyReleases(ctx, legacy, repair, report); err != nil {
+		return err
+	}
+	if len(toCheck) == 0 {
+		return nil
+	}
+
+	found, err := r.inventoryClient.GetReservations(ctx, reservationIDs)
+	if err != nil {
+		return err
+	}
+	reservations := make(map[string]*clients.Reservation, len(found))
+	for i := range found {
+		reservations[found[i].ID] = &found[i]
+	}
+
+	for i := range toCheck {
+		report.OrdersChecked++
+		discrepancy := compareWithReservation(&toCheck[i], reservations[toCheck[i].ReservationID])
+		if discrepancy == nil {
+			continue
+		}
+		if repair && discrepancy.Repairable {
+			r.repair(ctx, &toCheck[i], discrepancy)
+		}
+		report.Discrepancies = append(report.Discrepancies, *discrepancy)
+	}
+	return nil
+}
+
+// reconcileLegacyReleases checks that cancelled and refunded orders placed without a reservation got
+// their stock back, which inventory records under the order's release key. Only releases queued
+// through the outbox are recorded; orders whose stock was returned before that are skipped.
+func (r *reconciler) reconcileLegacyReleases(ctx context.Context, orders []domain.Order, repair bool, report *domain.ReconciliationReport) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	orderIDs := make([]int, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+	queued, err := r.outboxRepo.OrdersWithEvent(ctx, orderIDs, domain.EventStockRelease)
+	if err != nil {
+		return err
+	}
+
+	var toCheck []domain.Order
+	keys := []string{}
+	for _, order := range orders {
+		// Refunds of delivered goods queue no release either, as they have nothing to return
+		if !queued[order.ID] {
+			report.OrdersSkipped++
+			continue
+		}
+		toCheck = append(toCheck, order)
+		keys = append(keys, legacyReleaseKey(order.ID))
+	}
+	if len(toCheck) == 0 {
+		return nil
+	}
+
+	found, err := r.inventoryClient.GetAppliedReleases(ctx, keys)
+	if err != nil {
+		return err
+	}
+	applied := make(map[string]bool, len(found))
+	for _, key := range found {
+		applied[key] = true
+	}
+
+	for i := range toCheck {
+		report.OrdersChecked++
+		if applied[legacyReleaseKey(toCheck[i].ID)] {
+			continue
+		}
+		// The release key makes queueing the release again safe even if inventory did apply it
+		discrepancy := &domain.Discrepancy{
+			OrderID:     toCheck[i].ID,
+			OrderStatus: toCheck[i].Status,
+			Kind:        domain.DiscrepancyReleaseUnverified,
+			Detail:      fmt.Sprintf("order is %s but inventory has no record of its stock being returned", toCheck[i].Status),
+			Repairable:  true,
+		}
+		if repair {
+			r.repair(ctx, &toCheck[i], discrepancy)
+		}
+		report.Discrepancies = append(report.Discrepancies, *discrepancy)
+	}
+	return nil
+}
+
+// compareWithReservation returns the discrepancy between an order and its reservation, or nil
+// if they agree. A nil reservation means inventory does not know the reservation.
+func compareWithReservation(order *domain.Order, reservation *clients.Reservation) *domain.Discrepancy {
+	d := &domain.Discrepancy{
+		OrderID:       order.ID,
+		OrderStatus:   order.Status,
+		ReservationID: order.ReservationID,
+	}
+	if reservation != nil {
+		d.ReservationStatus = reservation.Status
+	}
+
+	// Cancelled and refunded orders are checked only for stock that was never returned. Refunds of
+	// delivered goods legitimately keep their reservation fulfilled, or confirmed if they shipped
+	// before reservations were fulfilled.
+	switch order.Status {
+	case domain.StatusCancelled:
+		switch {
+		case reservation == nil, reservation.Status == clients.ReservationCancelled, reservation.Status == clients.ReservationExpired:
+			return nil
+		}
+		d.Kind, d.Detail, d.Repairable = domain.DiscrepancyStockNotReturned, fmt.Sprintf("order is cancelled but its reservation is still %s", reservation.Status), true
+		return d
+	case domain.StatusRefunded:
+		if reservation == nil || reservation.Status != clients.ReservationPending {
+			return nil
+		}
+		d.Kind, d.Detail, d.Repairable = domain.DiscrepancyStockNotReturned, "order is refunded but its reservation still holds stock", true
+		return d
+	}
+
+	if reservation == nil {
+		d.Kind, d.Detail = domain.DiscrepancyReservationMissing, "inventory has no reservation for this order"
+		// A pending order can simply have its reserve step queued again; the saga cancels it if stock has run out
+		d.Repairable = order.Status == domain.StatusPending
+		return d
+	}
+	if detail := diffItems(order.Items, reservation.Items); detail != "" {
+		d.Kind, d.Detail = domain.DiscrepancyQuantityMismatch, detail
+		return d
+	}
+
+	switch reservation.Status {
+	case clients.ReservationCancelled, clients.ReservationExpired:
+		d.Kind = domain.DiscrepancyReservationLapsed
+		d.Detail = fmt.Sprintf("order is %s but its reservation is %s, so its stock is no longer held", order.Status, reservation.Status)
+		// An unpaid order whose hold ran out was abandoned; it cannot be confirmed any more
+		d.Repairable = order.Status == domain.StatusPending
+		return d
+	case clients.ReservationPending:
+		// Every status past pending was reached by paying for the order, which confirms the reservation
+		if order.Status != domain.StatusPending {
+			d.Kind, d.Detail = domain.DiscrepancyReservationUnconfirmed, fmt.Sprintf("order is %s but its reservation is still pending", order.Status)
+			d.Repairable = reservation.ExpiresAt.After(time.Now())
+			return d
+		}
+	case clients.ReservationConfirmed:
+		switch order.Status {
+		case domain.StatusShipped, domain.StatusDelivered, domain.StatusCompleted:
+			d.Kind, d.Detail, d.Repairable = domain.DiscrepancyReservationUnfulfilled, fmt.Sprintf("order is %s but its reservation still holds its stock", order.Status), true
+			return d
+		}
+	}
+	return nil
+}
+
+// diffItems describes how the reserved quantities differ from the order items, or returns "" if they match.
+func diffItems(orderItems []domain.OrderItem, reserved []clients.StockItem) string {
+	want := make(map[itemLine]int)
+	for _, item := range orderItems {
+		want[itemLine{item.ProductID, item.VariantID}] += item.Quantity
+	}
+	got := make(map[itemLine]int)
+	for _, item := range reserved {
+		got[itemLine{item.ProductID, item.VariantID}] += item.Quantity
+	}
+
+	lines := make([]itemLine, 0, len(want)+len(got))
+	for line := range want {
+		lines = append(lines, line)
+	}
+	for line := range got {
+		if _, ok := want[line]; !ok {
+			lines = append(lines, line)
+		}
+	}
+	sortItemLines(lines)
+
+	var diffs []string
+	for _, line := range lines {
+		if want[line] != got[line] {
+			diffs = append(diffs, fmt.Sprintf("%s: ordered %d, reserved %d", line, want[line], got[line]))
+		}
+	}
+	return strings.Join(diffs, "; ")
+}
+
+func (r *reconciler) repair(ctx context.Context, order *domain.Order, d *domain.Discrepancy) {
+	log := requestid.Logger(ctx, r.log)
+
+	var err error
+	switch d.Kind {
+	case domain.DiscrepancyStockNotReturned, domain.DiscrepancyReleaseUnverified:
+		event := releaseEvent(order)
+		event.OrderID = order.ID
+		err = r.outboxRepo.EnqueueEvents(ctx, event)
+	case domain.DiscrepancyReservationMissing:
+		err = r.outboxRepo.EnqueueEvents(ctx, &domain.OutboxEvent{
+			OrderID: order.ID,
+			Type:    domain.EventStockReserve,
+			Payload: domain.StockEventPayload{ReservationID: order.ReservationID, Items: order.Items},
+		})
+	case domain.DiscrepancyReservationLapsed:
+		// The stock is already back in inventory, so nothing needs to be released
+		_, err = r.orderRepo.UpdateOrderStatus(domain.ContextWithActor(ctx, domain.ActorReconciler), order.ID,
+			domain.StatusPending, domain.StatusCancelled, fmt.Sprintf("stock reservation %s", d.ReservationStatus))
+		if err == nil {
+			metrics.OrdersCancelled.Inc()
+		}
+	case domain.DiscrepancyReservationUnconfirmed:
+		err = r.inventoryClient.ConfirmReservation(ctx, order.ReservationID)
+	case domain.DiscrepancyReservationUnfulfilled:
+		err = r.inventoryClient.FulfillReservation(ctx, order.ReservationID)
+	default:
+		err = fmt.Errorf("no repair for %s", d.Kind)
+	}
+
+	if err != nil {
+		log.Errorf("Use Case: Failed to repair %s for order %d: %v", d.Kind, order.ID, err)
+		d.RepairError = err.Error()
+		return
+	}
+	log.Infof("Use Case: Repaired %s for order %d", d.Kind, order.ID)
+	d.Repaired = true
+}
+
+// recordDiscrepancies publishes the unresolved discrepancies of the latest run by kind.
+func recordDiscrepancies(report *domain.ReconciliationReport) {
+	counts := map[domain.DiscrepancyKind]int{
+		domain.DiscrepancyStockNotReturned:       0,
+		domain.DiscrepancyReservationMissing:     0,
+		domain.DiscrepancyReservationLapsed:      0,
+		domain.DiscrepancyReservationUnconfirmed: 0,
+		domain.DiscrepancyReservationUnfulfilled: 0,
+		domain.DiscrepancyQuantityMismatch:       0,
+		domain.DiscrepancyReleaseUnverified:      0,
+	}
+	for _, d := range report.Discrepancies {
+		if !d.Repaired {
+			counts[d.Kind]++
+		}
+	}
+	for kind, count := range counts {
+		metrics.InventoryDiscrepancies.WithLabelValues(string(kind)).Set(float64(count))
+	}
+}
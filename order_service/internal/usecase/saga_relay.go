@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"order_service/internal/clients"
+	"order_service/internal/domain"
+	"order_service/internal/metrics"
+	"order_service/internal/requestid"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outboxLease is how long a claimed event is hidden from other workers; a worker that
+// crashes mid-call leaves the event to be picked up again once the lease runs out.
+const outboxLease = 30 * time.Second
+
+// SagaRelay delivers outbox events to inventory_service. Transient failures are retried with
+// exponential backoff; a rejected or abandoned step runs its compensation.
+type SagaRelay interface {
+	// Dispatch delivers a single event right away. It returns the *clients.RejectionError if inventory
+	// refused the step, and nil if the event was delivered or is being handled by another worker.
+	Dispatch(ctx context.Context, eventID int64) error
+	// ProcessDue delivers a batch of due events and returns how many were claimed.
+	ProcessDue(ctx context.Context) (int, error)
+	// ReservationRejection returns the refusal with which inventory turned down the order's stock
+	// reservation, or nil if it did not (yet) turn it down.
+	ReservationRejection(ctx context.Context, orderID int) (*clients.RejectionError, error)
+}
+
+// giveUpPrefix starts the last error of an event the relay stopped retrying, which tells it apart
+// from an event that inventory rejected.
+const giveUpPrefix = "gave up after"
+
+type sagaRelay struct {
+	outboxRepo      domain.OutboxRepository
+	orderRepo       domain.OrderRepository
+	inventoryClient clients.InventoryClient
+	batchSize       int
+	maxAttempts     int
+	baseBackoff     time.Duration
+	maxBackoff      time.Duration
+	log             *logrus.Logger
+}
+
+func NewSagaRelay(outboxRepo domain.OutboxRepository, orderRepo domain.OrderRepository, invClient clients.InventoryClient,
+	batchSize, maxAttempts int, baseBackoff, maxBackoff time.Duration, logger *logrus.Logger) SagaRelay {
+	return &sagaRelay{
+		outboxRepo:      outboxRepo,
+		orderRepo:       orderRepo,
+		inventoryClient: invClient,
+		batchSize:       batchSize,
+		maxAttempts:     maxAttempts,
+		baseBackoff:     baseBackoff,
+		maxBackoff:      maxBackoff,
+		log:             logger,
+	}
+}
+
+func (r *sagaRelay) Dispatch(ctx context.Context, eventID int64) error {
+	log := requestid.Logger(ctx, r.log)
+
+	event, err := r.outboxRepo.ClaimEvent(ctx, eventID, outboxLease)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		log.Infof("Use Case: Outbox event %d is already being handled", eventID)
+		return nil
+	}
+	return r.process(ctx, event)
+}
+
+func (r *sagaRelay) ProcessDue(ctx context.Context) (int, error) {
+	log := requestid.Logger(ctx, r.log)
+
+	events, err := r.outboxRepo.ClaimDueEvents(ctx, r.batchSize, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+	for i := range events {
+		if ctx.Err() != nil {
+			// Unprocessed events are picked up again once their lease expires
+			break
+		}
+		if err := r.process(ctx, &events[i]); err != nil && !clients.IsRejection(err) {
+			log.Warnf("Use Case: Outbox event %d (%s) for order %d not delivered: %v", events[i].ID, events[i].Type, events[i].OrderID, err)
+		}
+	}
+	return len(events), nil
+}
+
+func (r *sagaRelay) ReservationRejection(ctx context.Context, orderID int) (*clients.RejectionError, error) {
+	event, err := r.outboxRepo.LatestEvent(ctx, orderID, domain.EventStockReserve)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil || event.Status != domain.OutboxFailed || strings.HasPrefix(event.LastError, giveUpPrefix) {
+		return nil, nil
+	}
+	return &clients.RejectionError{Message: event.LastError}, nil
+}
+
+// process performs the event's inventory call and records the outcome.
+func (r *sagaRelay) process(ctx context.Context, event *domain.OutboxEvent) error {
+	log := requestid.Logger(ctx, r.log)
+	log.Infof("Use Case: Delivering outbox event %d (%s) for order %d, attempt %d", event.ID, event.Type, event.OrderID, event.Attempts)
+
+	var err error
+	switch event.Type {
+	case domain.EventStockReserve:
+		err = r.reserve(ctx, event)
+	case domain.EventStockRelease:
+		err = r.release(ctx, event)
+	default:
+		err = &clients.RejectionError{Message: fmt.Sprintf("unknown outbox event type %q", event.Type)}
+	}
+
+	if err == nil {
+		log.Infof("Use Case: Outbox event %d (%s) for order %d delivered", event.ID, event.Type, event.OrderID)
+		return r.outboxRepo.MarkEventDone(ctx, event.ID)
+	}
+	if clients.IsRejection(err) {
+		log.Warnf("Use Case: Inventory rejected outbox event %d (%s) for order %d: %v", event.ID, event.Type, event.OrderID, err)
+		if failErr := r.fail(ctx, event, err); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+	if event.Attempts >= r.maxAttempts {
+		log.Errorf("Use Case: Giving up on outbox event %d (%s) for order %d after %d attempts: %v", event.ID, event.Type, event.OrderID, event.Attempts, err)
+		if failErr := r.fail(ctx, event, fmt.Errorf("%s %d attempts: %w", giveUpPrefix, event.Attempts, err)); failErr != nil {
+			return failErr
+		}
+		return err
+	}
+
+	nextAttemptAt := time.Now().Add(r.backoff(event.Attempts))
+	log.Warnf("Use Case: Outbox event %d (%s) for order %d failed, retrying at %s: %v",
+		event.ID, event.Type, event.OrderID, nextAttemptAt.Format(time.RFC3339), err)
+	if retryErr := r.outboxRepo.ScheduleRetry(ctx, event.ID, nextAttemptAt, err.Error()); retryErr != nil {
+		return retryErr
+	}
+	return err
+}
+
+func (r *sagaRelay) reserve(ctx context.Context, event *domain.OutboxEvent) error {
+	log := requestid.Logger(ctx, r.log)
+
+	order, err := r.orderRepo.GetOrderByID(ctx, event.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status == domain.StatusCancelled {
+		log.Infof("Use Case: Order %d was cancelled before its stock was reserved, skipping reservation", order.ID)
+		return nil
+	}
+	if err := r.inventoryClient.ReserveStock(ctx, event.Payload.ReservationID, toStockItems(event.Payload.Items)); err != nil {
+		return err
+	}
+
+	// A cancellation that came in while the stock was being reserved may have found no reservation
+	// to return, so the reservation is returned here
+	order, err = r.orderRepo.GetOrderByID(ctx, event.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.Status == domain.StatusCancelled {
+		log.Infof("Use Case: Order %d was cancelled while its stock was reserved, returning reservation %s", order.ID, event.Payload.ReservationID)
+		return r.inventoryClient.CancelReservation(ctx, event.Payload.ReservationID)
+	}
+	return nil
+}
+
+func (r *sagaRelay) release(ctx context.Context, event *domain.OutboxEvent) error {
+	log := requestid.Logger(ctx, r.log)
+	payload := event.Payload
+
+	if payload.ReservationID == "" {
+		return r.inventoryClient.ReleaseStock(ctx, toStockItems(payload.Items), payload.IdempotencyKey)
+	}
+	err := r.inventoryClient.CancelReservation(ctx, payload.ReservationID)
+	if clients.IsRejection(err) && strings.Contains(err.Error(), "not found") {
+		// The reserve step may still be on its way; the release is retried until it has settled
+		reserveEvent, lookupErr := r.outboxRepo.LatestEvent(ctx, event.OrderID, domain.EventStockReserve)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		if reserveEvent != nil && reserveEvent.Status == domain.OutboxPending {
+			return fmt.Errorf("reservation %s of order %d is not made yet: %v", payload.ReservationID, event.OrderID, err)
+		}
+		log.Infof("Use Case: Reservation %s of order %d was never made, nothing to return", payload.ReservationID, event.OrderID)
+		return nil
+	}
+	return err
+}
+
+// fail runs the compensation for a step that will not be retried and marks the event as failed.
+func (r *sagaRelay) fail(ctx context.Context, event *domain.OutboxEvent, cause error) error {
+	log := requestid.Logger(ctx, r.log)
+
+	switch event.Type {
+	case domain.EventStockReserve:
+		if err := r.abortOrder(ctx, event, cause); err != nil {
+			return err
+		}
+	case domain.EventStockRelease:
+		log.Errorf("Use Case: CRITICAL! Could not return stock for order %d (outbox event %d): %v. Manual stock adjustment needed!", event.OrderID, event.ID, cause)
+		metrics.RollbackFailures.WithLabelValues(metrics.OperationCancel).Inc()
+	}
+	return r.outboxRepo.MarkEventFailed(ctx, event.ID, cause.Error())
+}
+
+// abortOrder cancels an order whose stock could not be reserved. When the relay gave up on a
+// transient error the reservation may still have gone through, so its release is queued too.
+func (r *sagaRelay) abortOrder(ctx context.Context, event *domain.OutboxEvent, cause error) error {
+	log := requestid.Logger(ctx, r.log)
+
+	var events []*domain.OutboxEvent
+	reason := metrics.ReasonInventoryError
+	if clients.IsRejection(cause) {
+		if strings.Contains(cause.Error(), "insufficient stock") {
+			reason = metrics.ReasonInsufficientStock
+		}
+	} else {
+		events = append(events, &domain.OutboxEvent{
+			Type:    domain.EventStockRelease,
+			Payload: domain.StockEventPayload{ReservationID: event.Payload.ReservationID},
+		})
+	}
+
+	// The abort is recorded as the saga's doing even when it happens inside the customer's request
+	ctx = domain.ContextWithActor(ctx, domain.ActorSagaRelay)
+	_, err := r.orderRepo.UpdateOrderStatus(ctx, event.OrderID, domain.StatusPending, domain.StatusCancelled,
+		fmt.Sprintf("stock reservation failed: %v", cause), events...)
+	if err != nil && strings.Contains(err.Error(), "status transition not allowed") {
+		// The order was cancelled meanwhile, which already queued the return of its stock
+		log.Warnf("Use Case: Order %d changed status before it could be aborted: %v", event.OrderID, err)
+		return nil
+	}
+	if err != nil {
+		log.Errorf("Use Case: Failed to cancel order %d after its stock reservation failed: %v", event.OrderID, err)
+		return fmt.Errorf("could not cancel order %d after failed reservation: %w", event.OrderID, err)
+	}
+	log.Warnf("Use Case: Order %d cancelled because its stock could not be reserved: %v", event.OrderID, cause)
+	metrics.StockReservationFailures.WithLabelValues(reason).Inc()
+	return nil
+}
+
+// backoff doubles the delay with every attempt, starting at baseBackoff and capped at maxBackoff.
+func (r *sagaRelay) backoff(attempts int) time.Duration {
+	delay := r.baseBackoff
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,419 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"order_service/internal/clients"
+	"order_service/internal/domain"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeOutboxRepo records what the relay does with the event it processes.
+type fakeOutboxRepo struct {
+	domain.OutboxRepository
+	latestReserve *domain.OutboxEvent
+
+	done        []int64
+	failed      map[int64]string
+	retried     map[int64]time.Time
+	retryErrors map[int64]string
+}
+
+func newFakeOutboxRepo() *fakeOutboxRepo {
+	return &fakeOutboxRepo{
+		failed:      map[int64]string{},
+		retried:     map[int64]time.Time{},
+		retryErrors: map[int64]string{},
+	}
+}
+
+func (f *fakeOutboxRepo) MarkEventDone(ctx context.Context, id int64) error {
+	f.done = append(f.done, id)
+	return nil
+}
+
+func (f *fakeOutboxRepo) MarkEventFailed(ctx context.Context, id int64, lastError string) error {
+	f.failed[id] = lastError
+	return nil
+}
+
+func (f *fakeOutboxRepo) ScheduleRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	f.retried[id] = nextAttemptAt
+	f.retryErrors[id] = lastError
+	return nil
+}
+
+func (f *fakeOutboxRepo) LatestEvent(ctx context.Context, orderID int, eventType string) (*domain.OutboxEvent, error) {
+	if eventType == domain.EventStockReserve {
+		return f.latestReserve, nil
+	}
+	return nil, nil
+}
+
+// fakeOrderRepo serves the order in the given statuses, one per read, repeating the last one.
+type fakeOrderRepo struct {
+	domain.OrderRepository
+	statuses  []domain.OrderStatus
+	reads     int
+	updateErr error
+
+	updates []statusUpdate
+}
+
+type statusUpdate struct {
+	from, to domain.OrderStatus
+	actor    string
+	events   []*domain.OutboxEvent
+}
+
+func (f *fakeOrderRepo) GetOrderByID(ctx context.Context, id int) (*domain.Order, error) {
+	status := f.statuses[len(f.statuses)-1]
+	if f.reads < len(f.statuses) {
+		status = f.statuses[f.reads]
+	}
+	f.reads++
+	return &domain.Order{ID: id, Status: status}, nil
+}
+
+func (f *fakeOrderRepo) UpdateOrderStatus(ctx context.Context, id int, from, to domain.OrderStatus, reason string, events ...*domain.OutboxEvent) (*domain.Order, error) {
+	if f.updateErr != nil {
+		return nil, f.updateErr
+	}
+	f.updates = append(f.updates, statusUpdate{from: from, to: to, actor: domain.ActorFromContext(ctx), events: events})
+	return &domain.Order{ID: id, Status: to}, nil
+}
+
+// fakeInventoryClient answers every stock call with the configured error and records the calls.
+type fakeInventoryClient struct {
+	clients.InventoryClient
+	reserveErr error
+	cancelErr  error
+	releaseErr error
+
+	calls []string
+}
+
+func (f *fakeInventoryClient) ReserveStock(ctx context.Context, reservationID string, items []clients.StockItem) error {
+	f.calls = append(f.calls, "reserve "+reservationID)
+	return f.reserveErr
+}
+
+func (f *fakeInventoryClient) CancelReservation(ctx context.Context, reservationID string) error {
+	f.calls = append(f.calls, "cancel "+reservationID)
+	return f.cancelErr
+}
+
+func (f *fakeInventoryClient) ReleaseStock(ctx context.Context, items []clients.StockItem, idempotencyKey string) error {
+	f.calls = append(f.calls, "release "+idempotencyKey)
+	return f.releaseErr
+}
+
+func TestSagaRelayProcess(t *testing.T) {
+	const reservationID = "6f1c2a8e-8a0c-4d7b-9a55-0e0f3c1d2b4a"
+	items := []domain.OrderItem{{ProductID: 7, Quantity: 2}}
+	reserveEvent := func(attempts int) *domain.OutboxEvent {
+		return &domain.OutboxEvent{ID: 1, OrderID: 42, Type: domain.EventStockReserve, Attempts: attempts,
+			Payload: domain.StockEventPayload{ReservationID: reservationID, Items: items}}
+	}
+	releaseEvent := func(attempts int) *domain.OutboxEvent {
+		return &domain.OutboxEvent{ID: 2, OrderID: 42, Type: domain.EventStockRelease, Attempts: attempts,
+			Payload: domain.StockEventPayload{ReservationID: reservationID}}
+	}
+	notFound := &clients.RejectionError{Message: "reservation with id " + reservationID + " not found"}
+
+	tests := []struct {
+		name          string
+		event         *domain.OutboxEvent
+		statuses      []domain.OrderStatus
+		latestReserve *domain.OutboxEvent
+		updateErr     error
+		reserveErr    error
+		cancelErr     error
+		releaseErr    error
+
+		wantCalls     []string
+		wantErr       bool
+		wantRejection bool
+		wantDone      bool
+		wantRetry     bool
+		wantFailed    string // Prefix of the recorded error; "" if the event must not fail
+		wantUpdates   []statusUpdate
+	}{
+		{
+			name:      "reserve",
+			event:     reserveEvent(1),
+			statuses:  []domain.OrderStatus{domain.StatusPending},
+			wantCalls: []string{"reserve " + reservationID},
+			wantDone:  true,
+		},
+		{
+			name:      "reserve for order cancelled before the step",
+			event:     reserveEvent(1),
+			statuses:  []domain.OrderStatus{domain.StatusCancelled},
+			wantCalls: nil,
+			wantDone:  true,
+		},
+		{
+			name:      "reserve for order cancelled while reserving returns the reservation",
+			event:     reserveEvent(1),
+			statuses:  []domain.OrderStatus{domain.StatusPending, domain.StatusCancelled},
+			wantCalls: []string{"reserve " + reservationID, "cancel " + reservationID},
+			wantDone:  true,
+		},
+		{
+			name:      "reserve for order cancelled while reserving retries a failed return",
+			event:     reserveEvent(1),
+			statuses:  []domain.OrderStatus{domain.StatusPending, domain.StatusCancelled},
+			cancelErr: errors.New("inventory unavailable"),
+			wantCalls: []string{"reserve " + reservationID, "cancel " + reservationID},
+			wantErr:   true,
+			wantRetry: true,
+		},
+		{
+			name:          "rejected reserve aborts the order",
+			event:         reserveEvent(1),
+			statuses:      []domain.OrderStatus{domain.StatusPending},
+			reserveErr:    &clients.RejectionError{Message: "insufficient stock for product 7"},
+			wantCalls:     []string{"reserve " + reservationID},
+			wantErr:       true,
+			wantRejection: true,
+			wantFailed:    "insufficient stock",
+			wantUpdates: []statusUpdate{
+				{from: domain.StatusPending, to: domain.StatusCancelled, actor: domain.ActorSagaRelay},
+			},
+		},
+		{
+			name:          "rejected reserve of an order cancelled meanwhile",
+			event:         reserveEvent(1),
+			statuses:      []domain.OrderStatus{domain.StatusPending},
+			updateErr:     errors.New("status transition not allowed: cancelled -> cancelled"),
+			reserveErr:    &clients.RejectionError{Message: "insufficient stock for product 7"},
+			wantCalls:     []string{"reserve " + reservationID},
+			wantErr:       true,
+			wantRejection: true,
+			wantFailed:    "insufficient stock",
+		},
+		{
+			name:       "transient reserve failure is retried",
+			event:      reserveEvent(1),
+			statuses:   []domain.OrderStatus{domain.StatusPending},
+			reserveErr: errors.New("inventory unavailable"),
+			wantCalls:  []string{"reserve " + reservationID},
+			wantErr:    true,
+			wantRetry:  true,
+		},
+		{
+			name:       "giving up on reserve aborts the order and queues the release",
+			event:      reserveEvent(5),
+			statuses:   []domain.OrderStatus{domain.StatusPending},
+			reserveErr: errors.New("inventory unavailable"),
+			wantCalls:  []string{"reserve " + reservationID},
+			wantErr:    true,
+			wantFailed: giveUpPrefix + " 5 attempts",
+			wantUpdates: []statusUpdate{{
+				from: domain.StatusPending, to: domain.StatusCancelled, actor: domain.ActorSagaRelay,
+				events: []*domain.OutboxEvent{{Type: domain.EventStockRelease, Payload: domain.StockEventPayload{ReservationID: reservationID}}},
+			}},
+		},
+		{
+			name:      "release cancels the reservation",
+			event:     releaseEvent(1),
+			wantCalls: []string{"cancel " + reservationID},
+			wantDone:  true,
+		},
+		{
+			name:          "release of a reservation that was never made",
+			event:         releaseEvent(1),
+			latestReserve: &domain.OutboxEvent{ID: 1, Status: domain.OutboxFailed},
+			cancelErr:     notFound,
+			wantCalls:     []string{"cancel " + reservationID},
+			wantDone:      true,
+		},
+		{
+			name:      "release of a reservation that was never queued",
+			event:     releaseEvent(1),
+			cancelErr: notFound,
+			wantCalls: []string{"cancel " + reservationID},
+			wantDone:  true,
+		},
+		{
+			name:          "release waits for a reserve step still in flight",
+			event:         releaseEvent(1),
+			latestReserve: &domain.OutboxEvent{ID: 1, Status: domain.OutboxPending},
+			cancelErr:     notFound,
+			wantCalls:     []string{"cancel " + reservationID},
+			wantErr:       true,
+			wantRetry:     true,
+		},
+		{
+			name: "release of an order placed without a reservation",
+			event: &domain.OutboxEvent{ID: 3, OrderID: 42, Type: domain.EventStockRelease, Attempts: 1,
+				Payload: domain.StockEventPayload{IdempotencyKey: "order-42-cancel", Items: items}},
+			wantCalls: []string{"release order-42-cancel"},
+			wantDone:  true,
+		},
+		{
+			name:          "rejected release fails for manual follow-up",
+			event:         releaseEvent(1),
+			cancelErr:     &clients.RejectionError{Message: "cannot cancel reservation: it is fulfilled"},
+			wantCalls:     []string{"cancel " + reservationID},
+			wantErr:       true,
+			wantRejection: true,
+			wantFailed:    "cannot cancel reservation",
+		},
+		{
+			name:          "unknown event type",
+			event:         &domain.OutboxEvent{ID: 4, OrderID: 42, Type: "stock.teleport", Attempts: 1},
+			wantErr:       true,
+			wantRejection: true,
+			wantFailed:    "unknown outbox event type",
+		},
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := newFakeOutboxRepo()
+			outbox.latestReserve = tt.latestReserve
+			orders := &fakeOrderRepo{statuses: tt.statuses, updateErr: tt.updateErr}
+			if len(orders.statuses) == 0 {
+				orders.statuses = []domain.OrderStatus{domain.StatusCancelled}
+			}
+			inventory := &fakeInventoryClient{reserveErr: tt.reserveErr, cancelErr: tt.cancelErr, releaseErr: tt.releaseErr}
+			relay := NewSagaRelay(outbox, orders, inventory, 10, 5, time.Second, time.Minute, logger).(*sagaRelay)
+
+			err := relay.process(context.Background(), tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("process() error = %v, want error: %t", err, tt.wantErr)
+			}
+			if got := clients.IsRejection(err); got != tt.wantRejection {
+				t.Errorf("process() rejection = %t, want %t (error: %v)", got, tt.wantRejection, err)
+			}
+			if strings.Join(inventory.calls, ", ") != strings.Join(tt.wantCalls, ", ") {
+				t.Errorf("inventory calls = %q, want %q", inventory.calls, tt.wantCalls)
+			}
+
+			if done := len(outbox.done) == 1 && outbox.done[0] == tt.event.ID; done != tt.wantDone {
+				t.Errorf("event marked done = %t, want %t", done, tt.wantDone)
+			}
+			if _, retried := outbox.retried[tt.event.ID]; retried != tt.wantRetry {
+				t.Errorf("event scheduled for retry = %t, want %t", retried, tt.wantRetry)
+			}
+			lastError, failed := outbox.failed[tt.event.ID]
+			if failed != (tt.wantFailed != "") {
+				t.Errorf("event marked failed = %t (%q), want %t", failed, lastError, tt.wantFailed != "")
+			} else if failed && !strings.HasPrefix(lastError, tt.wantFailed) {
+				t.Errorf("event failed with %q, want prefix %q", lastError, tt.wantFailed)
+			}
+
+			if len(orders.updates) != len(tt.wantUpdates) {
+				t.Fatalf("order status updates = %+v, want %+v", orders.updates, tt.wantUpdates)
+			}
+			for i, want := range tt.wantUpdates {
+				got := orders.updates[i]
+				if got.from != want.from || got.to != want.to || got.actor != want.actor {
+					t.Errorf("update %d = %s -> %s by %s, want %s -> %s by %s", i, got.from, got.to, got.actor, want.from, want.to, want.actor)
+				}
+				if len(got.events) != len(want.events) {
+					t.Fatalf("update %d queued %d events, want %d", i, len(got.events), len(want.events))
+				}
+				for j := range want.events {
+					if got.events[j].Type != want.events[j].Type || got.events[j].Payload.ReservationID != want.events[j].Payload.ReservationID {
+						t.Errorf("update %d event %d = %s of %q, want %s of %q", i, j,
+							got.events[j].Type, got.events[j].Payload.ReservationID, want.events[j].Type, want.events[j].Payload.ReservationID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSagaRelayProcessSchedulesRetryWithBackoff(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	outbox := newFakeOutboxRepo()
+	inventory := &fakeInventoryClient{releaseErr: errors.New("inventory unavailable")}
+	relay := NewSagaRelay(outbox, &fakeOrderRepo{}, inventory, 10, 5, time.Second, time.Minute, logger).(*sagaRelay)
+
+	event := &domain.OutboxEvent{ID: 9, OrderID: 42, Type: domain.EventStockRelease, Attempts: 3,
+		Payload: domain.StockEventPayload{IdempotencyKey: "order-42-cancel"}}
+	before := time.Now()
+	if err := relay.process(context.Background(), event); err == nil {
+		t.Fatal("process() succeeded, want the transient error")
+	}
+
+	next, ok := outbox.retried[event.ID]
+	if !ok {
+		t.Fatal("event not scheduled for retry")
+	}
+	// The third attempt waits base * 2^2
+	if delay := next.Sub(before); delay < 4*time.Second || delay > 5*time.Second {
+		t.Errorf("retry scheduled %s after the attempt, want about 4s", delay)
+	}
+	if outbox.retryErrors[event.ID] != "inventory unavailable" {
+		t.Errorf("retry recorded error %q, want %q", outbox.retryErrors[event.ID], "inventory unavailable")
+	}
+}
+
+func TestSagaRelayBackoff(t *testing.T) {
+	relay := &sagaRelay{baseBackoff: time.Second, maxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 8 * time.Second},
+		{attempts: 5, want: 10 * time.Second},
+		{attempts: 50, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := relay.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestSagaRelayReservationRejection(t *testing.T) {
+	tests := []struct {
+		name   string
+		latest *domain.OutboxEvent
+		want   string // "" for no rejection
+	}{
+		{name: "no reserve step", latest: nil},
+		{name: "still pending", latest: &domain.OutboxEvent{Status: domain.OutboxPending}},
+		{name: "reserved", latest: &domain.OutboxEvent{Status: domain.OutboxDone}},
+		{name: "gave up", latest: &domain.OutboxEvent{Status: domain.OutboxFailed, LastError: giveUpPrefix + " 5 attempts: timeout"}},
+		{name: "rejected", latest: &domain.OutboxEvent{Status: domain.OutboxFailed, LastError: "insufficient stock for product 7"}, want: "insufficient stock for product 7"},
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := newFakeOutboxRepo()
+			outbox.latestReserve = tt.latest
+			relay := NewSagaRelay(outbox, &fakeOrderRepo{}, &fakeInventoryClient{}, 10, 5, time.Second, time.Minute, logger)
+
+			rejection, err := relay.ReservationRejection(context.Background(), 42)
+			if err != nil {
+				t.Fatalf("ReservationRejection() returned error: %v", err)
+			}
+			got := ""
+			if rejection != nil {
+				got = rejection.Message
+			}
+			if got != tt.want {
+				t.Errorf("ReservationRejection() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
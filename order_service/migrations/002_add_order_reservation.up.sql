@@ -0,0 +1,3 @@
+-- Stock reservation in inventory_service that holds the items of the order.
+-- NULL for orders created before reservations were introduced.
+ALTER TABLE orders ADD COLUMN reservation_id UUID;
@@ -0,0 +1,18 @@
+-- Transactional outbox for the order saga: inventory calls are written in the same
+-- transaction as the order change and delivered by a relay worker with retries.
+CREATE TABLE outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'done', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ
+);
+
+-- Index for the relay worker picking up due events
+CREATE INDEX idx_outbox_events_due ON outbox_events(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_outbox_events_order_id ON outbox_events(order_id);
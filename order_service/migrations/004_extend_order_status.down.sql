@@ -0,0 +1,11 @@
+-- Postgres cannot drop enum values, so the type is rebuilt. Orders in the new states are
+-- folded into the closest old one: refunded -> cancelled, anything past payment -> completed.
+UPDATE orders SET status = 'cancelled' WHERE status = 'refunded';
+UPDATE orders SET status = 'completed' WHERE status IN ('paid', 'processing', 'shipped', 'delivered');
+
+ALTER TYPE order_status RENAME TO order_status_old;
+CREATE TYPE order_status AS ENUM ('pending', 'completed', 'cancelled');
+ALTER TABLE orders ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE orders ALTER COLUMN status TYPE order_status USING status::text::order_status;
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'pending';
+DROP TYPE order_status_old;
@@ -0,0 +1,6 @@
+-- Fulfilment and refund states of the order lifecycle (see domain.TransitionEffects).
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'paid' AFTER 'pending';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'processing' AFTER 'paid';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'shipped' AFTER 'processing';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'delivered' AFTER 'shipped';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'refunded' AFTER 'cancelled';
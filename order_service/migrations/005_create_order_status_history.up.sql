@@ -0,0 +1,21 @@
+-- Every status change of an order, including the initial one (from_status NULL),
+-- with who made it and why. Rows are only ever inserted.
+CREATE TABLE order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status order_status,
+    to_status order_status NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history(order_id, id);
+
+-- Orders placed before the history existed: their creation, and their last change if they moved on
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, changed_at)
+SELECT id, NULL, 'pending', 'unknown', '', created_at FROM orders;
+
+INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, changed_at)
+SELECT id, 'pending', status, 'unknown', 'recorded before status history was kept', updated_at
+FROM orders WHERE status <> 'pending';
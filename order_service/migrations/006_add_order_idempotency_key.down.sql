@@ -0,0 +1,3 @@
+DROP INDEX idx_orders_user_idempotency_key;
+ALTER TABLE orders DROP COLUMN idempotency_fingerprint;
+ALTER TABLE orders DROP COLUMN idempotency_key;
@@ -0,0 +1,7 @@
+-- Client-supplied Idempotency-Key of the request that created the order, with a fingerprint
+-- of its items so that a replay with a different body can be told apart from a retry.
+ALTER TABLE orders ADD COLUMN idempotency_key TEXT;
+ALTER TABLE orders ADD COLUMN idempotency_fingerprint TEXT;
+
+CREATE UNIQUE INDEX idx_orders_user_idempotency_key ON orders(user_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
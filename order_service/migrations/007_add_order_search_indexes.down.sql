@@ -0,0 +1,5 @@
+DROP INDEX idx_order_items_product_id;
+DROP INDEX idx_orders_total;
+DROP INDEX idx_orders_status_created_at;
+DROP INDEX idx_orders_created_at;
+ALTER TABLE orders DROP COLUMN total;
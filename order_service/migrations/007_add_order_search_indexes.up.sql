@@ -0,0 +1,18 @@
+-- Order totals never change once the order is placed; storing them lets SearchOrders
+-- filter and sort by total without aggregating order_items for every candidate row.
+ALTER TABLE orders ADD COLUMN total NUMERIC(12, 2) NOT NULL DEFAULT 0;
+
+-- Backfill without touching updated_at
+ALTER TABLE orders DISABLE TRIGGER set_orders_timestamp;
+UPDATE orders o
+SET total = t.total
+FROM (SELECT order_id, SUM(quantity * price) AS total FROM order_items GROUP BY order_id) t
+WHERE t.order_id = o.id;
+ALTER TABLE orders ENABLE TRIGGER set_orders_timestamp;
+
+-- Keyset pagination of SearchOrders: (sort column, id)
+CREATE INDEX idx_orders_created_at ON orders(created_at, id);
+CREATE INDEX idx_orders_status_created_at ON orders(status, created_at, id);
+CREATE INDEX idx_orders_total ON orders(total, id);
+-- "Orders containing product X"
+CREATE INDEX idx_order_items_product_id ON order_items(product_id);
@@ -0,0 +1,2 @@
+CREATE INDEX idx_orders_user_id ON orders(user_id);
+DROP INDEX idx_orders_user_created_at;
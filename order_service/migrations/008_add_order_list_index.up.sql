@@ -0,0 +1,3 @@
+-- Keyset pagination of a user's orders, newest first: (created_at, id) < page token
+CREATE INDEX idx_orders_user_created_at ON orders(user_id, created_at, id);
+DROP INDEX idx_orders_user_id;
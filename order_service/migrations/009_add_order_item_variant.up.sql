@@ -0,0 +1,6 @@
+-- The variant (size, colour, ...) an item was ordered in. variant_id refers to inventory_service's
+-- product_variants and is NULL for products without variants; sku is copied when the order is
+-- placed so that it survives the variant being renamed or deleted.
+ALTER TABLE order_items
+    ADD COLUMN variant_id INT,
+    ADD COLUMN sku TEXT NOT NULL DEFAULT '';
@@ -9,15 +9,24 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	"user_service/internal/auth"
 	"user_service/internal/config"
 	grpcHandler "user_service/internal/delivery/grpc"
+	"user_service/internal/domain"
+	"user_service/internal/healthcheck"
+	"user_service/internal/metrics"
 	"user_service/internal/repository"
+	"user_service/internal/requestid"
+	"user_service/internal/tracing"
 	"user_service/internal/usecase"
 	userpb "user_service/proto"
 
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -35,6 +44,18 @@ func main() {
 	}
 	logger.Infof("Starting User Service...")
 
+	shutdownTracing, err := tracing.Init(context.Background(), "user_service", cfg.TracingExporter, cfg.TracingOTLPEndpoint, cfg.TracingSampleRatio, logger)
+	if err != nil {
+		logger.Fatalf("FATAL: Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorf("Error shutting down tracing: %v", err)
+		}
+	}()
+
 	db, err := connectDB(cfg.DatabaseURL, logger)
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
@@ -48,7 +69,9 @@ func main() {
 	}()
 
 	userRepo := repository.NewPostgresUserRepository(db, logger)
-	userUseCase := usecase.NewUserUseCase(userRepo, logger)
+	sessionRepo := repository.NewPostgresSessionRepository(db, logger)
+	jwtManager := auth.NewJWTManager(cfg.JwtSecret, cfg.JwtIssuer, cfg.AccessTokenTTL)
+	userUseCase := usecase.NewUserUseCase(userRepo, sessionRepo, jwtManager, cfg.RefreshTokenTTL, logger)
 	userGrpcHandler := grpcHandler.NewUserHandler(userUseCase, logger)
 
 	lis, err := net.Listen("tcp", cfg.GrpcPort)
@@ -57,13 +80,31 @@ func main() {
 	}
 	logger.Infof("gRPC server listening on %s", cfg.GrpcPort)
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			requestid.UnaryServerInterceptor(logger),
+			metrics.UnaryServerInterceptor(),
+		),
+	)
 
 	userpb.RegisterUserServiceServer(grpcServer, userGrpcHandler)
 
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	healthMonitor := healthcheck.NewMonitor(healthServer, []string{userpb.UserService_ServiceDesc.ServiceName}, []healthcheck.Check{
+		{Name: "postgres", Probe: db.PingContext},
+	}, cfg.HealthCheckInterval, logger)
+	healthCtx, stopHealth := context.WithCancel(context.Background())
+	defer stopHealth()
+	go healthMonitor.Run(healthCtx)
+	logger.Info("gRPC health service registered")
+
 	reflection.Register(grpcServer)
 	logger.Info("gRPC reflection service registered")
 
+	metricsServer := metrics.Serve(cfg.MetricsPort, logger)
+
 	go func() {
 		logger.Info("Starting gRPC server...")
 		if err := grpcServer.Serve(lis); err != nil && err != grpc.ErrServerStopped {
@@ -72,6 +113,10 @@ func main() {
 		logger.Info("gRPC server stopped serving.")
 	}()
 
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	go runSessionCleanup(cleanupCtx, userUseCase, cfg.SessionCleanupInterval, logger)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	logger.Info("Signal listener started.")
@@ -79,12 +124,41 @@ func main() {
 	<-quit
 	logger.Warn("Shutdown signal received...")
 
+	stopCleanup()
+
+	stopHealth()
+	healthServer.Shutdown()
+
 	logger.Info("Attempting graceful shutdown of gRPC server...")
 	grpcServer.GracefulStop()
 	logger.Info("gRPC server gracefully stopped.")
+
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelMetrics()
+	if err := metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.Errorf("Error shutting down metrics server: %v", err)
+	}
 	logger.Info("User Service shut down gracefully.")
 }
 
+func runSessionCleanup(ctx context.Context, uc domain.UserUseCase, interval time.Duration, logger *logrus.Logger) {
+	logger.Infof("Session cleanup started (interval: %s)", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Session cleanup stopped.")
+			return
+		case <-ticker.C:
+			if _, err := uc.CleanupExpiredSessions(ctx); err != nil {
+				logger.Errorf("Session cleanup failed: %v", err)
+			}
+		}
+	}
+}
+
 func setupLogger(level string) *logrus.Logger {
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.TextFormatter{
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTManager issues and parses HS256 access tokens signed with the secret shared with the API gateway.
+type JWTManager struct {
+	secret []byte
+	issuer string
+	ttl    time.Duration
+}
+
+// Claims are the access token claims. Roles are snapshotted at issue time and
+// picked up again on the next refresh.
+type Claims struct {
+	Roles []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func NewJWTManager(secret, issuer string, ttl time.Duration) *JWTManager {
+	return &JWTManager{
+		secret: []byte(secret),
+		issuer: issuer,
+		ttl:    ttl,
+	}
+}
+
+// Generate returns a signed token for the user together with its expiry time.
+// The session ID is stored in the jti claim so the token can be revoked server-side.
+func (m *JWTManager) Generate(userID int64, sessionID string, roles []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	claims := Claims{
+		Roles: roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("could not sign token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse verifies the signature, issuer and expiry of the token and returns its claims.
+func (m *JWTManager) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid token: missing subject")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("invalid token: missing session id")
+	}
+	return claims, nil
+}
+
+// UserIDFromClaims extracts the numeric user ID stored in the subject claim.
+func UserIDFromClaims(claims *Claims) (int64, error) {
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, fmt.Errorf("invalid token: bad subject %q", claims.Subject)
+	}
+	return userID, nil
+}
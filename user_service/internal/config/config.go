@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -14,6 +15,19 @@ type Config struct {
 	DatabaseURL string `envconfig:"DATABASE_URL" required:"true"`
 	GrpcPort    string `envconfig:"GRPC_PORT" default:":50053"`
 	LogLevel    string `envconfig:"LOG_LEVEL" default:"info"`
+	MetricsPort string `envconfig:"METRICS_PORT" default:":9093"` // Prometheus /metrics listener
+
+	JwtSecret       string        `envconfig:"JWT_SECRET"        required:"true"`
+	JwtIssuer       string        `envconfig:"JWT_ISSUER"        default:"user_service"`
+	AccessTokenTTL  time.Duration `envconfig:"ACCESS_TOKEN_TTL"  default:"15m"`
+	RefreshTokenTTL time.Duration `envconfig:"REFRESH_TOKEN_TTL" default:"720h"`
+
+	SessionCleanupInterval time.Duration `envconfig:"SESSION_CLEANUP_INTERVAL" default:"10m"`
+	HealthCheckInterval    time.Duration `envconfig:"HEALTH_CHECK_INTERVAL"    default:"5s"`
+
+	TracingExporter     string  `envconfig:"TRACING_EXPORTER"      default:"none"` // none, stdout or otlp
+	TracingOTLPEndpoint string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4317"`
+	TracingSampleRatio  float64 `envconfig:"TRACING_SAMPLE_RATIO"  default:"1"`
 }
 
 var (
@@ -42,6 +56,10 @@ func LoadConfig(logger *logrus.Logger) *Config {
 		} else {
 			logger.Fatal("Configuration error: DATABASE_URL is not set")
 		}
+		if config.JwtSecret == "" {
+			logger.Fatal("Configuration error: JWT_SECRET is not set")
+		}
+		logger.Infof("Configuration loaded: JwtIssuer=%s, AccessTokenTTL=%s, RefreshTokenTTL=%s", config.JwtIssuer, config.AccessTokenTTL, config.RefreshTokenTTL)
 
 	})
 	return &config
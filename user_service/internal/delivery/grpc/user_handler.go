@@ -8,7 +8,10 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type UserHandler struct {
@@ -32,7 +35,7 @@ func (h *UserHandler) RegisterUser(ctx context.Context, req *userpb.RegisterUser
 		return nil, status.Error(codes.InvalidArgument, "Name, email, and password are required")
 	}
 
-	createdUser, err := h.useCase.RegisterUser(req.GetName(), req.GetEmail(), req.GetPassword())
+	createdUser, err := h.useCase.RegisterUser(ctx, req.GetName(), req.GetEmail(), req.GetPassword())
 	if err != nil {
 		h.log.Errorf("gRPC Handler: RegisterUser use case failed: %v", err)
 
@@ -47,11 +50,7 @@ func (h *UserHandler) RegisterUser(ctx context.Context, req *userpb.RegisterUser
 		return nil, status.Errorf(codes.Internal, "User registration failed: %v", err)
 	}
 
-	response := &userpb.User{
-		Id:    createdUser.ID,
-		Name:  createdUser.Name,
-		Email: createdUser.Email,
-	}
+	response := toUserResponse(createdUser)
 
 	h.log.Infof("gRPC Handler: RegisterUser successful for ID: %d", response.Id)
 	return response, nil
@@ -65,7 +64,7 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *userpb.Authenti
 		return nil, status.Error(codes.InvalidArgument, "Email and password are required")
 	}
 
-	authResult, err := h.useCase.AuthenticateUser(req.GetEmail(), req.GetPassword())
+	authResult, err := h.useCase.AuthenticateUser(ctx, req.GetEmail(), req.GetPassword())
 	if err != nil {
 
 		h.log.Errorf("gRPC Handler: AuthenticateUser use case internal error: %v", err)
@@ -82,17 +81,37 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *userpb.Authenti
 
 	}
 
-	response := &userpb.AuthenticateUserResponse{
-		Authenticated: true,
-		Token:         authResult.Token,
-		UserId:        authResult.UserID,
-		ErrorMessage:  "",
-	}
+	response := toAuthenticateUserResponse(authResult)
 
 	h.log.Infof("gRPC Handler: AuthenticateUser successful for User ID: %d", response.UserId)
 	return response, nil
 }
 
+func (h *UserHandler) RefreshToken(ctx context.Context, req *userpb.RefreshTokenRequest) (*userpb.AuthenticateUserResponse, error) {
+	h.log.Info("gRPC Handler: Received RefreshToken request")
+
+	if req.GetRefreshToken() == "" {
+		h.log.Warn("gRPC Handler: RefreshToken validation failed - missing refresh token")
+		return nil, status.Error(codes.InvalidArgument, "Refresh token is required")
+	}
+
+	authResult, err := h.useCase.RefreshToken(ctx, req.GetRefreshToken())
+	if err != nil {
+		h.log.Errorf("gRPC Handler: RefreshToken use case internal error: %v", err)
+		return nil, status.Errorf(codes.Internal, "Token refresh failed due to an internal error: %v", err)
+	}
+
+	if !authResult.Authenticated {
+		h.log.Warnf("gRPC Handler: RefreshToken rejected: %s", authResult.ErrorMessage)
+		return nil, status.Error(codes.Unauthenticated, authResult.ErrorMessage)
+	}
+
+	response := toAuthenticateUserResponse(authResult)
+
+	h.log.Infof("gRPC Handler: RefreshToken successful for User ID: %d", response.UserId)
+	return response, nil
+}
+
 func (h *UserHandler) GetUserProfile(ctx context.Context, req *userpb.GetUserProfileRequest) (*userpb.UserProfile, error) {
 	userID := req.GetUserId()
 	h.log.Infof("gRPC Handler: Received GetUserProfile request for User ID: %d", userID)
@@ -102,7 +121,7 @@ func (h *UserHandler) GetUserProfile(ctx context.Context, req *userpb.GetUserPro
 		return nil, status.Error(codes.InvalidArgument, "Valid User ID is required")
 	}
 
-	profile, err := h.useCase.GetUserProfile(userID)
+	profile, err := h.useCase.GetUserProfile(ctx, userID)
 	if err != nil {
 		h.log.Warnf("gRPC Handler: GetUserProfile use case failed for User ID %d: %v", userID, err)
 
@@ -122,3 +141,202 @@ func (h *UserHandler) GetUserProfile(ctx context.Context, req *userpb.GetUserPro
 	h.log.Infof("gRPC Handler: GetUserProfile successful for User ID: %d", response.Id)
 	return response, nil
 }
+
+func (h *UserHandler) ValidateToken(ctx context.Context, req *userpb.ValidateTokenRequest) (*userpb.ValidateTokenResponse, error) {
+	h.log.Debug("gRPC Handler: Received ValidateToken request")
+
+	if req.GetToken() == "" {
+		h.log.Warn("gRPC Handler: ValidateToken validation failed - missing token")
+		return nil, status.Error(codes.InvalidArgument, "Token is required")
+	}
+
+	result, err := h.useCase.ValidateToken(ctx, req.GetToken())
+	if err != nil {
+		h.log.Errorf("gRPC Handler: ValidateToken use case internal error: %v", err)
+		return nil, status.Errorf(codes.Internal, "Token validation failed due to an internal error: %v", err)
+	}
+
+	if !result.Valid {
+		return &userpb.ValidateTokenResponse{
+			Valid:        false,
+			ErrorMessage: result.ErrorMessage,
+		}, nil
+	}
+
+	return &userpb.ValidateTokenResponse{
+		Valid:     true,
+		UserId:    result.UserID,
+		SessionId: result.SessionID,
+		ExpiresAt: timestamppb.New(result.ExpiresAt),
+		Roles:     result.Roles,
+	}, nil
+}
+
+func (h *UserHandler) Logout(ctx context.Context, req *userpb.LogoutRequest) (*emptypb.Empty, error) {
+	h.log.Info("gRPC Handler: Received Logout request")
+
+	if req.GetToken() == "" {
+		h.log.Warn("gRPC Handler: Logout validation failed - missing token")
+		return nil, status.Error(codes.InvalidArgument, "Token is required")
+	}
+
+	if err := h.useCase.Logout(ctx, req.GetToken()); err != nil {
+		h.log.Warnf("gRPC Handler: Logout use case failed: %v", err)
+		return nil, mapSessionErrorToGrpcStatus(err)
+	}
+
+	h.log.Info("gRPC Handler: Logout successful")
+	return &emptypb.Empty{}, nil
+}
+
+func (h *UserHandler) ListSessions(ctx context.Context, req *userpb.ListSessionsRequest) (*userpb.ListSessionsResponse, error) {
+	userID := req.GetUserId()
+	h.log.Infof("gRPC Handler: Received ListSessions request for User ID: %d", userID)
+
+	if userID <= 0 {
+		h.log.Warn("gRPC Handler: ListSessions validation failed - invalid user ID")
+		return nil, status.Error(codes.InvalidArgument, "Valid User ID is required")
+	}
+
+	sessions, err := h.useCase.ListSessions(ctx, userID)
+	if err != nil {
+		h.log.Errorf("gRPC Handler: ListSessions use case failed for User ID %d: %v", userID, err)
+		return nil, mapSessionErrorToGrpcStatus(err)
+	}
+
+	response := &userpb.ListSessionsResponse{
+		Sessions: make([]*userpb.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, &userpb.Session{
+			Id:        session.ID,
+			UserId:    session.UserID,
+			CreatedAt: timestamppb.New(session.CreatedAt),
+			ExpiresAt: timestamppb.New(session.ExpiresAt),
+		})
+	}
+
+	h.log.Infof("gRPC Handler: ListSessions returned %d sessions for User ID: %d", len(response.Sessions), userID)
+	return response, nil
+}
+
+func (h *UserHandler) RevokeSession(ctx context.Context, req *userpb.RevokeSessionRequest) (*emptypb.Empty, error) {
+	userID := req.GetUserId()
+	h.log.Infof("gRPC Handler: Received RevokeSession request for User ID: %d, Session: %s", userID, req.GetSessionId())
+
+	if userID <= 0 || req.GetSessionId() == "" {
+		h.log.Warn("gRPC Handler: RevokeSession validation failed - missing fields")
+		return nil, status.Error(codes.InvalidArgument, "Valid User ID and session ID are required")
+	}
+
+	if err := h.useCase.RevokeSession(ctx, userID, req.GetSessionId()); err != nil {
+		h.log.Warnf("gRPC Handler: RevokeSession use case failed for User ID %d: %v", userID, err)
+		return nil, mapSessionErrorToGrpcStatus(err)
+	}
+
+	h.log.Infof("gRPC Handler: Session %s revoked for User ID: %d", req.GetSessionId(), userID)
+	return &emptypb.Empty{}, nil
+}
+
+func (h *UserHandler) GrantRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error) {
+	h.log.Infof("gRPC Handler: Received GrantRole request for User ID: %d, Role: %s", req.GetUserId(), req.GetRole())
+
+	actorID, err := h.callerIDFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.useCase.GrantRole(ctx, actorID, req.GetUserId(), req.GetRole())
+	if err != nil {
+		h.log.Warnf("gRPC Handler: GrantRole use case failed for User ID %d: %v", req.GetUserId(), err)
+		return nil, mapRoleErrorToGrpcStatus(err)
+	}
+
+	return toUserResponse(user), nil
+}
+
+func (h *UserHandler) RevokeRole(ctx context.Context, req *userpb.UpdateUserRoleRequest) (*userpb.User, error) {
+	h.log.Infof("gRPC Handler: Received RevokeRole request for User ID: %d, Role: %s", req.GetUserId(), req.GetRole())
+
+	actorID, err := h.callerIDFromMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := h.useCase.RevokeRole(ctx, actorID, req.GetUserId(), req.GetRole())
+	if err != nil {
+		h.log.Warnf("gRPC Handler: RevokeRole use case failed for User ID %d: %v", req.GetUserId(), err)
+		return nil, mapRoleErrorToGrpcStatus(err)
+	}
+
+	return toUserResponse(user), nil
+}
+
+// callerIDFromMetadata resolves the calling user from the x-auth-token metadata set by the API gateway.
+func (h *UserHandler) callerIDFromMetadata(ctx context.Context) (int64, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("x-auth-token")) == 0 || md.Get("x-auth-token")[0] == "" {
+		h.log.Warn("gRPC Handler: Missing x-auth-token metadata")
+		return 0, status.Error(codes.Unauthenticated, "Authentication token is required")
+	}
+
+	result, err := h.useCase.ValidateToken(ctx, md.Get("x-auth-token")[0])
+	if err != nil {
+		h.log.Errorf("gRPC Handler: Caller token validation internal error: %v", err)
+		return 0, status.Errorf(codes.Internal, "Token validation failed due to an internal error: %v", err)
+	}
+	if !result.Valid {
+		h.log.Warnf("gRPC Handler: Caller token rejected: %s", result.ErrorMessage)
+		return 0, status.Error(codes.Unauthenticated, result.ErrorMessage)
+	}
+	return result.UserID, nil
+}
+
+func toUserResponse(user *domain.User) *userpb.User {
+	return &userpb.User{
+		Id:    user.ID,
+		Name:  user.Name,
+		Email: user.Email,
+		Roles: user.Roles,
+	}
+}
+
+func toAuthenticateUserResponse(authResult *domain.AuthResponse) *userpb.AuthenticateUserResponse {
+	return &userpb.AuthenticateUserResponse{
+		Authenticated:    true,
+		Token:            authResult.Token,
+		UserId:           authResult.UserID,
+		ErrorMessage:     "",
+		RefreshToken:     authResult.RefreshToken,
+		ExpiresAt:        timestamppb.New(authResult.ExpiresAt),
+		RefreshExpiresAt: timestamppb.New(authResult.RefreshExpiresAt),
+	}
+}
+
+func mapSessionErrorToGrpcStatus(err error) error {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "invalid token"):
+		return status.Errorf(codes.Unauthenticated, "%v", err)
+	case strings.Contains(errMsg, "not found"):
+		return status.Errorf(codes.NotFound, "%v", err)
+	case strings.Contains(errMsg, "invalid"):
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	default:
+		return status.Errorf(codes.Internal, "Session operation failed: %v", err)
+	}
+}
+
+func mapRoleErrorToGrpcStatus(err error) error {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "permission denied"):
+		return status.Errorf(codes.PermissionDenied, "%v", err)
+	case strings.Contains(errMsg, "not found"):
+		return status.Errorf(codes.NotFound, "%v", err)
+	case strings.Contains(errMsg, "invalid"):
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	default:
+		return status.Errorf(codes.Internal, "Role update failed: %v", err)
+	}
+}
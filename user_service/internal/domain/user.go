@@ -1,16 +1,44 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// Roles a user can hold. Every user is a customer; staff and admin are granted by an admin.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
 
 type User struct {
 	ID           int64
 	Name         string
 	Email        string
 	PasswordHash string
+	Roles        []string
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
 
+func (u *User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 type UserProfile struct {
 	ID    int64
 	Name  string
@@ -21,17 +49,77 @@ type AuthResponse struct {
 	Authenticated bool
 	Token         string
 	UserID        int64
+	ExpiresAt     time.Time
 	ErrorMessage  string
+
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+type Session struct {
+	ID        string
+	FamilyID  string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// RefreshToken is the stored (hashed) form of a single-use refresh token.
+type RefreshToken struct {
+	ID        string
+	FamilyID  string
+	SessionID string
+	UserID    int64
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type TokenValidation struct {
+	Valid        bool
+	UserID       int64
+	SessionID    string
+	Roles        []string
+	ExpiresAt    time.Time
+	ErrorMessage string
 }
 
 type UserRepository interface {
-	CreateUser(user *User) (*User, error)
-	GetUserByEmail(email string) (*User, error)
-	GetUserByID(id int64) (*User, error)
+	CreateUser(ctx context.Context, user *User) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	GetUserByID(ctx context.Context, id int64) (*User, error)
+	AddUserRole(ctx context.Context, id int64, role string) (*User, error)
+	RemoveUserRole(ctx context.Context, id int64, role string) (*User, error)
+}
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *Session, refreshToken *RefreshToken) (*Session, error)
+	GetSessionByID(ctx context.Context, id string) (*Session, error)
+	// ListActiveSessionsByUserID returns one session per login: its newest unrevoked session, which
+	// expires when the login's refresh token does.
+	ListActiveSessionsByUserID(ctx context.Context, userID int64) ([]Session, error)
+	RevokeSession(ctx context.Context, id string, userID int64) error
+	// DeleteExpiredSessions removes expired sessions, except those of refresh tokens still in use.
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, used *RefreshToken, session *Session, refreshToken *RefreshToken) error
+	RevokeTokenFamily(ctx context.Context, familyID string) error
 }
 
 type UserUseCase interface {
-	RegisterUser(name, email, password string) (*User, error)
-	AuthenticateUser(email, password string) (*AuthResponse, error)
-	GetUserProfile(id int64) (*UserProfile, error)
+	RegisterUser(ctx context.Context, name, email, password string) (*User, error)
+	AuthenticateUser(ctx context.Context, email, password string) (*AuthResponse, error)
+	GetUserProfile(ctx context.Context, id int64) (*UserProfile, error)
+	ValidateToken(ctx context.Context, token string) (*TokenValidation, error)
+	Logout(ctx context.Context, token string) error
+	ListSessions(ctx context.Context, userID int64) ([]Session, error)
+	RevokeSession(ctx context.Context, userID int64, sessionID string) error
+	CleanupExpiredSessions(ctx context.Context) (int64, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error)
+	GrantRole(ctx context.Context, actorID, userID int64, role string) (*User, error)
+	RevokeRole(ctx context.Context, actorID, userID int64, role string) (*User, error)
 }
@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// LoginAttempts counts password logins by result ("success" or "failure").
+var LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "user_login_attempts_total",
+	Help: "Total number of login attempts, by result.",
+}, []string{"result"})
+
+// LoginSucceeded records a successful login.
+func LoginSucceeded() { LoginAttempts.WithLabelValues("success").Inc() }
+
+// LoginFailed records a rejected login.
+func LoginFailed() { LoginAttempts.WithLabelValues("failure").Inc() }
@@ -0,0 +1,367 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"user_service/internal/domain"
+	"user_service/internal/requestid"
+	"user_service/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type postgresSessionRepository struct {
+	db  *sql.DB
+	log *logrus.Logger
+}
+
+func NewPostgresSessionRepository(db *sql.DB, logger *logrus.Logger) domain.SessionRepository {
+	return &postgresSessionRepository{
+		db:  db,
+		log: logger,
+	}
+}
+
+// withTx runs fn inside a transaction, committing on success and rolling back on error or panic.
+func (r *postgresSessionRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
+	log := requestid.Logger(ctx, r.log)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Errorf("Repository: Failed to begin transaction: %v", err)
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Errorf("Repository: Failed to rollback transaction: %v (original error: %v)", rbErr, err)
+			}
+		} else if cErr := tx.Commit(); cErr != nil {
+			log.Errorf("Repository: Failed to commit transaction: %v", cErr)
+			err = fmt.Errorf("failed to commit transaction: %w", cErr)
+		}
+	}()
+
+	return fn(tx)
+}
+
+func insertSessionTx(ctx context.Context, tx *sql.Tx, session *domain.Session) error {
+	query := `
+        INSERT INTO sessions (id, family_id, user_id, expires_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING created_at`
+	return tx.QueryRowContext(ctx, query, session.ID, session.FamilyID, session.UserID, session.ExpiresAt).Scan(&session.CreatedAt)
+}
+
+func insertRefreshTokenTx(ctx context.Context, tx *sql.Tx, token *domain.RefreshToken) error {
+	query := `
+        INSERT INTO refresh_tokens (id, family_id, session_id, user_id, token_hash, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING created_at`
+	return tx.QueryRowContext(ctx, query, token.ID, token.FamilyID, token.SessionID, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.CreatedAt)
+}
+
+func (r *postgresSessionRepository) CreateSession(ctx context.Context, session *domain.Session, refreshToken *domain.RefreshToken) (*domain.Session, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.CreateSession")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	log.Debugf("Repository: Attempting to create session %s for user ID: %d", session.ID, session.UserID)
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		if err := insertSessionTx(ctx, tx, session); err != nil {
+			return fmt.Errorf("could not create session: %w", err)
+		}
+		if err := insertRefreshTokenTx(ctx, tx, refreshToken); err != nil {
+			return fmt.Errorf("could not create refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Repository: Failed to create session for user ID %d: %v", session.UserID, err)
+		return nil, err
+	}
+
+	log.Infof("Repository: Session %s created for user ID: %d (family %s)", session.ID, session.UserID, session.FamilyID)
+	return session, nil
+}
+
+func (r *postgresSessionRepository) GetSessionByID(ctx context.Context, id string) (*domain.Session, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.GetSessionByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `
+        SELECT id, family_id, user_id, created_at, expires_at, revoked_at
+        FROM sessions
+        WHERE id = $1`
+	session := &domain.Session{}
+	var familyID sql.NullString
+	var revokedAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&session.ID,
+		&familyID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warnf("Repository: Session %s not found", id)
+			return nil, fmt.Errorf("session %s not found", id)
+		}
+		log.Errorf("Repository: Failed to get session %s: %v", id, err)
+		return nil, fmt.Errorf("could not get session: %w", err)
+	}
+
+	session.FamilyID = familyID.String
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+func (r *postgresSessionRepository) ListActiveSessionsByUserID(ctx context.Context, userID int64) ([]domain.Session, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.ListActiveSessionsByUserID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	// Rotation revokes the previous session, so a login has a single unrevoked session: the one issued
+	// with its current refresh token. It stays listed while that refresh token can still be used.
+	query := `
+        SELECT s.id, s.family_id, s.user_id, s.created_at, GREATEST(s.expires_at, COALESCE(t.expires_at, s.expires_at))
+        FROM sessions s
+        LEFT JOIN refresh_tokens t
+            ON t.session_id = s.id AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > NOW()
+        WHERE s.user_id = $1 AND s.revoked_at IS NULL AND (s.expires_at > NOW() OR t.id IS NOT NULL)
+        ORDER BY s.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Errorf("Repository: Failed to list sessions for user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("could not list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []domain.Session{}
+	for rows.Next() {
+		var session domain.Session
+		var familyID sql.NullString
+		if err := rows.Scan(&session.ID, &familyID, &session.UserID, &session.CreatedAt, &session.ExpiresAt); err != nil {
+			log.Errorf("Repository: Failed to scan session row for user ID %d: %v", userID, err)
+			return nil, fmt.Errorf("error scanning session data: %w", err)
+		}
+		session.FamilyID = familyID.String
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorf("Repository: Error during sessions iteration for user ID %d: %v", userID, err)
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	log.Debugf("Repository: Retrieved %d active sessions for user ID %d", len(sessions), userID)
+	return sessions, nil
+}
+
+// RevokeSession revokes the session and, when it belongs to a token family, every
+// other session and refresh token of that family so the login cannot be resumed. Any
+// session of a family that can still be refreshed names it, even one already rotated.
+func (r *postgresSessionRepository) RevokeSession(ctx context.Context, id string, userID int64) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.RevokeSession")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var familyID sql.NullString
+		var active bool
+		err := tx.QueryRowContext(ctx, `
+            SELECT family_id, revoked_at IS NULL
+            FROM sessions
+            WHERE id = $1 AND user_id = $2
+            FOR UPDATE`, id, userID).Scan(&familyID, &active)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("session %s not found", id)
+			}
+			return fmt.Errorf("could not revoke session: %w", err)
+		}
+
+		if !familyID.Valid {
+			if !active {
+				return fmt.Errorf("session %s not found", id)
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1`, id); err != nil {
+				return fmt.Errorf("could not revoke session: %w", err)
+			}
+			return nil
+		}
+
+		var live bool
+		err = tx.QueryRowContext(ctx, `
+            SELECT EXISTS (SELECT 1 FROM sessions WHERE family_id = $1 AND revoked_at IS NULL)
+                OR EXISTS (
+                    SELECT 1 FROM refresh_tokens
+                    WHERE family_id = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+                )`, familyID.String).Scan(&live)
+		if err != nil {
+			return fmt.Errorf("could not check token family %s: %w", familyID.String, err)
+		}
+		if !live {
+			return fmt.Errorf("session %s not found", id)
+		}
+		return revokeFamilyTx(ctx, tx, familyID.String)
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to revoke session %s for user ID %d: %v", id, userID, err)
+		return err
+	}
+
+	log.Infof("Repository: Session %s revoked for user ID %d", id, userID)
+	return nil
+}
+
+func (r *postgresSessionRepository) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.DeleteExpiredSessions")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	var deleted int64
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		// The session of a refresh token that can still be used is kept, so that its login can be
+		// listed and revoked until the refresh token expires
+		result, err := tx.ExecContext(ctx, `
+            DELETE FROM sessions s
+            WHERE s.expires_at <= NOW()
+              AND NOT EXISTS (
+                  SELECT 1 FROM refresh_tokens t
+                  WHERE t.session_id = s.id AND t.used_at IS NULL AND t.revoked_at IS NULL AND t.expires_at > NOW()
+              )`)
+		if err != nil {
+			return fmt.Errorf("could not delete expired sessions: %w", err)
+		}
+		if deleted, err = result.RowsAffected(); err != nil {
+			return fmt.Errorf("could not confirm expired sessions deletion: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at <= NOW()`); err != nil {
+			return fmt.Errorf("could not delete expired refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Repository: Failed to delete expired sessions: %v", err)
+		return 0, err
+	}
+
+	log.Debugf("Repository: Deleted %d expired sessions", deleted)
+	return deleted, nil
+}
+
+func (r *postgresSessionRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.GetRefreshTokenByHash")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `
+        SELECT id, family_id, session_id, user_id, token_hash, created_at, expires_at, used_at, revoked_at
+        FROM refresh_tokens
+        WHERE token_hash = $1`
+	token := &domain.RefreshToken{}
+	var usedAt, revokedAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.FamilyID,
+		&token.SessionID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Repository: Refresh token not found")
+			return nil, errors.New("refresh token not found")
+		}
+		log.Errorf("Repository: Failed to get refresh token: %v", err)
+		return nil, fmt.Errorf("could not get refresh token: %w", err)
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// RotateRefreshToken marks the used refresh token as consumed, revokes the access session
+// issued with it and stores the new session/refresh token pair, all in one transaction.
+func (r *postgresSessionRepository) RotateRefreshToken(ctx context.Context, used *domain.RefreshToken, session *domain.Session, refreshToken *domain.RefreshToken) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.RotateRefreshToken")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+            UPDATE refresh_tokens
+            SET used_at = NOW()
+            WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`, used.ID)
+		if err != nil {
+			return fmt.Errorf("could not mark refresh token as used: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("could not confirm refresh token use: %w", err)
+		}
+		if rowsAffected == 0 {
+			return fmt.Errorf("refresh token %s already used", used.ID)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, used.SessionID); err != nil {
+			return fmt.Errorf("could not revoke previous session: %w", err)
+		}
+		if err := insertSessionTx(ctx, tx, session); err != nil {
+			return fmt.Errorf("could not create session: %w", err)
+		}
+		if err := insertRefreshTokenTx(ctx, tx, refreshToken); err != nil {
+			return fmt.Errorf("could not create refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Repository: Failed to rotate refresh token %s (family %s): %v", used.ID, used.FamilyID, err)
+		return err
+	}
+
+	log.Infof("Repository: Refresh token %s rotated, new session %s (family %s)", used.ID, session.ID, session.FamilyID)
+	return nil
+}
+
+func (r *postgresSessionRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	ctx, span := tracing.StartSQLSpan(ctx, "SessionRepository.RevokeTokenFamily")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		return revokeFamilyTx(ctx, tx, familyID)
+	})
+	if err != nil {
+		log.Errorf("Repository: Failed to revoke token family %s: %v", familyID, err)
+		return err
+	}
+
+	log.Warnf("Repository: Token family %s revoked", familyID)
+	return nil
+}
+
+func revokeFamilyTx(ctx context.Context, tx *sql.Tx, familyID string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, familyID); err != nil {
+		return fmt.Errorf("could not revoke sessions of family %s: %w", familyID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, familyID); err != nil {
+		return fmt.Errorf("could not revoke refresh tokens of family %s: %w", familyID, err)
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"user_service/internal/domain"
+	"user_service/internal/requestid"
+	"user_service/internal/tracing"
 
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
@@ -22,16 +25,20 @@ func NewPostgresUserRepository(db *sql.DB, logger *logrus.Logger) domain.UserRep
 	}
 }
 
-func (r *postgresUserRepository) CreateUser(user *domain.User) (*domain.User, error) {
+func (r *postgresUserRepository) CreateUser(ctx context.Context, user *domain.User) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.CreateUser")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `
         INSERT INTO users (name, email, password_hash)
         VALUES ($1, $2, $3)
-        RETURNING id, created_at, updated_at`
+        RETURNING id, roles, created_at, updated_at`
 
-	r.log.Debugf("Repository: Attempting to create user with email: %s", user.Email)
+	log.Debugf("Repository: Attempting to create user with email: %s", user.Email)
 
-	err := r.db.QueryRow(query, user.Name, user.Email, user.PasswordHash).Scan(
+	err := r.db.QueryRowContext(ctx, query, user.Name, user.Email, user.PasswordHash).Scan(
 		&user.ID,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -39,78 +46,142 @@ func (r *postgresUserRepository) CreateUser(user *domain.User) (*domain.User, er
 	if err != nil {
 
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-			r.log.Warnf("Repository: Attempted to create user with duplicate email: %s", user.Email)
+			log.Warnf("Repository: Attempted to create user with duplicate email: %s", user.Email)
 			return nil, fmt.Errorf("user with email '%s' already exists", user.Email)
 		}
 
-		r.log.Errorf("Repository: Failed to create user '%s': %v", user.Email, err)
+		log.Errorf("Repository: Failed to create user '%s': %v", user.Email, err)
 		return nil, fmt.Errorf("could not create user: %w", err)
 	}
 
-	r.log.Infof("Repository: User created successfully with ID: %d, Email: %s", user.ID, user.Email)
+	log.Infof("Repository: User created successfully with ID: %d, Email: %s", user.ID, user.Email)
 	return user, nil
 }
 
-func (r *postgresUserRepository) GetUserByEmail(email string) (*domain.User, error) {
+func (r *postgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.GetUserByEmail")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `
-        SELECT id, name, email, password_hash, created_at, updated_at
+        SELECT id, name, email, password_hash, roles, created_at, updated_at
         FROM users
         WHERE email = $1`
 	user := &domain.User{}
 
-	r.log.Debugf("Repository: Attempting to find user by email: %s", email)
+	log.Debugf("Repository: Attempting to find user by email: %s", email)
 
-	err := r.db.QueryRow(query, email).Scan(
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.PasswordHash,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Repository: User with email %s not found", email)
+			log.Warnf("Repository: User with email %s not found", email)
 
 			return nil, fmt.Errorf("user with email %s not found", email)
 		}
-		r.log.Errorf("Repository: Failed to get user by email %s: %v", email, err)
+		log.Errorf("Repository: Failed to get user by email %s: %v", email, err)
 		return nil, fmt.Errorf("could not get user by email: %w", err)
 	}
 
-	r.log.Debugf("Repository: User found by email %s (ID: %d)", email, user.ID)
+	log.Debugf("Repository: User found by email %s (ID: %d)", email, user.ID)
 	return user, nil
 }
 
-func (r *postgresUserRepository) GetUserByID(id int64) (*domain.User, error) {
+func (r *postgresUserRepository) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.GetUserByID")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
 	query := `
-        SELECT id, name, email, password_hash, created_at, updated_at
+        SELECT id, name, email, password_hash, roles, created_at, updated_at
         FROM users
         WHERE id = $1`
 	user := &domain.User{}
 
-	r.log.Debugf("Repository: Attempting to find user by ID: %d", id)
+	log.Debugf("Repository: Attempting to find user by ID: %d", id)
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.PasswordHash,
+		pq.Array(&user.Roles),
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warnf("Repository: User with ID %d not found", id)
+			log.Warnf("Repository: User with ID %d not found", id)
 
 			return nil, fmt.Errorf("user with id %d not found", id)
 		}
-		r.log.Errorf("Repository: Failed to get user by ID %d: %v", id, err)
+		log.Errorf("Repository: Failed to get user by ID %d: %v", id, err)
 		return nil, fmt.Errorf("could not get user by id: %w", err)
 	}
 
-	r.log.Debugf("Repository: User found by ID %d (Email: %s)", id, user.Email)
+	log.Debugf("Repository: User found by ID %d (Email: %s)", id, user.Email)
+	return user, nil
+}
+
+func (r *postgresUserRepository) AddUserRole(ctx context.Context, id int64, role string) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.AddUserRole")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `
+        UPDATE users
+        SET roles = ARRAY(SELECT DISTINCT unnest(array_append(roles, $2::TEXT)) ORDER BY 1)
+        WHERE id = $1
+        RETURNING id, name, email, password_hash, roles, created_at, updated_at`
+
+	log.Debugf("Repository: Attempting to add role %s to user ID: %d", role, id)
+	return r.updateUserRoles(ctx, query, id, role)
+}
+
+func (r *postgresUserRepository) RemoveUserRole(ctx context.Context, id int64, role string) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.RemoveUserRole")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	query := `
+        UPDATE users
+        SET roles = array_remove(roles, $2::TEXT)
+        WHERE id = $1
+        RETURNING id, name, email, password_hash, roles, created_at, updated_at`
+
+	log.Debugf("Repository: Attempting to remove role %s from user ID: %d", role, id)
+	return r.updateUserRoles(ctx, query, id, role)
+}
+
+func (r *postgresUserRepository) updateUserRoles(ctx context.Context, query string, id int64, role string) (*domain.User, error) {
+	ctx, span := tracing.StartSQLSpan(ctx, "UserRepository.updateUserRoles")
+	defer span.End()
+	log := requestid.Logger(ctx, r.log)
+	user := &domain.User{}
+	err := r.db.QueryRowContext(ctx, query, id, role).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.PasswordHash,
+		pq.Array(&user.Roles),
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warnf("Repository: User with ID %d not found for role update", id)
+			return nil, fmt.Errorf("user with id %d not found", id)
+		}
+		log.Errorf("Repository: Failed to update roles (%s) of user ID %d: %v", role, id, err)
+		return nil, fmt.Errorf("could not update user roles: %w", err)
+	}
+
+	log.Infof("Repository: Roles of user ID %d are now %v", id, user.Roles)
 	return user, nil
 }
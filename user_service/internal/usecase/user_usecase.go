@@ -1,11 +1,16 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 	"unicode"
+	"user_service/internal/auth"
 	"user_service/internal/domain" // Убедись, что путь импорта правильный
+	"user_service/internal/metrics"
+	"user_service/internal/requestid"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -14,50 +19,56 @@ import (
 
 // userUseCase implements the domain.UserUseCase interface
 type userUseCase struct {
-	userRepo domain.UserRepository
-	log      *logrus.Logger
-	// Можно добавить сюда секрет для JWT, если будем генерировать его здесь
+	userRepo        domain.UserRepository
+	sessionRepo     domain.SessionRepository
+	jwtManager      *auth.JWTManager
+	refreshTokenTTL time.Duration
+	log             *logrus.Logger
 }
 
 // NewUserUseCase creates a new instance of userUseCase
-func NewUserUseCase(repo domain.UserRepository, logger *logrus.Logger) domain.UserUseCase {
+func NewUserUseCase(repo domain.UserRepository, sessionRepo domain.SessionRepository, jwtManager *auth.JWTManager, refreshTokenTTL time.Duration, logger *logrus.Logger) domain.UserUseCase {
 	return &userUseCase{
-		userRepo: repo,
-		log:      logger,
+		userRepo:        repo,
+		sessionRepo:     sessionRepo,
+		jwtManager:      jwtManager,
+		refreshTokenTTL: refreshTokenTTL,
+		log:             logger,
 	}
 }
 
 // RegisterUser handles user registration, including validation and password hashing
-func (uc *userUseCase) RegisterUser(name, email, password string) (*domain.User, error) {
-	uc.log.Infof("Use Case: Attempting registration for email: %s", email)
+func (uc *userUseCase) RegisterUser(ctx context.Context, name, email, password string) (*domain.User, error) {
+	log := requestid.Logger(ctx, uc.log)
+	log.Infof("Use Case: Attempting registration for email: %s", email)
 
 	// 1. Basic Validation
 	name = strings.TrimSpace(name)
 	email = strings.ToLower(strings.TrimSpace(email)) // Normalize email
 
 	if name == "" {
-		uc.log.Warn("Use Case: Registration failed - empty name")
+		log.Warn("Use Case: Registration failed - empty name")
 		return nil, errors.New("user name cannot be empty")
 	}
 	if !isValidEmail(email) { // Простая проверка email
-		uc.log.Warnf("Use Case: Registration failed - invalid email format: %s", email)
+		log.Warnf("Use Case: Registration failed - invalid email format: %s", email)
 		return nil, errors.New("invalid email format")
 	}
 	if err := validatePassword(password); err != nil { // Проверка сложности пароля
-		uc.log.Warnf("Use Case: Registration failed - password validation error: %v", err)
+		log.Warnf("Use Case: Registration failed - password validation error: %v", err)
 		return nil, err
 	}
 
 	// 2. Check if email already exists
 	// Мы полагаемся на ошибку уникальности от репозитория, но можно и явно проверить
-	// _, err := uc.userRepo.GetUserByEmail(email)
+	// _, err := uc.userRepo.GetUserByEmail(ctx, email)
 	// if err == nil {
-	//  uc.log.Warnf("Use Case: Registration failed - email already exists: %s", email)
+	//  log.Warnf("Use Case: Registration failed - email already exists: %s", email)
 	//  return nil, fmt.Errorf("user with email '%s' already exists", email)
 	// }
 	// if !errors.Is(err, sql.ErrNoRows) && !strings.Contains(err.Error(), "not found") { // Проверяем, что ошибка именно "не найдено"
 	//  // Если другая ошибка (например, DB недоступна), пробрасываем ее
-	//  uc.log.Errorf("Use Case: Error checking email existence for %s: %v", email, err)
+	//  log.Errorf("Use Case: Error checking email existence for %s: %v", email, err)
 	//  return nil, fmt.Errorf("failed to check email existence: %w", err)
 	// }
 	// Оставим проверку на уровне репозитория (unique constraint) для атомарности
@@ -65,7 +76,7 @@ func (uc *userUseCase) RegisterUser(name, email, password string) (*domain.User,
 	// 3. Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		uc.log.Errorf("Use Case: Failed to hash password for %s: %v", email, err)
+		log.Errorf("Use Case: Failed to hash password for %s: %v", email, err)
 		return nil, fmt.Errorf("internal error processing password: %w", err)
 	}
 
@@ -77,14 +88,14 @@ func (uc *userUseCase) RegisterUser(name, email, password string) (*domain.User,
 	}
 
 	// 5. Save to repository
-	createdUser, err := uc.userRepo.CreateUser(newUser)
+	createdUser, err := uc.userRepo.CreateUser(ctx, newUser)
 	if err != nil {
-		uc.log.Errorf("Use Case: Repository failed to create user %s: %v", email, err)
+		log.Errorf("Use Case: Repository failed to create user %s: %v", email, err)
 		// Возвращаем ошибку как есть (она уже содержит сообщение про duplicate email)
 		return nil, err
 	}
 
-	uc.log.Infof("Use Case: User registered successfully. ID: %d, Email: %s", createdUser.ID, createdUser.Email)
+	log.Infof("Use Case: User registered successfully. ID: %d, Email: %s", createdUser.ID, createdUser.Email)
 	// Возвращаем пользователя без хеша пароля (если нужно для ответа gRPC)
 	// Хотя RegisterUser в proto возвращает User (который без хеша),
 	// здесь можно вернуть полного пользователя, а в gRPC хендлере отфильтровать.
@@ -92,25 +103,28 @@ func (uc *userUseCase) RegisterUser(name, email, password string) (*domain.User,
 }
 
 // AuthenticateUser handles user login
-func (uc *userUseCase) AuthenticateUser(email, password string) (*domain.AuthResponse, error) {
+func (uc *userUseCase) AuthenticateUser(ctx context.Context, email, password string) (*domain.AuthResponse, error) {
+	log := requestid.Logger(ctx, uc.log)
 	email = strings.ToLower(strings.TrimSpace(email))
-	uc.log.Infof("Use Case: Attempting authentication for email: %s", email)
+	log.Infof("Use Case: Attempting authentication for email: %s", email)
 
 	if !isValidEmail(email) || password == "" {
-		uc.log.Warnf("Use Case: Auth failed - invalid email or empty password for %s", email)
+		log.Warnf("Use Case: Auth failed - invalid email or empty password for %s", email)
+		metrics.LoginFailed()
 		return &domain.AuthResponse{Authenticated: false, ErrorMessage: "Invalid email or password"}, nil // Не ошибка, а результат "не аутентифицирован"
 	}
 
 	// 1. Get user by email
-	user, err := uc.userRepo.GetUserByEmail(email)
+	user, err := uc.userRepo.GetUserByEmail(ctx, email)
 	if err != nil {
 		// Если пользователь не найден
 		if strings.Contains(err.Error(), "not found") {
-			uc.log.Warnf("Use Case: Auth failed - user not found: %s", email)
+			log.Warnf("Use Case: Auth failed - user not found: %s", email)
+			metrics.LoginFailed()
 			return &domain.AuthResponse{Authenticated: false, ErrorMessage: "Invalid email or password"}, nil
 		}
 		// Если другая ошибка БД
-		uc.log.Errorf("Use Case: Error retrieving user %s during auth: %v", email, err)
+		log.Errorf("Use Case: Error retrieving user %s during auth: %v", email, err)
 		return nil, fmt.Errorf("failed to retrieve user: %w", err) // Это внутренняя ошибка
 	}
 
@@ -119,37 +133,45 @@ func (uc *userUseCase) AuthenticateUser(email, password string) (*domain.AuthRes
 	if err != nil {
 		// Если пароли не совпадают (bcrypt.ErrMismatchedHashAndPassword)
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			uc.log.Warnf("Use Case: Auth failed - incorrect password for user %s (ID: %d)", email, user.ID)
+			log.Warnf("Use Case: Auth failed - incorrect password for user %s (ID: %d)", email, user.ID)
+			metrics.LoginFailed()
 			return &domain.AuthResponse{Authenticated: false, ErrorMessage: "Invalid email or password"}, nil
 		}
 		// Если другая ошибка при сравнении (маловероятно)
-		uc.log.Errorf("Use Case: Error comparing password hash for user %s: %v", email, err)
+		log.Errorf("Use Case: Error comparing password hash for user %s: %v", email, err)
 		return nil, fmt.Errorf("internal error during authentication: %w", err) // Внутренняя ошибка
 	}
 
-	// 3. Authentication successful - Generate a simple token (UUID for now)
-	token := uuid.NewString()
-	uc.log.Infof("Use Case: Authentication successful for user %s (ID: %d). Generated token: %s", email, user.ID, token)
+	// 3. Authentication successful - Issue a token pair starting a new token family
+	issued, err := uc.issueTokens(user, uuid.NewString())
+	if err != nil {
+		log.Errorf("Use Case: Failed to issue tokens for user %s (ID: %d): %v", email, user.ID, err)
+		return nil, fmt.Errorf("internal error issuing token: %w", err)
+	}
 
-	return &domain.AuthResponse{
-		Authenticated: true,
-		Token:         token,
-		UserID:        user.ID,
-	}, nil
+	if _, err := uc.sessionRepo.CreateSession(ctx, issued.session, issued.refreshToken); err != nil {
+		log.Errorf("Use Case: Failed to store session for user %s (ID: %d): %v", email, user.ID, err)
+		return nil, fmt.Errorf("internal error storing session: %w", err)
+	}
+	log.Infof("Use Case: Authentication successful for user %s (ID: %d). Token expires at %s", email, user.ID, issued.response.ExpiresAt.Format(time.RFC3339))
+	metrics.LoginSucceeded()
+
+	return issued.response, nil
 }
 
 // GetUserProfile retrieves user profile information
-func (uc *userUseCase) GetUserProfile(id int64) (*domain.UserProfile, error) {
-	uc.log.Infof("Use Case: Attempting to get profile for user ID: %d", id)
+func (uc *userUseCase) GetUserProfile(ctx context.Context, id int64) (*domain.UserProfile, error) {
+	log := requestid.Logger(ctx, uc.log)
+	log.Infof("Use Case: Attempting to get profile for user ID: %d", id)
 
 	if id <= 0 {
-		uc.log.Warnf("Use Case: Get profile failed - invalid user ID: %d", id)
+		log.Warnf("Use Case: Get profile failed - invalid user ID: %d", id)
 		return nil, errors.New("invalid user ID")
 	}
 
-	user, err := uc.userRepo.GetUserByID(id)
+	user, err := uc.userRepo.GetUserByID(ctx, id)
 	if err != nil {
-		uc.log.Warnf("Use Case: Repository failed to get user profile for ID %d: %v", id, err)
+		log.Warnf("Use Case: Repository failed to get user profile for ID %d: %v", id, err)
 		// Возвращаем ошибку как есть (включая not found)
 		return nil, err
 	}
@@ -161,12 +183,321 @@ func (uc *userUseCase) GetUserProfile(id int64) (*domain.UserProfile, error) {
 		Email: user.Email,
 	}
 
-	uc.log.Infof("Use Case: Profile retrieved successfully for user ID: %d", id)
+	log.Infof("Use Case: Profile retrieved successfully for user ID: %d", id)
 	return profile, nil
 }
 
+// ValidateToken checks the token signature and that its session has not been revoked or expired
+func (uc *userUseCase) ValidateToken(ctx context.Context, token string) (*domain.TokenValidation, error) {
+	log := requestid.Logger(ctx, uc.log)
+	claims, err := uc.jwtManager.Parse(token)
+	if err != nil {
+		log.Warnf("Use Case: Token validation failed: %v", err)
+		return &domain.TokenValidation{Valid: false, ErrorMessage: "Invalid or expired token"}, nil
+	}
+	userID, err := auth.UserIDFromClaims(claims)
+	if err != nil {
+		log.Warnf("Use Case: Token validation failed: %v", err)
+		return &domain.TokenValidation{Valid: false, ErrorMessage: "Invalid token"}, nil
+	}
+
+	session, err := uc.sessionRepo.GetSessionByID(ctx, claims.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Warnf("Use Case: Token validation failed - session %s not found", claims.ID)
+			return &domain.TokenValidation{Valid: false, ErrorMessage: "Session not found"}, nil
+		}
+		log.Errorf("Use Case: Error retrieving session %s during token validation: %v", claims.ID, err)
+		return nil, fmt.Errorf("failed to retrieve session: %w", err)
+	}
+
+	if session.UserID != userID {
+		log.Warnf("Use Case: Token validation failed - session %s belongs to user %d, token subject is %d", session.ID, session.UserID, userID)
+		return &domain.TokenValidation{Valid: false, ErrorMessage: "Invalid token"}, nil
+	}
+	if session.RevokedAt != nil {
+		log.Warnf("Use Case: Token validation failed - session %s was revoked", session.ID)
+		return &domain.TokenValidation{Valid: false, ErrorMessage: "Session has been revoked"}, nil
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		log.Warnf("Use Case: Token validation failed - session %s expired", session.ID)
+		return &domain.TokenValidation{Valid: false, ErrorMessage: "Session has expired"}, nil
+	}
+
+	log.Debugf("Use Case: Token valid for user ID %d (session %s)", userID, session.ID)
+	return &domain.TokenValidation{
+		Valid:     true,
+		UserID:    userID,
+		SessionID: session.ID,
+		Roles:     claims.Roles,
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// Logout revokes the session behind the given token
+func (uc *userUseCase) Logout(ctx context.Context, token string) error {
+	log := requestid.Logger(ctx, uc.log)
+	claims, err := uc.jwtManager.Parse(token)
+	if err != nil {
+		log.Warnf("Use Case: Logout failed - %v", err)
+		return err
+	}
+	userID, err := auth.UserIDFromClaims(claims)
+	if err != nil {
+		log.Warnf("Use Case: Logout failed - %v", err)
+		return err
+	}
+
+	if err := uc.sessionRepo.RevokeSession(ctx, claims.ID, userID); err != nil {
+		log.Warnf("Use Case: Logout failed for user ID %d (session %s): %v", userID, claims.ID, err)
+		return err
+	}
+
+	log.Infof("Use Case: User ID %d logged out (session %s revoked)", userID, claims.ID)
+	return nil
+}
+
+// ListSessions returns the active logins of a user, one session each
+func (uc *userUseCase) ListSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	log := requestid.Logger(ctx, uc.log)
+	if userID <= 0 {
+		log.Warnf("Use Case: List sessions failed - invalid user ID: %d", userID)
+		return nil, errors.New("invalid user ID")
+	}
+
+	sessions, err := uc.sessionRepo.ListActiveSessionsByUserID(ctx, userID)
+	if err != nil {
+		log.Errorf("Use Case: Repository failed to list sessions for user ID %d: %v", userID, err)
+		return nil, err
+	}
+
+	log.Infof("Use Case: Retrieved %d active sessions for user ID %d", len(sessions), userID)
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's own sessions together with its login
+func (uc *userUseCase) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	log := requestid.Logger(ctx, uc.log)
+	if userID <= 0 {
+		log.Warnf("Use Case: Revoke session failed - invalid user ID: %d", userID)
+		return errors.New("invalid user ID")
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		log.Warnf("Use Case: Revoke session failed - invalid session ID: %s", sessionID)
+		return errors.New("invalid session ID")
+	}
+
+	if err := uc.sessionRepo.RevokeSession(ctx, sessionID, userID); err != nil {
+		log.Warnf("Use Case: Repository failed to revoke session %s for user ID %d: %v", sessionID, userID, err)
+		return err
+	}
+
+	log.Infof("Use Case: Session %s revoked for user ID %d", sessionID, userID)
+	return nil
+}
+
+// CleanupExpiredSessions removes sessions whose tokens can no longer be used
+func (uc *userUseCase) CleanupExpiredSessions(ctx context.Context) (int64, error) {
+	log := requestid.Logger(ctx, uc.log)
+	deleted, err := uc.sessionRepo.DeleteExpiredSessions(ctx)
+	if err != nil {
+		log.Errorf("Use Case: Failed to clean up expired sessions: %v", err)
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Infof("Use Case: Cleaned up %d expired sessions", deleted)
+	}
+	return deleted, nil
+}
+
+// RefreshToken exchanges a single-use refresh token for a new access/refresh token pair.
+// Presenting a refresh token that was already used revokes its whole token family.
+func (uc *userUseCase) RefreshToken(ctx context.Context, refreshToken string) (*domain.AuthResponse, error) {
+	log := requestid.Logger(ctx, uc.log)
+	rejected := &domain.AuthResponse{Authenticated: false, ErrorMessage: "Invalid or expired refresh token"}
+	if refreshToken == "" {
+		return rejected, nil
+	}
+
+	stored, err := uc.sessionRepo.GetRefreshTokenByHash(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Warn("Use Case: Refresh failed - unknown refresh token")
+			return rejected, nil
+		}
+		log.Errorf("Use Case: Error retrieving refresh token: %v", err)
+		return nil, fmt.Errorf("failed to retrieve refresh token: %w", err)
+	}
+
+	if stored.UsedAt != nil || stored.RevokedAt != nil {
+		log.Warnf("Use Case: Refresh token reuse detected for user ID %d (family %s). Revoking family.", stored.UserID, stored.FamilyID)
+		if err := uc.sessionRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return nil, fmt.Errorf("failed to revoke token family: %w", err)
+		}
+		return rejected, nil
+	}
+	if !stored.ExpiresAt.After(time.Now()) {
+		log.Warnf("Use Case: Refresh failed - token expired for user ID %d (family %s)", stored.UserID, stored.FamilyID)
+		return rejected, nil
+	}
+
+	// Reload the user so role changes made since the last refresh end up in the new token
+	user, err := uc.userRepo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Warnf("Use Case: Refresh failed - user ID %d no longer exists", stored.UserID)
+			return rejected, nil
+		}
+		log.Errorf("Use Case: Error retrieving user ID %d during refresh: %v", stored.UserID, err)
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	issued, err := uc.issueTokens(user, stored.FamilyID)
+	if err != nil {
+		log.Errorf("Use Case: Failed to issue tokens on refresh for user ID %d: %v", stored.UserID, err)
+		return nil, fmt.Errorf("internal error issuing token: %w", err)
+	}
+
+	if err := uc.sessionRepo.RotateRefreshToken(ctx, stored, issued.session, issued.refreshToken); err != nil {
+		if strings.Contains(err.Error(), "already used") {
+			// Lost a race with another refresh using the same token - treat it as reuse.
+			log.Warnf("Use Case: Concurrent refresh token use for user ID %d (family %s). Revoking family.", stored.UserID, stored.FamilyID)
+			if err := uc.sessionRepo.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+				return nil, fmt.Errorf("failed to revoke token family: %w", err)
+			}
+			return rejected, nil
+		}
+		log.Errorf("Use Case: Failed to rotate refresh token for user ID %d: %v", stored.UserID, err)
+		return nil, fmt.Errorf("internal error rotating refresh token: %w", err)
+	}
+
+	log.Infof("Use Case: Token pair refreshed for user ID %d (family %s)", stored.UserID, stored.FamilyID)
+	return issued.response, nil
+}
+
+// GrantRole adds a role to a user. Only admins may change roles.
+func (uc *userUseCase) GrantRole(ctx context.Context, actorID, userID int64, role string) (*domain.User, error) {
+	log := requestid.Logger(ctx, uc.log)
+	log.Infof("Use Case: User ID %d attempting to grant role %s to user ID %d", actorID, role, userID)
+
+	if err := uc.checkRoleChange(ctx, actorID, userID, role); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.userRepo.AddUserRole(ctx, userID, role)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to grant role %s to user ID %d: %v", role, userID, err)
+		return nil, err
+	}
+
+	log.Infof("Use Case: Role %s granted to user ID %d by admin ID %d", role, userID, actorID)
+	return user, nil
+}
+
+// RevokeRole removes a role from a user. Only admins may change roles, and an admin
+// cannot drop their own admin role so the system is never left without one by accident.
+func (uc *userUseCase) RevokeRole(ctx context.Context, actorID, userID int64, role string) (*domain.User, error) {
+	log := requestid.Logger(ctx, uc.log)
+	log.Infof("Use Case: User ID %d attempting to revoke role %s from user ID %d", actorID, role, userID)
+
+	if err := uc.checkRoleChange(ctx, actorID, userID, role); err != nil {
+		return nil, err
+	}
+	if role == domain.RoleCustomer {
+		log.Warnf("Use Case: Revoke role failed - the %s role cannot be revoked", domain.RoleCustomer)
+		return nil, fmt.Errorf("invalid role change: the %s role cannot be revoked", domain.RoleCustomer)
+	}
+	if actorID == userID && role == domain.RoleAdmin {
+		log.Warnf("Use Case: Revoke role failed - admin ID %d tried to revoke their own admin role", actorID)
+		return nil, errors.New("invalid role change: admins cannot revoke their own admin role")
+	}
+
+	user, err := uc.userRepo.RemoveUserRole(ctx, userID, role)
+	if err != nil {
+		log.Warnf("Use Case: Repository failed to revoke role %s from user ID %d: %v", role, userID, err)
+		return nil, err
+	}
+
+	log.Infof("Use Case: Role %s revoked from user ID %d by admin ID %d", role, userID, actorID)
+	return user, nil
+}
+
 // --- Helper Functions ---
 
+// checkRoleChange validates a role change request and verifies the actor is an admin.
+func (uc *userUseCase) checkRoleChange(ctx context.Context, actorID, userID int64, role string) error {
+	log := requestid.Logger(ctx, uc.log)
+	if userID <= 0 {
+		log.Warnf("Use Case: Role change failed - invalid user ID: %d", userID)
+		return errors.New("invalid user ID")
+	}
+	if !domain.IsValidRole(role) {
+		log.Warnf("Use Case: Role change failed - invalid role: %s", role)
+		return fmt.Errorf("invalid role '%s'", role)
+	}
+
+	actor, err := uc.userRepo.GetUserByID(ctx, actorID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			log.Warnf("Use Case: Role change failed - actor ID %d not found", actorID)
+			return errors.New("permission denied: unknown caller")
+		}
+		return fmt.Errorf("failed to retrieve caller: %w", err)
+	}
+	if !actor.HasRole(domain.RoleAdmin) {
+		log.Warnf("Use Case: Role change failed - user ID %d is not an admin", actorID)
+		return errors.New("permission denied: admin role required")
+	}
+	return nil
+}
+
+type issuedTokens struct {
+	session      *domain.Session
+	refreshToken *domain.RefreshToken
+	response     *domain.AuthResponse
+}
+
+// issueTokens builds a new access token, its session and a refresh token within the given family.
+func (uc *userUseCase) issueTokens(user *domain.User, familyID string) (*issuedTokens, error) {
+	userID := user.ID
+	sessionID := uuid.NewString()
+	accessToken, expiresAt, err := uc.jwtManager.Generate(userID, sessionID, user.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	refreshExpiresAt := time.Now().Add(uc.refreshTokenTTL)
+
+	return &issuedTokens{
+		session: &domain.Session{
+			ID:        sessionID,
+			FamilyID:  familyID,
+			UserID:    userID,
+			ExpiresAt: expiresAt,
+		},
+		refreshToken: &domain.RefreshToken{
+			ID:        uuid.NewString(),
+			FamilyID:  familyID,
+			SessionID: sessionID,
+			UserID:    userID,
+			TokenHash: auth.HashRefreshToken(refreshToken),
+			ExpiresAt: refreshExpiresAt,
+		},
+		response: &domain.AuthResponse{
+			Authenticated:    true,
+			Token:            accessToken,
+			UserID:           userID,
+			ExpiresAt:        expiresAt,
+			RefreshToken:     refreshToken,
+			RefreshExpiresAt: refreshExpiresAt,
+		},
+	}, nil
+}
+
 // isValidEmail provides a basic check for email format.
 // For production, consider a more robust library.
 func isValidEmail(email string) bool {
@@ -0,0 +1,13 @@
+CREATE TABLE sessions (
+                          id UUID PRIMARY KEY, -- Matches the jti claim of the issued JWT
+                          user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                          expires_at TIMESTAMPTZ NOT NULL,
+                          revoked_at TIMESTAMPTZ -- NULL while the session is active
+);
+
+-- Index for listing a user's sessions
+CREATE INDEX idx_sessions_user_id ON sessions(user_id);
+
+-- Index for the expired sessions cleanup job
+CREATE INDEX idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_expires_at;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+DROP TABLE IF EXISTS refresh_tokens;
+
+DROP INDEX IF EXISTS idx_sessions_family_id;
+ALTER TABLE sessions DROP COLUMN IF EXISTS family_id;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- A token family groups every session and refresh token issued from one login
+ALTER TABLE sessions ADD COLUMN family_id UUID;
+
+CREATE INDEX idx_sessions_family_id ON sessions(family_id);
+
+CREATE TABLE refresh_tokens (
+                                id UUID PRIMARY KEY,
+                                family_id UUID NOT NULL,
+                                session_id UUID NOT NULL, -- Access session issued together with this refresh token
+                                user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                token_hash CHAR(64) NOT NULL UNIQUE, -- SHA-256 hex of the token, the token itself is never stored
+                                created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                expires_at TIMESTAMPTZ NOT NULL,
+                                used_at TIMESTAMPTZ, -- Set once the token has been exchanged (single use)
+                                revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_roles_valid;
+ALTER TABLE users DROP COLUMN IF EXISTS roles;
@@ -0,0 +1,10 @@
+ALTER TABLE users
+    ADD COLUMN roles TEXT[] NOT NULL DEFAULT ARRAY['customer']::TEXT[];
+
+-- Only known roles may be stored
+ALTER TABLE users
+    ADD CONSTRAINT users_roles_valid CHECK (roles <@ ARRAY['customer', 'staff', 'admin']::TEXT[]);
+
+-- Roles are managed through the admin-only GrantRole/RevokeRole RPCs.
+-- The first admin has to be seeded by hand, e.g.:
+-- UPDATE users SET roles = ARRAY['customer', 'admin'] WHERE email = 'admin@example.com';